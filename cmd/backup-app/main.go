@@ -1,9 +1,12 @@
 package main
 
 import (
+	"backup-app/internal/backup"
 	"backup-app/internal/database"
 	"backup-app/internal/handlers"
 	"backup-app/internal/scheduler"
+	"backup-app/internal/window"
+	"bufio"
 	"context"
 	"fmt"
 	"html/template"
@@ -13,6 +16,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,10 +29,8 @@ var templates *template.Template
 // TODO: convert bytes to megabytes/gigabytes/... get it from size and apply automatically
 // TODO: what abuot large file copy to network, s3, etc?
 // TODO: chunking/splitting, resumable uploads, retries, timeouts to avoid breaches on instable networks
-// TODO: modify scheduller to human readeble interface. with simple set time, adn chose daily/,amually/or set which days should be included or by which days should backup occurs
 // TODO: add dynamic update to job status
 // TODO: fix page view (background width not changed but tasks and another info width more wide than background)
-// TODO: add next run field (according to scheduller, or "not set" for manual backup)
 // TODO: list of backups should be more tableview and more narrow (should fit in windiows size)
 // TODO: add data transfer view for exact backup run (how much data will be copied)
 // TODO: add backup size in destination directory
@@ -77,6 +79,32 @@ func main() {
 	fmt.Printf(" Server Timeouts: Read=%s, Write=%s, Idle=%s\n", cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout)
 	fmt.Printf(" Shutdown Timeout: %s\n", cfg.ShutdownTimeout)
 	fmt.Printf(" Path to log file: %s\n", cfg.LogFilePath)
+	fmt.Printf(" Run limits: max concurrent=%d, max per destination=%d\n", cfg.MaxConcurrentRuns, cfg.MaxRunsPerDestination)
+	fmt.Printf(" Backup window: %s - %s, blackout dates: %v\n", cfg.BackupWindowStart, cfg.BackupWindowEnd, cfg.BlackoutDates)
+
+	if cfg.InstanceID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "localhost"
+		}
+		cfg.InstanceID = fmt.Sprintf("%s:%d", hostname, cfg.ServerPort)
+	}
+	if cfg.LeaderLeaseTTL == "" {
+		cfg.LeaderLeaseTTL = "30s"
+	}
+	leaseTTL, err := time.ParseDuration(cfg.LeaderLeaseTTL)
+	if err != nil || leaseTTL < 3*time.Second {
+		log.Fatalf("Wrong leader_lease_ttl '%s', expected duration of at least 3s", cfg.LeaderLeaseTTL)
+	}
+	fmt.Printf(" Instance: %s, leader lease: %s\n", cfg.InstanceID, leaseTTL)
+
+	backup.SetAllowedCommands(cfg.AllowedCommands)
+	fmt.Printf(" Allowed commands: %d\n", len(cfg.AllowedCommands))
+
+	globalWindow, err := window.Parse(cfg.BackupWindowStart, cfg.BackupWindowEnd, strings.Join(cfg.BlackoutDates, ","))
+	if err != nil {
+		log.Fatalf("Wrong backup window in configuration: %v", err)
+	}
 
 	//Initialize DataBase
 	db, err := database.InitDB(cfg.DatabasePath)
@@ -94,7 +122,8 @@ func main() {
 
 	//--- Load HTML-templates ---
 
-	templates, err = template.ParseFiles(filepath.Join("web", "templates", "layout.html"))
+	templates, err = template.ParseFiles(filepath.Join("web", "templates", "layout.html"),
+		filepath.Join("web", "templates", "schedule_fields.html"))
 	if err != nil {
 		log.Fatalf("Error loading HTML-templates: %v", err)
 	}
@@ -104,10 +133,26 @@ func main() {
 
 	//--- Repos initialization
 	userRepo := database.NewUserRepo(db)
+
+	// "backup-app create-admin <username>" adds admin or changes its password and exits, password is read from stdin
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		if err := createAdmin(userRepo, os.Args[2:], os.Stdin); err != nil {
+			log.Fatalf("Admin creation error: %v", err)
+		}
+		return
+	}
 	jobRepo := database.NewJobRepo(db)
+	replicaRepo := database.NewReplicaRepo(db)
+	runRepo := database.NewRunRepo(db)
+	runRepo.SetInstance(cfg.InstanceID)
+	settingsRepo := database.NewSettingsRepo(db)
+	leaseRepo := database.NewLeaseRepo(db)
 
 	// Scheduler initialization
-	schedManager := scheduler.NewSchedulerManager(jobRepo)
+	schedManager := scheduler.NewSchedulerManager(jobRepo, replicaRepo, runRepo, settingsRepo)
+	schedManager.SetRunLimits(cfg.MaxConcurrentRuns, cfg.MaxRunsPerDestination)
+	schedManager.SetGlobalWindow(globalWindow)
+	schedManager.SetLeaderElection(leaseRepo, cfg.InstanceID, leaseTTL)
 	schedManager.Start()
 
 	schedManager.LoadAndScheduleJobs()
@@ -118,10 +163,19 @@ func main() {
 	mux := http.NewServeMux()
 
 	//WebHandlers initialization
-	webHandlers := handlers.NewWebHandlers(templates, userRepo, jobRepo)
+	webHandlers := handlers.NewWebHandlers(templates, userRepo, jobRepo, replicaRepo, runRepo)
 
 	//sheduler tasks reload
-	webHandlers.SetSchedulerReloadFunc(schedManager.LoadAndScheduleJobs)
+	webHandlers.SetSchedulerReloadFunc(schedManager.ReloadJob)
+	webHandlers.SetSchedulerRunFunc(schedManager.StartJob)
+	webHandlers.SetSchedulerCancelFunc(schedManager.CancelJob)
+	webHandlers.SetSchedulerPauseFunc(schedManager.Pause)
+	webHandlers.SetSchedulerResumeFunc(schedManager.Resume)
+	webHandlers.SetPauseStateFunc(schedManager.PauseState)
+	webHandlers.SetLeaderInfoFunc(schedManager.LeaderInfo)
+	webHandlers.SetNextRunsFunc(schedManager.NextRuns)
+	webHandlers.SetScheduleLoadFunc(schedManager.ScheduleLoad)
+	webHandlers.SetValidateScheduleFunc(scheduler.ValidateJobSchedule)
 
 	// Static files handling
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("web/static"))))
@@ -140,6 +194,27 @@ func main() {
 	mux.HandleFunc("DELETE /jobs/delete/{id}", webHandlers.DeleteJobHandler)
 
 	mux.HandleFunc("POST /jobs/run/{id}", webHandlers.RunBackupHandler)
+	mux.HandleFunc("POST /jobs/cancel/{id}", webHandlers.CancelJobHandler)
+
+	mux.HandleFunc("GET /jobs/history/{id}", webHandlers.JobHistoryHandler)
+	mux.HandleFunc("GET /jobs/chains", webHandlers.JobChainsHandler)
+	mux.HandleFunc("GET /schedule/load", webHandlers.ScheduleLoadHandler)
+
+	mux.HandleFunc("GET /maintenance", webHandlers.MaintenancePageHandler)
+	mux.HandleFunc("GET /maintenance/banner", webHandlers.MaintenanceBannerHandler)
+	mux.HandleFunc("POST /maintenance/pause", webHandlers.PauseSchedulerHandler)
+	mux.HandleFunc("POST /maintenance/resume", webHandlers.ResumeSchedulerHandler)
+
+	// JSON API
+	mux.HandleFunc("GET /api/jobs", webHandlers.APIJobsHandler)
+	mux.HandleFunc("GET /api/jobs/{id}", webHandlers.APIJobHandler)
+	mux.HandleFunc("GET /api/jobs/{id}/runs", webHandlers.APIJobRunsHandler)
+	mux.HandleFunc("POST /api/jobs/{id}/run", webHandlers.APIRunJobHandler)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", webHandlers.APICancelJobHandler)
+	mux.HandleFunc("GET /api/scheduler", webHandlers.APISchedulerHandler)
+	mux.HandleFunc("GET /api/scheduler/load", webHandlers.APIScheduleLoadHandler)
+	mux.HandleFunc("POST /api/scheduler/pause", webHandlers.APIPauseSchedulerHandler)
+	mux.HandleFunc("POST /api/scheduler/resume", webHandlers.APIResumeSchedulerHandler)
 
 	// sysinfo Handlers
 	mux.HandleFunc("/health", handlers.HealthHandler)
@@ -200,24 +275,58 @@ func main() {
 		//Received system signal
 		log.Printf("Received system signal: %v. Begin gracefull shutdown...", sig)
 
-		//stop scheduller
-		schedManager.Stop()
-
 		//Create context with timeout for operation fnish
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 
-		//Initialize gracefull shutdown
+		//Initialize gracefull shutdown, HTTP server stops first so no new runs are started
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			log.Printf("Graceful shutdown error: %v", err)
-			os.Exit(1)
+		} else {
+			log.Println("Server shutdown gracefully")
+		}
+
+		//stop scheduller, runs which don't finish in time are cancelled and continue after restart
+		if err := schedManager.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Scheduler shutdown error: %v", err)
 		}
-		log.Println("Server shutdown gracefully")
 	}
 
 	log.Println("Application closed.")
 }
 
+// createAdmin creates admin user or gives admin rights to existing one with the new password
+func createAdmin(userRepo *database.UserRepo, args []string, stdin io.Reader) error {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return fmt.Errorf("usage: backup-app create-admin <username>, password is read from stdin")
+	}
+	username := strings.TrimSpace(args[0])
+
+	fmt.Printf("Password for '%s': ", username)
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("can't read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if len(password) < 8 {
+		return fmt.Errorf("password must have at least 8 characters")
+	}
+
+	user, err := userRepo.GetUserByUsername(username)
+	if err != nil {
+		if user, err = userRepo.CreateUser(username, password); err != nil {
+			return err
+		}
+	} else if err := userRepo.UpdateUser(user, password); err != nil {
+		return err
+	}
+	if err := userRepo.SetAdmin(user, true); err != nil {
+		return err
+	}
+	log.Printf("Admin '%s' saved.", username)
+	return nil
+}
+
 type Config struct {
 	ServerPort   int    `yaml:"server_port"`
 	BackupDir    string `yaml:"backup_directory"`
@@ -231,6 +340,22 @@ type Config struct {
 	ShutdownTimeout string `yaml:"shutdown_timeout"`
 
 	LogFilePath string `yaml:"log_file_path"`
+
+	MaxConcurrentRuns     int `yaml:"max_concurrent_runs"`
+	MaxRunsPerDestination int `yaml:"max_runs_per_destination"`
+
+	// Backup window for all jobs, e.g. "22:00" - "06:00", and days without backups
+	BackupWindowStart string   `yaml:"backup_window_start"`
+	BackupWindowEnd   string   `yaml:"backup_window_end"`
+	BlackoutDates     []string `yaml:"blackout_dates"`
+
+	// Instances sharing the database elect one leader which schedules jobs.
+	// Instance ID must be unique and stable across restarts, default is host name and server port.
+	InstanceID     string `yaml:"instance_id"`
+	LeaderLeaseTTL string `yaml:"leader_lease_ttl"`
+
+	// Shell commands which dump sources and preconditions of jobs may run, other commands are refused
+	AllowedCommands []string `yaml:"allowed_commands"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// backup-cli talks to JSON API of running backup-app server
+
+const timeFormat = "2006-01-02 15:04"
+
+type job struct {
+	ID               int           `json:"id"`
+	Name             string        `json:"name"`
+	Kind             string        `json:"kind"`
+	SourceType       string        `json:"source_type"`
+	SourcePath       string        `json:"source_path"`
+	DestinationPaths []string      `json:"destination_paths"`
+	Schedule         string        `json:"schedule"`
+	ScheduleText     string        `json:"schedule_description"`
+	ScheduleAction   string        `json:"schedule_action"`
+	Schedules        []jobSchedule `json:"schedules"`
+	Preconditions    []condition   `json:"preconditions"`
+	TimeZone         string        `json:"time_zone"`
+	IsActive         bool          `json:"is_active"`
+	Priority         int           `json:"priority"`
+	LastRunStatus    string        `json:"last_run_status"`
+	LastRunTime      *time.Time    `json:"last_run_time"`
+	Manual           bool          `json:"manual"`
+	NextRuns         []time.Time   `json:"next_runs"`
+	ScheduleError    string        `json:"schedule_error"`
+}
+
+type condition struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type jobSchedule struct {
+	Action       string `json:"action"`
+	Schedule     string `json:"schedule"`
+	ScheduleText string `json:"schedule_description"`
+}
+
+type run struct {
+	ID          int       `json:"id"`
+	StartTime   time.Time `json:"start_time"`
+	Status      string    `json:"status"`
+	Message     string    `json:"message"`
+	Trigger     string    `json:"trigger"`
+	Action      string    `json:"action"`
+	FilesCount  int       `json:"files_count"`
+	BytesCount  int64     `json:"bytes_count"`
+	Attempt     int       `json:"attempt"`
+	ParentRunID struct {
+		Int64 int64 `json:"Int64"`
+		Valid bool  `json:"Valid"`
+	} `json:"parent_run_id"`
+}
+
+type client struct {
+	server   string
+	user     string
+	password string
+	http     *http.Client
+}
+
+func main() {
+	defaultServer := os.Getenv("BACKUP_SERVER")
+	if defaultServer == "" {
+		defaultServer = "http://localhost:8080"
+	}
+	server := flag.String("server", defaultServer, "address of backup-app server (env BACKUP_SERVER)")
+	user := flag.String("user", os.Getenv("BACKUP_USER"), "admin user, password is read from env BACKUP_PASSWORD (env BACKUP_USER)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	c := &client{server: *server, user: *user, password: os.Getenv("BACKUP_PASSWORD"), http: &http.Client{Timeout: 30 * time.Second}}
+	args := flag.Args()[1:]
+
+	var err error
+	switch flag.Arg(0) {
+	case "jobs":
+		err = c.listJobs()
+	case "job":
+		err = c.showJob(args)
+	case "runs":
+		err = c.listRuns(args)
+	case "run":
+		err = c.runJob(args)
+	case "cancel":
+		err = c.cancelJob(args)
+	case "pause":
+		err = c.pauseScheduler(args)
+	case "resume":
+		err = c.resumeScheduler()
+	case "load":
+		err = c.showLoad(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: backup-cli [-server URL] [-user NAME] <command> [arguments]
+
+Commands:
+  jobs                          list jobs with last and next run
+  job <id> [-next N]            show job and its next N run times
+  runs <id> [-limit N] [-status S] [-trigger T]
+                                show run history of the job
+  run <id>                      start the job now
+  cancel <id>                   stop the working run of the job
+  pause [-until T | -for D] [-reason R]
+                                skip scheduled runs of all jobs until T (YYYY-MM-DD HH:MM),
+                                for duration D or until resume
+  resume                        resume scheduled runs
+  load [-hours N]               show runs of different jobs writing to the same storage
+                                at the same time in the next N hours and suggested start times
+
+pause, resume and run during maintenance require admin: -user NAME (env BACKUP_USER)
+and password in env BACKUP_PASSWORD. Admin is added with "backup-app create-admin NAME".
+`)
+}
+
+func (c *client) listJobs() error {
+	var jobs []job
+	if err := c.get("/api/jobs", &jobs); err != nil {
+		return err
+	}
+	var state schedulerState
+	if err := c.get("/api/scheduler", &state); err == nil && state.Pause != nil {
+		fmt.Printf("Scheduler is paused since %s", state.Pause.Since.Local().Format(timeFormat))
+		if !state.Pause.Until.IsZero() {
+			fmt.Printf(" until %s", state.Pause.Until.Local().Format(timeFormat))
+		}
+		if state.Pause.Reason != "" {
+			fmt.Printf(": %s", state.Pause.Reason)
+		}
+		fmt.Print("\n\n")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCHEDULE\tACTIVE\tLAST RUN\tSTATUS\tNEXT RUN")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\t%s\t%s\n", j.ID, j.Name, scheduleText(j), j.IsActive,
+			formatTime(j.LastRunTime), valueOr(j.LastRunStatus, "-"), nextRunText(j))
+	}
+	return w.Flush()
+}
+
+func (c *client) showJob(args []string) error {
+	fs := flag.NewFlagSet("job", flag.ExitOnError)
+	next := fs.Int("next", 5, "how many next run times to show")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var j job
+	if err := c.get(fmt.Sprintf("/api/jobs/%d?next=%d", id, *next), &j); err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:            %d\n", j.ID)
+	fmt.Printf("Name:          %s\n", j.Name)
+	fmt.Printf("Kind:          %s (%s)\n", j.Kind, j.SourceType)
+	fmt.Printf("Source:        %s\n", j.SourcePath)
+	for i, d := range j.DestinationPaths {
+		if i == 0 {
+			fmt.Printf("Destinations:  %s\n", d)
+		} else {
+			fmt.Printf("               %s\n", d)
+		}
+	}
+	if j.ScheduleText == "" || j.ScheduleText == j.Schedule {
+		fmt.Printf("Schedule:      %s, %s\n", j.Schedule, valueOr(j.ScheduleAction, "full"))
+	} else {
+		fmt.Printf("Schedule:      %s (%s), %s\n", j.ScheduleText, j.Schedule, valueOr(j.ScheduleAction, "full"))
+	}
+	for _, s := range j.Schedules {
+		fmt.Printf("               %s (%s), %s\n", valueOr(s.ScheduleText, s.Schedule), s.Schedule, s.Action)
+	}
+	for i, p := range j.Preconditions {
+		if i == 0 {
+			fmt.Printf("Preconditions: %s %s\n", p.Type, p.Value)
+		} else {
+			fmt.Printf("               %s %s\n", p.Type, p.Value)
+		}
+	}
+	fmt.Printf("Time zone:     %s\n", valueOr(j.TimeZone, "server local"))
+	fmt.Printf("Active:        %t\n", j.IsActive)
+	fmt.Printf("Priority:      %d\n", j.Priority)
+	fmt.Printf("Last run:      %s %s\n", formatTime(j.LastRunTime), j.LastRunStatus)
+	switch {
+	case j.ScheduleError != "":
+		fmt.Printf("Next runs:     schedule error: %s\n", j.ScheduleError)
+	case j.Manual:
+		fmt.Printf("Next runs:     manual\n")
+	default:
+		// Next runs are shown in time zone of the job
+		for i, t := range j.NextRuns {
+			if i == 0 {
+				fmt.Printf("Next runs:     %s\n", t.Format(timeFormat+" -0700"))
+			} else {
+				fmt.Printf("               %s\n", t.Format(timeFormat+" -0700"))
+			}
+		}
+	}
+	return nil
+}
+
+func (c *client) listRuns(args []string) error {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	limit := fs.Int("limit", 20, "how many runs to show")
+	status := fs.String("status", "", "show only runs with this status")
+	trigger := fs.String("trigger", "", "show only runs started by cron, manual, api, retry, catchup, dependency, resume or watch")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(*limit))
+	if *status != "" {
+		q.Set("status", *status)
+	}
+	if *trigger != "" {
+		q.Set("trigger", *trigger)
+	}
+
+	var resp struct {
+		Total int   `json:"total"`
+		Runs  []run `json:"runs"`
+	}
+	if err := c.get(fmt.Sprintf("/api/jobs/%d/runs?%s", id, q.Encode()), &resp); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tTRIGGER\tACTION\tATTEMPT\tSTATUS\tFILES\tBYTES\tMESSAGE")
+	for _, r := range resp.Runs {
+		attempt := strconv.Itoa(r.Attempt)
+		if r.ParentRunID.Valid {
+			attempt = fmt.Sprintf("%d (of %d)", r.Attempt, r.ParentRunID.Int64)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", r.ID, r.StartTime.Local().Format(timeFormat),
+			r.Trigger, valueOr(r.Action, "full"), attempt, r.Status, r.FilesCount, r.BytesCount, r.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Shown %d of %d runs\n", len(resp.Runs), resp.Total)
+	return nil
+}
+
+func (c *client) runJob(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := c.do(http.MethodPost, fmt.Sprintf("/api/jobs/%d/run", id), &resp); err != nil {
+		return err
+	}
+	fmt.Printf("Job %d %s\n", id, resp.Status)
+	return nil
+}
+
+func (c *client) cancelJob(args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := c.do(http.MethodPost, fmt.Sprintf("/api/jobs/%d/cancel", id), &resp); err != nil {
+		return err
+	}
+	fmt.Printf("Job %d %s\n", id, resp.Status)
+	return nil
+}
+
+// schedulerState is maintenance mode of the scheduler
+type schedulerState struct {
+	Paused bool `json:"paused"`
+	Pause  *struct {
+		Since  time.Time `json:"since"`
+		Until  time.Time `json:"until"`
+		Reason string    `json:"reason"`
+	} `json:"pause"`
+}
+
+func (c *client) pauseScheduler(args []string) error {
+	fs := flag.NewFlagSet("pause", flag.ExitOnError)
+	until := fs.String("until", "", "end of the pause, YYYY-MM-DD HH:MM in server time zone")
+	duration := fs.String("for", "", "duration of the pause, e.g. 4h")
+	reason := fs.String("reason", "", "why the scheduler is paused")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	if *until != "" {
+		q.Set("until", *until)
+	}
+	if *duration != "" {
+		q.Set("for", *duration)
+	}
+	if *reason != "" {
+		q.Set("reason", *reason)
+	}
+	var state schedulerState
+	if err := c.do(http.MethodPost, "/api/scheduler/pause?"+q.Encode(), &state); err != nil {
+		return err
+	}
+	if state.Pause != nil && !state.Pause.Until.IsZero() {
+		fmt.Printf("Scheduler paused until %s\n", state.Pause.Until.Local().Format(timeFormat))
+	} else {
+		fmt.Println("Scheduler paused until resume")
+	}
+	return nil
+}
+
+func (c *client) resumeScheduler() error {
+	var state schedulerState
+	if err := c.do(http.MethodPost, "/api/scheduler/resume", &state); err != nil {
+		return err
+	}
+	fmt.Println("Scheduler resumed")
+	return nil
+}
+
+// plannedRun is a scheduled run in the schedule load
+type plannedRun struct {
+	JobName string    `json:"job_name"`
+	Action  string    `json:"action"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+type scheduleLoad struct {
+	Runs      []plannedRun `json:"runs"`
+	Conflicts []struct {
+		Storage string       `json:"storage"`
+		Start   time.Time    `json:"start"`
+		End     time.Time    `json:"end"`
+		Runs    []plannedRun `json:"runs"`
+	} `json:"conflicts"`
+	Suggestions []struct {
+		JobName   string    `json:"job_name"`
+		Action    string    `json:"action"`
+		Schedule  string    `json:"schedule"`
+		Current   time.Time `json:"current"`
+		Suggested time.Time `json:"suggested"`
+		NewSpec   string    `json:"new_spec"`
+		Note      string    `json:"note"`
+	} `json:"suggestions"`
+}
+
+func (c *client) showLoad(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	hours := fs.Int("hours", 24, "length of the period from now in hours, up to 168")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var load scheduleLoad
+	if err := c.get(fmt.Sprintf("/api/scheduler/load?hours=%d", *hours), &load); err != nil {
+		return err
+	}
+
+	fmt.Printf("Scheduled runs in the next %d hours: %d\n", *hours, len(load.Runs))
+	if len(load.Conflicts) == 0 {
+		fmt.Println("No conflicts")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STORAGE\tFROM\tTO\tRUNS")
+	for _, conflict := range load.Conflicts {
+		var runs []string
+		for _, r := range conflict.Runs {
+			runs = append(runs, fmt.Sprintf("%s (%s %s-%s)", r.JobName, r.Action, r.Start.Local().Format("15:04"), r.End.Local().Format("15:04")))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", conflict.Storage, conflict.Start.Local().Format(timeFormat),
+			conflict.End.Local().Format(timeFormat), strings.Join(runs, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("\nSuggested start times:")
+	for _, s := range load.Suggestions {
+		change := "new schedule " + s.NewSpec
+		if s.NewSpec == "" {
+			change = "change schedule by hand: " + s.Note
+		}
+		fmt.Printf("  %s (%s, %s): %s -> %s, %s\n", s.JobName, s.Action, s.Schedule,
+			s.Current.Local().Format(timeFormat), s.Suggested.Local().Format("15:04"), change)
+	}
+	return nil
+}
+
+// parseID reads job ID which goes before the command flags
+func parseID(fs *flag.FlagSet, args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("job ID is required")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("wrong job ID '%s'", args[0])
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *client) get(path string, v any) error {
+	return c.do(http.MethodGet, path, v)
+}
+
+func (c *client) do(method, path string, v any) error {
+	req, err := http.NewRequest(method, c.server+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("can't connect to server %s: %w", c.server, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s", apiErr.Error)
+		}
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("wrong response from server: %w", err)
+	}
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(timeFormat)
+}
+
+func nextRunText(j job) string {
+	switch {
+	case j.ScheduleError != "":
+		return "schedule error"
+	case j.Manual:
+		return "manual"
+	case len(j.NextRuns) == 0:
+		return "-"
+	}
+	return j.NextRuns[0].Local().Format(timeFormat)
+}
+
+// scheduleText is the main schedule of the job with number of additional schedules
+func scheduleText(j job) string {
+	text := valueOr(j.ScheduleText, j.Schedule)
+	if len(j.Schedules) > 0 {
+		text += fmt.Sprintf(" +%d", len(j.Schedules))
+	}
+	return text
+}
+
+func valueOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
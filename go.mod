@@ -9,3 +9,7 @@ require github.com/mattn/go-sqlite3 v1.14.28
 require golang.org/x/crypto v0.38.0
 
 require github.com/robfig/cron/v3 v3.0.1
+
+require github.com/fsnotify/fsnotify v1.9.0
+
+require golang.org/x/sys v0.33.0 // indirect
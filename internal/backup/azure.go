@@ -0,0 +1,439 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	azureScheme     = "azure://"
+	azureAPIVersion = "2020-10-02"
+
+	// Files bigger than one block are uploaded as staged blocks + block list
+	azureBlockSize = 4 * 1024 * 1024
+)
+
+// AzureBlobDestination writes backup files as block blobs into Azure Blob Storage container.
+//
+// Destination format:
+//
+//	azure://<account>/<container>[/<prefix>]?key=env:<VARIABLE>|sas=env:<VARIABLE>&tier=Hot|Cool|Cold|Archive&endpoint=<url>
+//
+// Credentials are never written in the destination itself, key and sas name environment variables which hold them.
+// When neither key nor sas are set, AZURE_STORAGE_KEY and AZURE_STORAGE_SAS_TOKEN environment variables are used.
+// endpoint allows to use Azurite or another compatible service, e.g. http://127.0.0.1:10000/devstoreaccount1
+type AzureBlobDestination struct {
+	Account    string
+	Container  string
+	Prefix     string
+	AccessTier string
+	Endpoint   string
+
+	accountKey []byte
+	sasToken   url.Values
+	client     *http.Client
+}
+
+func NewAzureBlobDestination(spec string) (*AzureBlobDestination, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		// Error of url.Parse repeats the destination, which can contain credentials
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("wrong azure destination: %w", err)
+	}
+
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if u.Host == "" || parts[0] == "" {
+		return nil, fmt.Errorf("azure destination should look like azure://<account>/<container>/<prefix>")
+	}
+
+	d := &AzureBlobDestination{
+		Account:    u.Host,
+		Container:  parts[0],
+		AccessTier: u.Query().Get("tier"),
+		Endpoint:   strings.TrimRight(u.Query().Get("endpoint"), "/"),
+		client:     &http.Client{},
+	}
+	if len(parts) > 1 {
+		d.Prefix = strings.Trim(parts[1], "/")
+	}
+	if d.Endpoint == "" {
+		d.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", d.Account)
+	}
+
+	switch d.AccessTier {
+	case "", "Hot", "Cool", "Cold", "Archive":
+	default:
+		return nil, fmt.Errorf("unknown azure access tier '%s'", d.AccessTier)
+	}
+
+	key, err := azureCredential(u.Query(), "key")
+	if err != nil {
+		return nil, err
+	}
+	sas, err := azureCredential(u.Query(), "sas")
+	if err != nil {
+		return nil, err
+	}
+	if key == "" && sas == "" {
+		key = os.Getenv("AZURE_STORAGE_KEY")
+		sas = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+
+	switch {
+	case key != "":
+		d.accountKey, err = base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("azure shared key is not valid base64: %w", err)
+		}
+	case sas != "":
+		d.sasToken, err = url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return nil, fmt.Errorf("wrong azure SAS token: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("azure destination requires shared key or SAS token")
+	}
+
+	return d, nil
+}
+
+// azureCredential reads shared key or SAS token from the environment variable named in the destination
+func azureCredential(query url.Values, name string) (string, error) {
+	value := query.Get(name)
+	if value == "" {
+		return "", nil
+	}
+	variable, ok := strings.CutPrefix(value, "env:")
+	if !ok || variable == "" {
+		return "", fmt.Errorf("azure %s must not be written in destination, put it into environment variable and use %s=env:<VARIABLE>", name, name)
+	}
+	credential := os.Getenv(variable)
+	if credential == "" {
+		return "", fmt.Errorf("environment variable %s with azure %s is not set", variable, name)
+	}
+	return credential, nil
+}
+
+func (d *AzureBlobDestination) String() string {
+	return fmt.Sprintf("%s%s/%s/%s", azureScheme, d.Account, d.Container, d.Prefix)
+}
+
+// Prepare creates container if it does not exist yet.
+// SAS token scoped to the container is not allowed to create it, then the container is expected to exist
+// and wrong permissions are reported by the first upload.
+func (d *AzureBlobDestination) Prepare(ctx context.Context) error {
+	resp, err := d.do(ctx, http.MethodPut, d.containerURL(url.Values{"restype": {"container"}}), nil, nil)
+	if err != nil {
+		return fmt.Errorf("error creating container '%s': %w", d.Container, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode/100 == 2 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusForbidden && d.accountKey == nil {
+		switch resp.Header.Get("x-ms-error-code") {
+		case "AuthorizationFailure", "AuthorizationPermissionMismatch", "AuthorizationResourceTypeMismatch":
+			return nil
+		}
+	}
+	return azureError(resp, "create container")
+}
+
+func (d *AzureBlobDestination) WriteFile(ctx context.Context, relPath string, r io.Reader, size int64, modTime time.Time) error {
+	blobURL := d.blobURL(relPath, nil)
+
+	// MD5 of the whole file is stored in blob properties, ContentMD5 returns it for verification
+	hash := md5.New()
+	r = io.TeeReader(r, hash)
+
+	// Small files are uploaded in one request
+	buf := make([]byte, azureBlockSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return d.putBlob(ctx, blobURL, buf[:n], hash.Sum(nil))
+	}
+	if err != nil {
+		return fmt.Errorf("error reading data for blob '%s': %w", relPath, err)
+	}
+
+	var blockIDs []string
+	for {
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", len(blockIDs))))
+		if err := d.putBlock(ctx, relPath, blockID, buf[:n]); err != nil {
+			return err
+		}
+		blockIDs = append(blockIDs, blockID)
+
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("error reading data for blob '%s': %w", relPath, err)
+		}
+	}
+
+	return d.putBlockList(ctx, relPath, blockIDs, hash.Sum(nil))
+}
+
+// ContentMD5 returns hex encoded MD5 which was stored in blob properties on upload.
+// Blob properties can be read in every access tier, so archived blobs are verified without rehydration.
+func (d *AzureBlobDestination) ContentMD5(ctx context.Context, relPath string) (string, error) {
+	resp, err := d.do(ctx, http.MethodHead, d.blobURL(relPath, nil), nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("error reading properties of blob '%s': %w", relPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", azureError(resp, "get blob properties")
+	}
+	sum, err := base64.StdEncoding.DecodeString(resp.Header.Get("Content-MD5"))
+	if err != nil || len(sum) != md5.Size {
+		return "", fmt.Errorf("blob '%s' has no valid MD5 in its properties", relPath)
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+func (d *AzureBlobDestination) Remove(ctx context.Context, relPath string) error {
+	resp, err := d.do(ctx, http.MethodDelete, d.blobURL(relPath, nil), nil, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting blob '%s': %w", relPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return azureError(resp, "delete blob")
+	}
+	return nil
+}
+
+func (d *AzureBlobDestination) putBlob(ctx context.Context, blobURL string, data, contentMD5 []byte) error {
+	headers := map[string]string{
+		"x-ms-blob-type":        "BlockBlob",
+		"x-ms-blob-content-md5": base64.StdEncoding.EncodeToString(contentMD5),
+	}
+	if d.AccessTier != "" {
+		headers["x-ms-access-tier"] = d.AccessTier
+	}
+
+	resp, err := d.do(ctx, http.MethodPut, blobURL, headers, data)
+	if err != nil {
+		return fmt.Errorf("error uploading blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return azureError(resp, "put blob")
+	}
+	return nil
+}
+
+func (d *AzureBlobDestination) putBlock(ctx context.Context, relPath, blockID string, data []byte) error {
+	blockURL := d.blobURL(relPath, url.Values{"comp": {"block"}, "blockid": {blockID}})
+
+	resp, err := d.do(ctx, http.MethodPut, blockURL, nil, data)
+	if err != nil {
+		return fmt.Errorf("error uploading block for '%s': %w", relPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return azureError(resp, "put block")
+	}
+	return nil
+}
+
+func (d *AzureBlobDestination) putBlockList(ctx context.Context, relPath string, blockIDs []string, contentMD5 []byte) error {
+	list := struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: blockIDs}
+
+	body, err := xml.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("error building block list for '%s': %w", relPath, err)
+	}
+	body = append([]byte(xml.Header), body...)
+
+	headers := map[string]string{
+		"Content-Type":          "application/xml",
+		"x-ms-blob-content-md5": base64.StdEncoding.EncodeToString(contentMD5),
+	}
+	if d.AccessTier != "" {
+		headers["x-ms-access-tier"] = d.AccessTier
+	}
+
+	resp, err := d.do(ctx, http.MethodPut, d.blobURL(relPath, url.Values{"comp": {"blocklist"}}), headers, body)
+	if err != nil {
+		return fmt.Errorf("error commiting block list for '%s': %w", relPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return azureError(resp, "put block list")
+	}
+	return nil
+}
+
+func (d *AzureBlobDestination) containerURL(query url.Values) string {
+	return d.withQuery(d.Endpoint+"/"+url.PathEscape(d.Container), query)
+}
+
+func (d *AzureBlobDestination) blobURL(relPath string, query url.Values) string {
+	name := path.Join(d.Prefix, relPath)
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return d.withQuery(d.Endpoint+"/"+url.PathEscape(d.Container)+"/"+strings.Join(segments, "/"), query)
+}
+
+func (d *AzureBlobDestination) withQuery(rawURL string, query url.Values) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	for k, v := range d.sasToken {
+		q[k] = v
+	}
+	if len(q) == 0 {
+		return rawURL
+	}
+	return rawURL + "?" + q.Encode()
+}
+
+func (d *AzureBlobDestination) do(ctx context.Context, method, rawURL string, headers map[string]string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if len(body) > 0 {
+		// Service checks transactional MD5, so corrupted uploads are rejected
+		sum := md5.Sum(body)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	if d.accountKey != nil {
+		req.Header.Set("Authorization", "SharedKey "+d.Account+":"+d.sign(req))
+	}
+
+	resp, err := d.client.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		// URL of the request contains SAS token
+		urlErr.URL = strings.SplitN(urlErr.URL, "?", 2)[0]
+	}
+	return resp, err
+}
+
+// sign builds Shared Key signature for the request
+// https://learn.microsoft.com/rest/api/storageservices/authorize-with-shared-key
+func (d *AzureBlobDestination) sign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = fmt.Sprintf("%d", req.ContentLength)
+	}
+
+	var msHeaders []string
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-ms-") {
+			msHeaders = append(msHeaders, lk)
+		}
+	}
+	sort.Strings(msHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, h := range msHeaders {
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(req.Header.Get(h)) + "\n")
+	}
+
+	var canonicalResource strings.Builder
+	canonicalResource.WriteString("/" + d.Account + req.URL.EscapedPath())
+	query := map[string][]string{}
+	var keys []string
+	for k, v := range req.URL.Query() {
+		lk := strings.ToLower(k)
+		if _, ok := query[lk]; !ok {
+			keys = append(keys, lk)
+		}
+		query[lk] = append(query[lk], v...)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		canonicalResource.WriteString("\n" + k + ":" + strings.Join(values, ","))
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}, "\n") + "\n" + canonicalHeaders.String() + canonicalResource.String()
+
+	mac := hmac.New(sha256.New, d.accountKey)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// AzureError is failed response of Azure Blob Storage
+type AzureError struct {
+	Operation  string
+	StatusCode int
+	Code       string // x-ms-error-code, or HTTP status when the service did not send it
+	Message    string
+}
+
+func (e *AzureError) Error() string {
+	return fmt.Sprintf("azure %s failed (%s): %s", e.Operation, e.Code, e.Message)
+}
+
+// Temporary tells if the request can pass when it is repeated later
+func (e *AzureError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func azureError(resp *http.Response, operation string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	code := resp.Header.Get("x-ms-error-code")
+	if code == "" {
+		code = resp.Status
+	}
+	return &AzureError{Operation: operation, StatusCode: resp.StatusCode, Code: code, Message: strings.TrimSpace(string(body))}
+}
@@ -0,0 +1,362 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAzureAccount = "devstoreaccount1"
+
+var testAzureKey = []byte("test shared key of the storage account")
+
+// fakeBlobService is a small stand-in for Azurite, it checks authorization and keeps uploaded blobs in memory
+type fakeBlobService struct {
+	t   *testing.T
+	sas bool
+
+	mu         sync.Mutex
+	containers map[string]bool
+	blobs      map[string][]byte
+	blocks     map[string][]byte
+	tiers      map[string]string
+	md5s       map[string]string
+	blockLists map[string][]string
+	requests   []string
+}
+
+func newFakeBlobService(t *testing.T, sas bool) (*fakeBlobService, *httptest.Server) {
+	s := &fakeBlobService{
+		t:          t,
+		sas:        sas,
+		containers: map[string]bool{},
+		blobs:      map[string][]byte{},
+		blocks:     map[string][]byte{},
+		tiers:      map[string]string{},
+		md5s:       map[string]string{},
+		blockLists: map[string][]string{},
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func (s *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "InvalidInput")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path+" "+query.Get("comp")+query.Get("restype"))
+
+	if s.sas {
+		if query.Get("sig") == "" || r.Header.Get("Authorization") != "" {
+			s.fail(w, http.StatusForbidden, "AuthenticationFailed")
+			return
+		}
+		// Token is scoped to the container, so the container can't be created with it
+		if query.Get("restype") == "container" {
+			s.fail(w, http.StatusForbidden, "AuthorizationFailure")
+			return
+		}
+	} else if got, want := r.Header.Get("Authorization"), "SharedKey "+testAzureAccount+":"+expectedSharedKey(r); got != want {
+		s.t.Errorf("%s %s: Authorization = %q, want %q", r.Method, r.URL, got, want)
+		s.fail(w, http.StatusForbidden, "AuthenticationFailed")
+		return
+	}
+
+	if md5Header := r.Header.Get("Content-MD5"); md5Header != "" {
+		sum := md5.Sum(body)
+		if md5Header != base64.StdEncoding.EncodeToString(sum[:]) {
+			s.fail(w, http.StatusBadRequest, "Md5Mismatch")
+			return
+		}
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodDelete:
+		if _, ok := s.blobs[name]; !ok {
+			s.fail(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(s.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case r.Method == http.MethodHead:
+		if _, ok := s.blobs[name]; !ok {
+			s.fail(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		if sum := s.md5s[name]; sum != "" {
+			w.Header().Set("Content-MD5", sum)
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	case query.Get("restype") == "container":
+		if s.containers[name] {
+			s.fail(w, http.StatusConflict, "ContainerAlreadyExists")
+			return
+		}
+		s.containers[name] = true
+	case query.Get("comp") == "block":
+		s.blocks[name+"#"+query.Get("blockid")] = body
+	case query.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			s.fail(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		var data []byte
+		for _, id := range list.Latest {
+			block, ok := s.blocks[name+"#"+id]
+			if !ok {
+				s.fail(w, http.StatusBadRequest, "InvalidBlockList")
+				return
+			}
+			data = append(data, block...)
+		}
+		s.blobs[name] = data
+		s.blockLists[name] = list.Latest
+		s.tiers[name] = r.Header.Get("x-ms-access-tier")
+		s.md5s[name] = r.Header.Get("x-ms-blob-content-md5")
+	default:
+		if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+			s.fail(w, http.StatusBadRequest, "InvalidBlobType")
+			return
+		}
+		s.blobs[name] = body
+		s.tiers[name] = r.Header.Get("x-ms-access-tier")
+		s.md5s[name] = r.Header.Get("x-ms-blob-content-md5")
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *fakeBlobService) fail(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code></Error>", code)
+}
+
+// expectedSharedKey signs the request as it is described in the Shared Key documentation
+func expectedSharedKey(r *http.Request) string {
+	contentLength := ""
+	if r.ContentLength > 0 {
+		contentLength = fmt.Sprint(r.ContentLength)
+	}
+
+	var headers []string
+	for k := range r.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			headers = append(headers, k+":"+r.Header.Get(k)+"\n")
+		}
+	}
+	sort.Strings(headers)
+
+	resource := "/" + testAzureAccount + r.URL.EscapedPath()
+	query := r.URL.Query()
+	var names []string
+	for k := range query {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		resource += "\n" + k + ":" + strings.Join(query[k], ",")
+	}
+
+	stringToSign := r.Method + "\n\n\n" + contentLength + "\n" + r.Header.Get("Content-MD5") + "\n" +
+		r.Header.Get("Content-Type") + "\n\n\n\n\n\n\n" + strings.Join(headers, "") + resource
+
+	mac := hmac.New(sha256.New, testAzureKey)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAzureBlobDestinationSharedKey(t *testing.T) {
+	service, srv := newFakeBlobService(t, false)
+	t.Setenv("TEST_AZURE_KEY", base64.StdEncoding.EncodeToString(testAzureKey))
+
+	dest, err := NewAzureBlobDestination("azure://" + testAzureAccount + "/backups/nightly/db/?key=env:TEST_AZURE_KEY&tier=Cool&endpoint=" + url.QueryEscape(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		// Second Prepare finds existing container
+		if err := dest.Prepare(ctx); err != nil {
+			t.Fatalf("Prepare: %v", err)
+		}
+	}
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), (2*azureBlockSize+1000)/16)
+	files := map[string][]byte{
+		"small.txt":          []byte("small file"),
+		"dir with space/big": large,
+		"empty":              {},
+	}
+	for name, data := range files {
+		if err := dest.WriteFile(ctx, name, bytes.NewReader(data), int64(len(data)), time.Now()); err != nil {
+			t.Fatalf("WriteFile(%s): %v", name, err)
+		}
+	}
+
+	for name, data := range files {
+		blob := "backups/nightly/db/" + name
+		got, ok := service.blobs[blob]
+		if !ok {
+			t.Errorf("blob %q is not uploaded, requests: %v", blob, service.requests)
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("blob %q has %d bytes, want %d", blob, len(got), len(data))
+		}
+		if service.tiers[blob] != "Cool" {
+			t.Errorf("blob %q access tier = %q, want Cool", blob, service.tiers[blob])
+		}
+	}
+
+	if blocks := len(service.blockLists["backups/nightly/db/dir with space/big"]); blocks != 3 {
+		t.Errorf("big file uploaded in %d blocks, want 3", blocks)
+	}
+	if _, ok := service.blockLists["backups/nightly/db/small.txt"]; ok {
+		t.Errorf("small file should be uploaded with one Put Blob request")
+	}
+
+	for i := 0; i < 2; i++ {
+		// Second Remove finds no blob
+		if err := dest.Remove(ctx, "small.txt"); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+	}
+	if _, ok := service.blobs["backups/nightly/db/small.txt"]; ok {
+		t.Errorf("removed blob is left in container")
+	}
+}
+
+func TestAzureBlobDestinationContainerSAS(t *testing.T) {
+	service, srv := newFakeBlobService(t, true)
+	t.Setenv("TEST_AZURE_SAS", "?sv=2020-10-02&sr=c&sp=cw&sig=c2lnbmF0dXJl")
+
+	dest, err := NewAzureBlobDestination("azure://" + testAzureAccount + "/backups?sas=env:TEST_AZURE_SAS&endpoint=" + url.QueryEscape(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := dest.Prepare(ctx); err != nil {
+		t.Fatalf("Prepare with container SAS: %v", err)
+	}
+	if err := dest.WriteFile(ctx, "a/b.txt", strings.NewReader("data"), 4, time.Now()); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if got := string(service.blobs["backups/a/b.txt"]); got != "data" {
+		t.Errorf("blob content = %q, want %q", got, "data")
+	}
+	if tier := service.tiers["backups/a/b.txt"]; tier != "" {
+		t.Errorf("access tier %q is sent without tier in destination", tier)
+	}
+}
+
+func TestAzureBlobDestinationVerify(t *testing.T) {
+	service, srv := newFakeBlobService(t, false)
+	t.Setenv("TEST_AZURE_KEY", base64.StdEncoding.EncodeToString(testAzureKey))
+	spec := "azure://" + testAzureAccount + "/backups?key=env:TEST_AZURE_KEY&tier=Archive&endpoint=" + url.QueryEscape(srv.URL)
+
+	src := t.TempDir()
+	files := map[string][]byte{
+		"a.txt":   []byte("first file"),
+		"big.bin": bytes.Repeat([]byte("x"), azureBlockSize+10),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(src, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := PerformBackup(context.Background(), 1, src, []string{spec}, BackupOptions{Verify: true})
+	if result.Status != "Success" {
+		t.Fatalf("backup with verification: %s %s", result.Status, result.Message)
+	}
+	if result := VerifyBackup(context.Background(), 1, src, []string{spec}); result.Status != "Success" {
+		t.Fatalf("verify: %s %s", result.Status, result.Message)
+	}
+
+	service.mu.Lock()
+	service.blobs["backups/a.txt"] = []byte("changed")
+	service.md5s["backups/a.txt"] = base64.StdEncoding.EncodeToString(md5.New().Sum(nil))
+	delete(service.blobs, "backups/big.bin")
+	service.mu.Unlock()
+
+	result = VerifyBackup(context.Background(), 1, src, []string{spec})
+	if result.Status != "Error" {
+		t.Fatalf("verify of changed blobs: %s %s", result.Status, result.Message)
+	}
+	for _, want := range []string{"'a.txt' differs", "'big.bin' is missing"} {
+		if !strings.Contains(result.Message, want) {
+			t.Errorf("verify message %q does not contain %q", result.Message, want)
+		}
+	}
+}
+
+func TestNewAzureBlobDestination(t *testing.T) {
+	t.Setenv("TEST_AZURE_KEY", base64.StdEncoding.EncodeToString(testAzureKey))
+	t.Setenv("AZURE_STORAGE_KEY", "")
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "")
+
+	tests := []struct {
+		spec    string
+		prefix  string
+		wantErr string
+	}{
+		{spec: "azure://acct/backups?key=env:TEST_AZURE_KEY", prefix: ""},
+		{spec: "azure://acct/backups/a/b/?key=env:TEST_AZURE_KEY", prefix: "a/b"},
+		{spec: "azure://acct/backups?key=c2VjcmV0", wantErr: "must not be written in destination"},
+		{spec: "azure://acct/backups?sas=sv%3D1%26sig%3Dx", wantErr: "must not be written in destination"},
+		{spec: "azure://acct/backups?key=env:NOT_SET_AZURE_KEY", wantErr: "is not set"},
+		{spec: "azure://acct/backups", wantErr: "requires shared key or SAS token"},
+		{spec: "azure://acct/backups?key=env:TEST_AZURE_KEY&tier=Frozen", wantErr: "unknown azure access tier"},
+		{spec: "azure://acct?key=env:TEST_AZURE_KEY", wantErr: "should look like"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			dest, err := NewAzureBlobDestination(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dest.Prefix != tt.prefix {
+				t.Errorf("prefix = %q, want %q", dest.Prefix, tt.prefix)
+			}
+			if strings.Contains(dest.String(), "key") {
+				t.Errorf("String() = %q shows credentials", dest.String())
+			}
+		})
+	}
+}
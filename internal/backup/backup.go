@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -9,15 +10,37 @@ import (
 	"time"
 )
 
+const (
+	StatusCancelled = "Cancelled"
+	StatusTimedOut  = "Timed out" // run was stopped by maximum run time or end of backup window
+	StatusPaused    = "Paused"    // run was stopped by end of backup window and continues in the next one
+)
+
 type BackupResult struct {
-	JobID    int
-	Status   string
-	Message  string
-	Duration time.Duration
-	Time     time.Time
+	JobID        int
+	Status       string
+	Message      string
+	Duration     time.Duration
+	Time         time.Time
+	Destinations []DestinationResult
+	// Files and Bytes are counted from the source, equal to the best destination of the run
+	Files int
+	Bytes int64
+	// Err stopped the whole run, failures of single destinations are in Destinations
+	Err error
+}
+
+// DestinationResult is outcome of the run for one of the job destinations
+type DestinationResult struct {
+	Destination string
+	Status      string
+	Message     string
+	Files       int
+	Bytes       int64
+	Err         error
 }
 
-func PerformLocalBackup(jobID int, sourcePath, destinationPath string) BackupResult {
+func PerformLocalBackup(ctx context.Context, jobID int, sourcePath, destinationPath string) BackupResult {
 	startTime := time.Now()
 	result := BackupResult{
 		JobID: jobID,
@@ -30,6 +53,7 @@ func PerformLocalBackup(jobID int, sourcePath, destinationPath string) BackupRes
 	srcInfo, err := os.Stat(sourcePath)
 	if os.IsNotExist(err) {
 		result.Status = "Error"
+		result.Err = sourceError(err)
 		result.Message = fmt.Sprintf("Source '%s' not exist: %v", sourcePath, err)
 		log.Printf("Backup error for job ID %d: %s", jobID, result.Message)
 		result.Duration = time.Since(startTime)
@@ -37,6 +61,7 @@ func PerformLocalBackup(jobID int, sourcePath, destinationPath string) BackupRes
 	}
 	if err != nil {
 		result.Status = "Error"
+		result.Err = sourceError(err)
 		result.Message = fmt.Sprintf("Access to source error '%s': %v", sourcePath, err)
 		log.Printf("Backup error for job ID %d: %s", jobID, result.Message)
 		result.Duration = time.Since(startTime)
@@ -47,6 +72,7 @@ func PerformLocalBackup(jobID int, sourcePath, destinationPath string) BackupRes
 		err = os.MkdirAll(destinationPath, 0755)
 		if err != nil {
 			result.Status = "Помилка"
+			result.Err = err
 			result.Message = fmt.Sprintf("Can't create destination folder '%s': %v", destinationPath, err)
 			log.Printf("Backup error for job ID %d: %s", jobID, result.Message)
 			result.Duration = time.Since(startTime)
@@ -57,6 +83,7 @@ func PerformLocalBackup(jobID int, sourcePath, destinationPath string) BackupRes
 		err = os.MkdirAll(destDir, 0755)
 		if err != nil {
 			result.Status = "Error"
+			result.Err = err
 			result.Message = fmt.Sprintf("Can't create parent directory for destination file '%s': %v", destDir, err)
 			log.Printf("Backup error for job ID %d: %s", jobID, result.Message)
 			result.Duration = time.Since(startTime)
@@ -66,13 +93,17 @@ func PerformLocalBackup(jobID int, sourcePath, destinationPath string) BackupRes
 
 	// Копіювання вмісту
 	if srcInfo.IsDir() {
-		err = copyDirectory(sourcePath, destinationPath)
+		err = copyDirectory(ctx, sourcePath, sourcePath, destinationPath, &result.Files, &result.Bytes)
 	} else {
-		err = copyFile(sourcePath, destinationPath)
+		result.Bytes, err = copyFile(ctx, sourcePath, destinationPath)
+		if err == nil {
+			result.Files = 1
+		}
 	}
 
 	if err != nil {
 		result.Status = "Error"
+		result.Err = err
 		result.Message = fmt.Sprintf("Error during backup: %v", err)
 		log.Printf("Backup error for job ID %d: %s", jobID, result.Message)
 	} else {
@@ -85,30 +116,31 @@ func PerformLocalBackup(jobID int, sourcePath, destinationPath string) BackupRes
 	return result
 }
 
-func copyFile(src, dst string) error {
+func copyFile(ctx context.Context, src, dst string) (int64, error) {
 	in, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("can't open source file %s: %w", src, err)
+		return 0, sourceError(fmt.Errorf("can't open source file %s: %w", src, err))
 	}
 	defer in.Close()
 
 	out, err := os.Create(dst)
 	if err != nil {
-		return fmt.Errorf("can't create destination file %s: %w", dst, err)
+		return 0, fmt.Errorf("can't create destination file %s: %w", dst, err)
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, in)
+	written, err := io.Copy(out, &contextReader{ctx: ctx, r: in})
 	if err != nil {
-		return fmt.Errorf("error copy file data: %w", err)
+		return written, fmt.Errorf("error copy file data: %w", err)
 	}
-	return out.Close()
+	return written, out.Close()
 }
 
-func copyDirectory(src, dst string) error {
+// copyDirectory copies src into dst, manifest folder in the root of the source is skipped
+func copyDirectory(ctx context.Context, root, src, dst string, files *int, bytes *int64) error {
 	entries, err := os.ReadDir(src)
 	if err != nil {
-		return fmt.Errorf("can't read source directory %s: %w", src, err)
+		return sourceError(fmt.Errorf("can't read source directory %s: %w", src, err))
 	}
 
 	for _, entry := range entries {
@@ -116,20 +148,42 @@ func copyDirectory(src, dst string) error {
 		dstPath := filepath.Join(dst, entry.Name())
 
 		if entry.IsDir() {
+			if entry.Name() == manifestDir && src == root {
+				continue
+			}
 			err = os.MkdirAll(dstPath, 0755)
 			if err != nil {
 				return fmt.Errorf("can't create sub directory %s: %w", dstPath, err)
 			}
-			err = copyDirectory(srcPath, dstPath)
+			err = copyDirectory(ctx, root, srcPath, dstPath, files, bytes)
 			if err != nil {
 				return err
 			}
 		} else {
-			err = copyFile(srcPath, dstPath)
+			written, err := copyFile(ctx, srcPath, dstPath)
 			if err != nil {
 				return err
 			}
+			*files++
+			*bytes += written
 		}
 	}
 	return nil
 }
+
+// contextReader stops reading of the source when the run is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := cr.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = sourceError(err)
+	}
+	return n, err
+}
@@ -0,0 +1,320 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Destination is a place where backup files are written to.
+// relPath always uses forward slashes and is relative to the destination root.
+// size is -1 when the length of the stream is not known in advance.
+type Destination interface {
+	String() string
+	Prepare(ctx context.Context) error
+	WriteFile(ctx context.Context, relPath string, r io.Reader, size int64, modTime time.Time) error
+}
+
+// NewDestination builds destination from the job destination string.
+// Plain paths are local directories, "azure://" URLs are Azure Blob Storage containers.
+func NewDestination(spec string) (Destination, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty destination")
+	}
+
+	if strings.HasPrefix(strings.ToLower(spec), azureScheme) {
+		return NewAzureBlobDestination(spec)
+	}
+
+	return &LocalDestination{Root: spec}, nil
+}
+
+// ChangeChecker is implemented by destinations which can tell that their copy of the file is up to date
+type ChangeChecker interface {
+	Unchanged(relPath string, size int64, modTime time.Time) bool
+}
+
+// ChecksumReader is implemented by destinations which can read written file back to verify it
+type ChecksumReader interface {
+	Checksum(ctx context.Context, relPath string) (string, error)
+}
+
+// Remover is implemented by destinations which can delete written file, e.g. output of failed dump command
+type Remover interface {
+	// Remove deletes the file, missing file is not an error
+	Remove(ctx context.Context, relPath string) error
+}
+
+// MD5Reader is implemented by destinations which can't read written file back, but keep MD5 of the file content
+type MD5Reader interface {
+	// ContentMD5 returns hex encoded MD5 of the file in destination
+	ContentMD5(ctx context.Context, relPath string) (string, error)
+}
+
+// checksums of source file, SHA-256 is compared with ChecksumReader and MD5 with MD5Reader
+type checksums struct {
+	sha256 string
+	md5    string
+}
+
+type LocalDestination struct {
+	Root string
+}
+
+func (d *LocalDestination) String() string {
+	return d.Root
+}
+
+func (d *LocalDestination) Prepare(ctx context.Context) error {
+	if err := os.MkdirAll(d.Root, 0755); err != nil {
+		return fmt.Errorf("can't create destination folder '%s': %w", d.Root, err)
+	}
+	return nil
+}
+
+func (d *LocalDestination) WriteFile(ctx context.Context, relPath string, r io.Reader, size int64, modTime time.Time) error {
+	dst := filepath.Join(d.Root, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("can't create parent directory for '%s': %w", dst, err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("can't create destination file %s: %w", dst, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("error copy file data to '%s': %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("error closing destination file '%s': %w", dst, err)
+	}
+
+	if !modTime.IsZero() {
+		if err := os.Chtimes(dst, time.Now(), modTime); err != nil {
+			return fmt.Errorf("error setting modification time for '%s': %w", dst, err)
+		}
+	}
+	return nil
+}
+
+// Unchanged tells if the destination has the file with the same size and modification time
+func (d *LocalDestination) Unchanged(relPath string, size int64, modTime time.Time) bool {
+	info, err := os.Stat(filepath.Join(d.Root, filepath.FromSlash(relPath)))
+	return err == nil && info.Mode().IsRegular() && info.Size() == size && info.ModTime().Equal(modTime)
+}
+
+func (d *LocalDestination) Remove(ctx context.Context, relPath string) error {
+	if err := os.Remove(filepath.Join(d.Root, filepath.FromSlash(relPath))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't remove destination file '%s': %w", relPath, err)
+	}
+	return nil
+}
+
+// Checksum returns hex encoded SHA-256 of the file in destination
+func (d *LocalDestination) Checksum(ctx context.Context, relPath string) (string, error) {
+	f, err := os.Open(filepath.Join(d.Root, filepath.FromSlash(relPath)))
+	if err != nil {
+		return "", fmt.Errorf("can't open destination file '%s': %w", relPath, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("error reading destination file '%s': %w", relPath, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyChecksum compares destination copy with source checksums.
+// Destinations which can tell neither checksum of their copy are reported as not verified.
+func verifyChecksum(ctx context.Context, dest Destination, relPath string, source checksums) error {
+	var sum, sourceSum string
+	var err error
+	switch reader := dest.(type) {
+	case ChecksumReader:
+		sourceSum = source.sha256
+		sum, err = reader.Checksum(ctx, relPath)
+	case MD5Reader:
+		sourceSum = source.md5
+		sum, err = reader.ContentMD5(ctx, relPath)
+	default:
+		return fmt.Errorf("'%s' is not verified, destination can't be read back", relPath)
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't verify '%s': %w", relPath, err)
+	}
+	if sum != sourceSum {
+		return fmt.Errorf("checksum mismatch for '%s': source %s, destination %s", relPath, sourceSum, sum)
+	}
+	return nil
+}
+
+// DestinationKey identifies storage target of the destination, so runs writing to the same target can be limited together
+func DestinationKey(spec string) string {
+	dest, err := NewDestination(spec)
+	if err != nil {
+		return spec
+	}
+	if local, ok := dest.(*LocalDestination); ok {
+		return filepath.Clean(local.Root)
+	}
+	return dest.String()
+}
+
+// StorageKey identifies storage device of the destination. Local destinations are identified by their mount point,
+// so folders on the same disk or network share are one storage.
+func StorageKey(spec string) string {
+	dest, err := NewDestination(spec)
+	if err != nil {
+		return spec
+	}
+	local, ok := dest.(*LocalDestination)
+	if !ok {
+		return dest.String()
+	}
+
+	// Destination folder can be not created yet, so the nearest existing parent is checked
+	path := filepath.Clean(local.Root)
+	for {
+		if mounted, err := isMountPoint(path); err == nil && mounted {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// BackupOptions are job settings which change how the run is performed
+type BackupOptions struct {
+	// When false the run is successful if at least one destination received the backup
+	RequireAllDestinations bool
+	SpacePolicy            string
+	MinFreeBytes           int64
+	// Retention of files which are gone from the source, used by pruning
+	Retention RetentionPolicy
+	// Verify checksums of written files, used for replication of existing backups
+	Verify bool
+	// SourceType tells how sourcePath is read: files, SQLite database or dump command
+	SourceType string
+	// ChangedPaths makes incremental run which copies only these paths relative to the source directory
+	ChangedPaths []string
+	// Incremental copies only files which are missing or changed on the destination
+	Incremental bool
+}
+
+// PerformBackup copies source to all job destinations.
+// Single local destination keeps using PerformLocalBackup, otherwise source is read once and streamed to every destination.
+// Cancelling ctx stops the copy, the run then gets Cancelled status.
+func PerformBackup(ctx context.Context, jobID int, sourcePath string, destinationPaths []string, opts BackupOptions) BackupResult {
+	result := performBackup(ctx, jobID, sourcePath, destinationPaths, opts)
+	if ctx.Err() != nil {
+		result.Status = StatusCancelled
+		result.Message = fmt.Sprintf("Run was cancelled: %v, %d files (%d bytes) were copied before it stopped",
+			context.Cause(ctx), result.Files, result.Bytes)
+		log.Printf("Backup for job ID %d cancelled: %v", jobID, context.Cause(ctx))
+	}
+	return result
+}
+
+func performBackup(ctx context.Context, jobID int, sourcePath string, destinationPaths []string, opts BackupOptions) BackupResult {
+	var dests []Destination
+	for _, p := range destinationPaths {
+		dest, err := NewDestination(p)
+		if err != nil {
+			return BackupResult{
+				JobID:   jobID,
+				Status:  "Error",
+				Message: fmt.Sprintf("Wrong destination '%s': %v", p, err),
+				Err:     err,
+				Time:    time.Now(),
+			}
+		}
+		dests = append(dests, dest)
+	}
+
+	if opts.SourceType == SourceTypeSQLite {
+		tmpDir, err := os.MkdirTemp("", "backup-sqlite-")
+		if err != nil {
+			return BackupResult{JobID: jobID, Status: "Error", Message: fmt.Sprintf("Can't create temporary directory: %v", err), Err: err, Time: time.Now()}
+		}
+		defer os.RemoveAll(tmpDir)
+
+		log.Printf("Making snapshot of SQLite database '%s' for job ID %d", sourcePath, jobID)
+		snapshotPath, err := snapshotSQLite(ctx, sourcePath, tmpDir)
+		if err != nil {
+			log.Printf("Backup error for job ID %d: %v", jobID, err)
+			return BackupResult{JobID: jobID, Status: "Error", Message: err.Error(), Err: sourceError(err), Time: time.Now()}
+		}
+		sourcePath = snapshotPath
+	}
+
+	if len(dests) == 1 && !opts.Verify && !opts.Incremental && len(opts.ChangedPaths) == 0 && (opts.SourceType == "" || opts.SourceType == SourceTypeFiles) {
+		if local, ok := dests[0].(*LocalDestination); ok {
+			var result BackupResult
+			// PerformLocalBackup writes single file source to the destination path itself
+			if err := checkFreeSpace(ctx, jobID, sourcePath, "", local, opts); err != nil {
+				result = BackupResult{JobID: jobID, Status: statusForError(err), Message: err.Error(), Err: err, Time: time.Now()}
+				log.Printf("Backup for job ID %d not started: %s", jobID, result.Message)
+			} else {
+				result = PerformLocalBackup(ctx, jobID, sourcePath, local.Root)
+				if result.Status == "Success" {
+					recordRetention(jobID, result.Time, local, func() ([]string, error) { return sourceFiles(sourcePath, nil) }, true)
+				}
+			}
+			result.Destinations = []DestinationResult{{
+				Destination: local.Root,
+				Status:      result.Status,
+				Message:     result.Message,
+				Files:       result.Files,
+				Bytes:       result.Bytes,
+				Err:         result.Err,
+			}}
+			return result
+		}
+	}
+
+	result := performFanOutBackup(ctx, jobID, sourcePath, dests, opts)
+	// Results are reported with the destination strings as they are configured in the job
+	for i := range result.Destinations {
+		result.Destinations[i].Destination = destinationPaths[i]
+	}
+	return result
+}
+
+// recordRetention remembers files of the successful run on destinations which support retention.
+// Failure is only logged, the files are then kept longer than the policy says.
+func recordRetention(jobID int, runTime time.Time, dest Destination, files func() ([]string, error), complete bool) {
+	pruner, ok := dest.(Pruner)
+	if !ok {
+		return
+	}
+	names, err := files()
+	if err == nil {
+		err = pruner.RecordRun(jobID, runTime, names, complete)
+	}
+	if err != nil {
+		log.Printf("Can't record files of job ID %d on '%s' for retention: %v", jobID, dest, err)
+	}
+}
+
+func statusForError(err error) string {
+	if errors.Is(err, ErrInsufficientSpace) {
+		return StatusInsufficientSpace
+	}
+	return "Error"
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"syscall"
+)
+
+// Error classes of failed runs, retry policy of the job tells which of them are retried
+const (
+	ErrorClassTransient = "transient" // network, timeouts, locked or busy files, storage temporarily unavailable
+	ErrorClassSpace     = "space"     // not enough free space on destination
+	ErrorClassSource    = "source"    // source is missing or can't be read
+	ErrorClassOther     = "other"
+)
+
+var ErrorClasses = []string{ErrorClassTransient, ErrorClassSpace, ErrorClassSource, ErrorClassOther}
+
+// SourceError is failure to read the source, another destination or a retry with the same source does not help
+type SourceError struct {
+	Err error
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+func sourceError(err error) error {
+	var srcErr *SourceError
+	if err == nil || errors.As(err, &srcErr) {
+		return err
+	}
+	return &SourceError{Err: err}
+}
+
+// System errors which usually disappear when the operation is repeated later
+var transientErrnos = append([]syscall.Errno{
+	syscall.EAGAIN, syscall.EBUSY, syscall.EIO, syscall.ETIMEDOUT, syscall.EPIPE,
+	syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED,
+	syscall.ENETDOWN, syscall.ENETUNREACH, syscall.EHOSTUNREACH,
+}, platformTransientErrnos...)
+
+// ClassifyResult returns error class of failed run, empty string for successful, skipped or cancelled runs.
+// When the run failed on destinations, the class of the destination error which is the most likely to pass on retry is used.
+func ClassifyResult(result BackupResult) string {
+	switch result.Status {
+	case "Success", StatusCancelled, StatusTimedOut, StatusPaused, "Skipped":
+		return ""
+	case StatusInsufficientSpace:
+		return ErrorClassSpace
+	}
+	if result.Err != nil {
+		return ClassifyError(result.Err)
+	}
+
+	class := ErrorClassOther
+	for _, d := range result.Destinations {
+		if d.Status == "Success" || d.Err == nil {
+			continue
+		}
+		switch c := ClassifyError(d.Err); {
+		case c == ErrorClassTransient:
+			return c
+		case c == ErrorClassSpace || class == ErrorClassOther:
+			class = c
+		}
+	}
+	return class
+}
+
+// ClassifyError returns error class of the error which stopped the run
+func ClassifyError(err error) string {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var urlErr *url.Error
+	var azureErr *AzureError
+	var errno syscall.Errno
+	var srcErr *SourceError
+
+	switch {
+	case err == nil:
+		return ErrorClassOther
+	case errors.Is(err, ErrInsufficientSpace), errors.Is(err, syscall.ENOSPC):
+		return ErrorClassSpace
+	case errors.As(err, &opErr), errors.As(err, &dnsErr), errors.As(err, &urlErr), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorClassTransient
+	case errors.As(err, &azureErr) && azureErr.Temporary():
+		return ErrorClassTransient
+	case sqliteBusy(err):
+		return ErrorClassTransient
+	case errors.As(err, &errno) && slices.Contains(transientErrnos, errno):
+		return ErrorClassTransient
+	case errors.As(err, &srcErr):
+		return ErrorClassSource
+	}
+	return ErrorClassOther
+}
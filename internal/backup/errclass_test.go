@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"insufficient space", fmt.Errorf("%w: '/mnt' has 0 bytes free", ErrInsufficientSpace), ErrorClassSpace},
+		{"disk full", &os.PathError{Op: "write", Path: "/backup/a", Err: syscall.ENOSPC}, ErrorClassSpace},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ErrorClassTransient},
+		{"busy file", fmt.Errorf("copy: %w", &os.PathError{Op: "open", Path: "/data/db", Err: syscall.EBUSY}), ErrorClassTransient},
+		{"azure throttling", &AzureError{Operation: "put blob", StatusCode: 503, Code: "ServerBusy"}, ErrorClassTransient},
+		{"azure forbidden", &AzureError{Operation: "put blob", StatusCode: 403, Code: "AuthorizationFailure"}, ErrorClassOther},
+		{"missing source", sourceError(&os.PathError{Op: "stat", Path: "/srv/network-timeout", Err: syscall.ENOENT}), ErrorClassSource},
+		{"unreadable source on busy device", sourceError(&os.PathError{Op: "read", Path: "/src", Err: syscall.EBUSY}), ErrorClassTransient},
+		{"words in message are not classes", fmt.Errorf("can't write '/backups/connection timeout/source'"), ErrorClassOther},
+		{"permission on destination", &os.PathError{Op: "open", Path: "/backup/a", Err: syscall.EACCES}, ErrorClassOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyResult(t *testing.T) {
+	transient := &AzureError{Operation: "put block", StatusCode: 500, Code: "InternalError"}
+	tests := []struct {
+		name   string
+		result BackupResult
+		want   string
+	}{
+		{"success", BackupResult{Status: "Success"}, ""},
+		{"cancelled", BackupResult{Status: StatusCancelled, Err: transient}, ""},
+		{"space status", BackupResult{Status: StatusInsufficientSpace}, ErrorClassSpace},
+		{"run error", BackupResult{Status: "Error", Err: sourceError(os.ErrNotExist)}, ErrorClassSource},
+		{"destinations", BackupResult{Status: "Error", Destinations: []DestinationResult{
+			{Status: "Error", Err: os.ErrPermission},
+			{Status: "Error", Err: transient},
+		}}, ErrorClassTransient},
+		{"destination without error", BackupResult{Status: "Error", Destinations: []DestinationResult{
+			{Status: "Error", Message: "connection reset"},
+		}}, ErrorClassOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyResult(tt.result); got != tt.want {
+				t.Errorf("ClassifyResult() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
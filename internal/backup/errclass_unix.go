@@ -0,0 +1,7 @@
+//go:build !windows
+
+package backup
+
+import "syscall"
+
+var platformTransientErrnos = []syscall.Errno{syscall.ESTALE}
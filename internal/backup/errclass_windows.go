@@ -0,0 +1,13 @@
+//go:build windows
+
+package backup
+
+import "syscall"
+
+// File is used or locked by another process
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+var platformTransientErrnos = []syscall.Errno{errorSharingViolation, errorLockViolation}
@@ -0,0 +1,350 @@
+package backup
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fanOutBufferSize = 256 * 1024
+
+// errAllDestinationsFailed stops the walk over source, results of each destination are reported separately
+var errAllDestinationsFailed = errors.New("all destinations failed")
+
+type fanOutTarget struct {
+	dest   Destination
+	result DestinationResult
+	err    error
+	// Names of files written by the run
+	written []string
+}
+
+// performFanOutBackup reads every source file once and streams it to all destinations at the same time.
+// Failed destination is excluded from the rest of the run, other destinations continue.
+func performFanOutBackup(ctx context.Context, jobID int, sourcePath string, dests []Destination, opts BackupOptions) BackupResult {
+	startTime := time.Now()
+	result := BackupResult{
+		JobID: jobID,
+		Time:  startTime,
+	}
+
+	var names []string
+	targets := make([]*fanOutTarget, len(dests))
+	for i, dest := range dests {
+		targets[i] = &fanOutTarget{dest: dest, result: DestinationResult{Destination: dest.String()}}
+		names = append(names, dest.String())
+	}
+
+	log.Printf("Starting backup for job ID %d from '%s' to '%s'", jobID, sourcePath, strings.Join(names, "', '"))
+
+	var srcInfo os.FileInfo
+	var err error
+	if opts.SourceType != SourceTypeCommand {
+		srcInfo, err = os.Stat(sourcePath)
+		if err != nil {
+			result.Status = "Error"
+			result.Err = sourceError(err)
+			result.Message = fmt.Sprintf("Access to source error '%s': %v", sourcePath, err)
+			log.Printf("Backup error for job ID %d: %s", jobID, result.Message)
+			result.Destinations = fanOutResults(targets, fmt.Errorf("source is not available"))
+			result.Duration = time.Since(startTime)
+			return result
+		}
+	}
+
+	for _, t := range targets {
+		if err := checkFreeSpace(ctx, jobID, sourcePath, filepath.Base(sourcePath), t.dest, opts); err != nil {
+			t.err = err
+			log.Printf("Backup for job ID %d skips destination '%s': %v", jobID, t.dest, t.err)
+			continue
+		}
+		if err := t.dest.Prepare(ctx); err != nil {
+			t.err = fmt.Errorf("can't prepare destination: %w", err)
+			log.Printf("Backup error for job ID %d, destination '%s': %v", jobID, t.dest, t.err)
+		}
+	}
+
+	switch {
+	case opts.SourceType == SourceTypeCommand:
+		err = fanOutCommand(ctx, targets, sourcePath)
+	case srcInfo.IsDir() && len(opts.ChangedPaths) > 0:
+		log.Printf("Incremental backup for job ID %d copies %d changed paths", jobID, len(opts.ChangedPaths))
+		for _, rel := range opts.ChangedPaths {
+			path := filepath.Join(sourcePath, filepath.FromSlash(rel))
+			if _, statErr := os.Lstat(path); os.IsNotExist(statErr) {
+				// Path was removed after the change
+				continue
+			}
+			if err = fanOutTree(ctx, targets, sourcePath, path, opts); err != nil {
+				break
+			}
+		}
+	case srcInfo.IsDir():
+		err = fanOutTree(ctx, targets, sourcePath, sourcePath, opts)
+	default:
+		err = fanOutFile(ctx, targets, sourcePath, filepath.Base(sourcePath), srcInfo, opts)
+	}
+	if errors.Is(err, errAllDestinationsFailed) {
+		err = nil
+	}
+
+	result.Destinations = fanOutResults(targets, err)
+	if err == nil {
+		var files []string
+		var filesErr error
+		listed := false
+		for _, t := range targets {
+			if t.err != nil {
+				continue
+			}
+			recordRetention(jobID, startTime, t.dest, func() ([]string, error) {
+				if opts.SourceType == SourceTypeCommand {
+					return t.written, nil
+				}
+				if !listed {
+					files, filesErr = sourceFiles(sourcePath, opts.ChangedPaths)
+					listed = true
+				}
+				return files, filesErr
+			}, len(opts.ChangedPaths) == 0)
+		}
+	}
+
+	var failed []string
+	onlySpaceFailures := true
+	for _, d := range result.Destinations {
+		if d.Files > result.Files {
+			result.Files, result.Bytes = d.Files, d.Bytes
+		}
+		if d.Status != "Success" {
+			failed = append(failed, fmt.Sprintf("%s: %s", d.Destination, d.Message))
+			onlySpaceFailures = onlySpaceFailures && d.Status == StatusInsufficientSpace
+		}
+	}
+
+	switch {
+	case err != nil:
+		result.Status = "Error"
+		result.Err = sourceError(err)
+		result.Message = fmt.Sprintf("Error during backup: %v", err)
+	case len(failed) == 0:
+		result.Status = "Success"
+		result.Message = "Backup successfully completed."
+	case !opts.RequireAllDestinations && len(failed) < len(targets):
+		result.Status = "Success"
+		result.Message = fmt.Sprintf("Backup completed, %d of %d destinations failed: %s", len(failed), len(targets), strings.Join(failed, "; "))
+	default:
+		result.Status = "Error"
+		if onlySpaceFailures {
+			result.Status = StatusInsufficientSpace
+		}
+		result.Message = fmt.Sprintf("Backup failed for %d of %d destinations: %s", len(failed), len(targets), strings.Join(failed, "; "))
+	}
+
+	if result.Status == "Success" {
+		log.Printf("Backup for job ID %d completed successfully. %s", jobID, result.Message)
+	} else {
+		log.Printf("Backup error for job ID %d: %s", jobID, result.Message)
+	}
+
+	result.Duration = time.Since(startTime)
+	return result
+}
+
+// fanOutTree copies regular files under path, names on destinations are relative to sourcePath
+func fanOutTree(ctx context.Context, targets []*fanOutTarget, sourcePath, path string, opts BackupOptions) error {
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isManifestDir(sourcePath, path, info) {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(sourcePath, path)
+		if err != nil {
+			return err
+		}
+		return fanOutFile(ctx, targets, path, filepath.ToSlash(rel), info, opts)
+	})
+}
+
+// fanOutFile copies one source file to all destinations which did not fail yet.
+// With Verify the SHA-256 of the source is compared with the written copy, or MD5 on destinations which keep only MD5 of it.
+// Incremental run skips destinations which already have the same file.
+// Returned error means source problem, destination problems are kept in targets.
+func fanOutFile(ctx context.Context, targets []*fanOutTarget, path, relPath string, info os.FileInfo, opts BackupOptions) error {
+	if activeTargets(targets) == 0 {
+		return errAllDestinationsFailed
+	}
+	if opts.Incremental {
+		var changed []*fanOutTarget
+		for _, t := range targets {
+			if checker, ok := t.dest.(ChangeChecker); !ok || !checker.Unchanged(relPath, info.Size(), info.ModTime()) {
+				changed = append(changed, t)
+			}
+		}
+		if activeTargets(changed) == 0 {
+			return nil
+		}
+		targets = changed
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return sourceError(fmt.Errorf("can't open source file %s: %w", path, err))
+	}
+	defer f.Close()
+
+	if err := fanOutStream(ctx, targets, f, relPath, info.Size(), info.ModTime(), opts.Verify); err != nil {
+		return fmt.Errorf("error reading source file '%s': %w", path, err)
+	}
+	return nil
+}
+
+func activeTargets(targets []*fanOutTarget) int {
+	count := 0
+	for _, t := range targets {
+		if t.err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// fanOutStream writes data of one file to all destinations which did not fail yet.
+// size is -1 when length of the stream is unknown.
+func fanOutStream(ctx context.Context, targets []*fanOutTarget, r io.Reader, relPath string, size int64, modTime time.Time, verify bool) error {
+	var active []*fanOutTarget
+	for _, t := range targets {
+		if t.err == nil {
+			active = append(active, t)
+		}
+	}
+	if len(active) == 0 {
+		return errAllDestinationsFailed
+	}
+
+	writers := make([]*io.PipeWriter, len(active))
+	errs := make([]error, len(active))
+	written := make([]int64, len(active))
+	alive := make([]bool, len(active))
+
+	var wg sync.WaitGroup
+	for i, t := range active {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+		alive[i] = true
+
+		wg.Add(1)
+		go func(i int, t *fanOutTarget, pr *io.PipeReader) {
+			defer wg.Done()
+			errs[i] = t.dest.WriteFile(ctx, relPath, pr, size, modTime)
+			if errs[i] != nil {
+				pr.CloseWithError(errs[i])
+			} else {
+				pr.Close()
+			}
+		}(i, t, pr)
+	}
+
+	var readErr error
+	hash := sha256.New()
+	md5Hash := md5.New()
+	buf := make([]byte, fanOutBufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			readErr = err
+			break
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			md5Hash.Write(buf[:n])
+			for i, pw := range writers {
+				if !alive[i] {
+					continue
+				}
+				if _, werr := pw.Write(buf[:n]); werr != nil {
+					alive[i] = false
+					continue
+				}
+				written[i] += int64(n)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = sourceError(err)
+			break
+		}
+	}
+
+	for _, pw := range writers {
+		if readErr != nil {
+			pw.CloseWithError(readErr)
+		} else {
+			pw.Close()
+		}
+	}
+	wg.Wait()
+
+	if readErr != nil {
+		return readErr
+	}
+
+	sourceSums := checksums{sha256: hex.EncodeToString(hash.Sum(nil)), md5: hex.EncodeToString(md5Hash.Sum(nil))}
+	for i, t := range active {
+		if errs[i] != nil {
+			t.err = fmt.Errorf("error writing '%s': %w", relPath, errs[i])
+			log.Printf("Backup error for destination '%s': %v", t.dest, t.err)
+			continue
+		}
+		if verify {
+			if err := verifyChecksum(ctx, t.dest, relPath, sourceSums); err != nil {
+				t.err = err
+				log.Printf("Backup error for destination '%s': %v", t.dest, t.err)
+				continue
+			}
+		}
+		t.result.Files++
+		t.result.Bytes += written[i]
+		t.written = append(t.written, relPath)
+	}
+	return nil
+}
+
+func fanOutResults(targets []*fanOutTarget, runErr error) []DestinationResult {
+	results := make([]DestinationResult, len(targets))
+	for i, t := range targets {
+		r := t.result
+		switch {
+		case t.err != nil:
+			r.Status = statusForError(t.err)
+			r.Message = t.err.Error()
+			r.Err = t.err
+		case runErr != nil:
+			r.Status = "Error"
+			r.Message = fmt.Sprintf("Backup interrupted: %v", runErr)
+			r.Err = runErr
+		default:
+			r.Status = "Success"
+			r.Message = "Backup successfully completed."
+		}
+		results[i] = r
+	}
+	return results
+}
@@ -58,7 +58,7 @@ func CopyDir(src, dst string, totalCopiedFiles *int, totalCopiedBytes *int64) er
 
 	sourceInfo, err := os.Stat(src)
 	if err != nil {
-		fmt.Errorf("error getting source directory information '%s': %w", src, err)
+		return fmt.Errorf("error getting source directory information '%s': %w", src, err)
 	}
 
 	if !sourceInfo.IsDir() {
@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VerifyBackup compares SHA-256 of every source file with its copy on each destination, MD5 on destinations which keep only MD5.
+// Destinations which can tell neither of them fail as not verified.
+// Cancelling ctx stops the check, the run then gets Cancelled status.
+func VerifyBackup(ctx context.Context, jobID int, sourcePath string, destinationPaths []string) BackupResult {
+	result := maintainDestinations(ctx, jobID, sourcePath, SourceTypeFiles, destinationPaths, "verify", verifyDestination)
+	if ctx.Err() != nil {
+		result.Status = StatusCancelled
+		result.Message = fmt.Sprintf("Verification was cancelled: %v, %d files were checked before it stopped", context.Cause(ctx), result.Files)
+		log.Printf("Verification for job ID %d cancelled: %v", jobID, context.Cause(ctx))
+	}
+	return result
+}
+
+// PruneBackup removes files of the job which are gone from the source longer than retention policy allows from each destination.
+// Cancelling ctx stops the removal, the run then gets Cancelled status.
+func PruneBackup(ctx context.Context, jobID int, sourcePath string, destinationPaths []string, opts BackupOptions) BackupResult {
+	prune := func(ctx context.Context, dest Destination, sourcePath string) DestinationResult {
+		return pruneDestination(ctx, jobID, dest, sourcePath, opts)
+	}
+	result := maintainDestinations(ctx, jobID, sourcePath, opts.SourceType, destinationPaths, "prune", prune)
+	if ctx.Err() != nil {
+		result.Status = StatusCancelled
+		result.Message = fmt.Sprintf("Pruning was cancelled: %v", context.Cause(ctx))
+		log.Printf("Pruning for job ID %d cancelled: %v", jobID, context.Cause(ctx))
+	}
+	return result
+}
+
+// maintainDestinations runs fn for every destination and collects the results the same way as the backup does
+func maintainDestinations(ctx context.Context, jobID int, sourcePath, sourceType string, destinationPaths []string, action string,
+	fn func(ctx context.Context, dest Destination, sourcePath string) DestinationResult) BackupResult {
+	startTime := time.Now()
+	result := BackupResult{JobID: jobID, Time: startTime}
+
+	if _, err := os.Stat(sourcePath); err != nil && sourceType != SourceTypeCommand {
+		result.Status = "Error"
+		result.Err = sourceError(err)
+		result.Message = fmt.Sprintf("Access to source error '%s': %v", sourcePath, err)
+		log.Printf("Backup %s error for job ID %d: %s", action, jobID, result.Message)
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
+	log.Printf("Starting backup %s for job ID %d of '%s' on '%s'", action, jobID, sourcePath, strings.Join(destinationPaths, "', '"))
+
+	var failed []string
+	for _, p := range destinationPaths {
+		var r DestinationResult
+		dest, err := NewDestination(p)
+		if err != nil {
+			r = DestinationResult{Status: "Error", Message: fmt.Sprintf("Wrong destination: %v", err), Err: err}
+		} else {
+			r = fn(ctx, dest, sourcePath)
+		}
+		r.Destination = p
+		if r.Files > result.Files || (r.Files == result.Files && r.Bytes > result.Bytes) {
+			result.Files, result.Bytes = r.Files, r.Bytes
+		}
+		if r.Status != "Success" {
+			failed = append(failed, fmt.Sprintf("%s: %s", p, r.Message))
+		}
+		result.Destinations = append(result.Destinations, r)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if len(failed) == 0 {
+		result.Status = "Success"
+		result.Message = fmt.Sprintf("Backup %s successfully completed.", action)
+		log.Printf("Backup %s for job ID %d completed successfully", action, jobID)
+	} else {
+		result.Status = "Error"
+		result.Message = fmt.Sprintf("Backup %s failed for %d of %d destinations: %s", action, len(failed), len(destinationPaths), strings.Join(failed, "; "))
+		log.Printf("Backup %s error for job ID %d: %s", action, jobID, result.Message)
+	}
+	result.Duration = time.Since(startTime)
+	return result
+}
+
+func verifyDestination(ctx context.Context, dest Destination, sourcePath string) DestinationResult {
+	var destSum func(relPath string) (string, error)
+	var sourceSum func(sums checksums) string
+	switch reader := dest.(type) {
+	case ChecksumReader:
+		destSum = func(relPath string) (string, error) { return reader.Checksum(ctx, relPath) }
+		sourceSum = func(sums checksums) string { return sums.sha256 }
+	case MD5Reader:
+		destSum = func(relPath string) (string, error) { return reader.ContentMD5(ctx, relPath) }
+		sourceSum = func(sums checksums) string { return sums.md5 }
+	default:
+		return DestinationResult{Status: "Error", Message: "Destination can't be read back, not verified."}
+	}
+
+	srcInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return DestinationResult{Status: "Error", Message: fmt.Sprintf("Access to source error: %v", err), Err: sourceError(err)}
+	}
+
+	var r DestinationResult
+	var mismatches []string
+	check := func(path, relPath string, size int64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sums, err := fileChecksums(path)
+		if err != nil {
+			return err
+		}
+		sum, err := destSum(relPath)
+		switch {
+		case err != nil:
+			mismatches = append(mismatches, fmt.Sprintf("'%s' is missing", relPath))
+		case sum != sourceSum(sums):
+			mismatches = append(mismatches, fmt.Sprintf("'%s' differs", relPath))
+		}
+		r.Files++
+		r.Bytes += size
+		return nil
+	}
+
+	if srcInfo.IsDir() {
+		err = filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if isManifestDir(sourcePath, path, info) {
+				return filepath.SkipDir
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(sourcePath, path)
+			if err != nil {
+				return err
+			}
+			return check(path, filepath.ToSlash(rel), info.Size())
+		})
+	} else {
+		err = check(sourcePath, filepath.Base(sourcePath), srcInfo.Size())
+	}
+
+	switch {
+	case err != nil:
+		r.Status = "Error"
+		r.Message = fmt.Sprintf("Verification interrupted: %v", err)
+		r.Err = err
+	case len(mismatches) > 0:
+		r.Status = "Error"
+		r.Message = fmt.Sprintf("%d of %d files do not match source: %s", len(mismatches), r.Files, strings.Join(mismatches, ", "))
+	default:
+		r.Status = "Success"
+		r.Message = fmt.Sprintf("All %d files match source.", r.Files)
+	}
+	return r
+}
+
+func pruneDestination(ctx context.Context, jobID int, dest Destination, sourcePath string, opts BackupOptions) DestinationResult {
+	pruner, ok := dest.(Pruner)
+	if !ok {
+		return DestinationResult{Status: "Success", Message: "Destination does not support pruning."}
+	}
+	freed, err := pruner.Prune(ctx, jobID, sourcePath, opts.SourceType, opts.Retention)
+	if err != nil {
+		return DestinationResult{Status: "Error", Message: err.Error(), Bytes: freed, Err: err}
+	}
+	return DestinationResult{Status: "Success", Message: fmt.Sprintf("Removed %d bytes of files older than %s.", freed, opts.Retention), Bytes: freed}
+}
+
+func fileChecksums(path string) (checksums, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return checksums{}, fmt.Errorf("can't open source file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	md5Hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(hash, md5Hash), f); err != nil {
+		return checksums{}, fmt.Errorf("error reading source file '%s': %w", path, err)
+	}
+	return checksums{sha256: hex.EncodeToString(hash.Sum(nil)), md5: hex.EncodeToString(md5Hash.Sum(nil))}, nil
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+)
+
+// isMountPoint compares device of the path with device of its parent
+func isMountPoint(path string) (bool, error) {
+	parent := filepath.Dir(path)
+	if parent == path {
+		return true, nil
+	}
+
+	var st, parentSt syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return false, fmt.Errorf("stat '%s': %w", path, err)
+	}
+	if err := syscall.Stat(parent, &parentSt); err != nil {
+		return false, fmt.Errorf("stat '%s': %w", parent, err)
+	}
+	return st.Dev != parentSt.Dev || st.Ino == parentSt.Ino, nil
+}
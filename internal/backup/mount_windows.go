@@ -0,0 +1,26 @@
+//go:build windows
+
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// isMountPoint accepts root of a drive or folder where a volume is mounted (reparse point)
+func isMountPoint(path string) (bool, error) {
+	if vol := filepath.VolumeName(path); vol != "" && strings.TrimRight(path, `\/`) == vol {
+		return true, nil
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+	if attrs, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return attrs.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0, nil
+	}
+	return false, nil
+}
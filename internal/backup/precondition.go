@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// preconditionTimeout limits network and command checks, so an offline share doesn't hold the run
+const preconditionTimeout = 30 * time.Second
+
+// CheckMountPoint makes sure path is a mount point, so the backup is not written to the empty folder of unmounted disk
+func CheckMountPoint(path string) error {
+	path = filepath.Clean(path)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("'%s' is not available: %w", path, err)
+	}
+	mounted, err := isMountPoint(path)
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		return fmt.Errorf("'%s' is not a mount point, disk is not mounted", path)
+	}
+	return nil
+}
+
+// CheckMarkerFile makes sure marker file exists, e.g. file which is kept only on the backup disk
+func CheckMarkerFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("marker file '%s' is not found: %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("marker '%s' is a directory, not a file", path)
+	}
+	return nil
+}
+
+// CheckReachable makes sure host:port accepts TCP connections
+func CheckReachable(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, preconditionTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("'%s' is not reachable: %w", address, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// CheckFreeSpace makes sure path has at least minFreeBytes free, nearest existing parent is checked for missing path
+func CheckFreeSpace(ctx context.Context, path string, minFreeBytes int64) error {
+	dest, err := NewDestination(path)
+	if err != nil {
+		return fmt.Errorf("wrong path '%s': %w", path, err)
+	}
+	reporter, ok := dest.(SpaceReporter)
+	if !ok {
+		return nil
+	}
+	free, err := reporter.FreeSpace(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get free space of '%s': %w", path, err)
+	}
+	if free < minFreeBytes {
+		return fmt.Errorf("'%s' has %d MB free, %d MB required", path, free/(1<<20), minFreeBytes/(1<<20))
+	}
+	return nil
+}
+
+// CheckCommand runs shell command and succeeds when it exits with 0
+func CheckCommand(ctx context.Context, command string) error {
+	if err := CommandAllowed(command); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, preconditionTimeout)
+	defer cancel()
+
+	output, err := shellCommand(ctx, command).CombinedOutput()
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("command '%s' failed (%v): %s", commandName(command), err, out)
+		}
+		return fmt.Errorf("command '%s' failed: %w", commandName(command), err)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy decides how long files written by the job are kept after they are gone from the source.
+// Dumps of command jobs are never in the source, so the policy decides how many of them are kept.
+// File is kept while one of the rules keeps it, zero disables the rule.
+type RetentionPolicy struct {
+	// File is kept while it was in the source during one of the last KeepRuns runs
+	KeepRuns int
+	// File is kept while it was in the source during the last KeepDays days
+	KeepDays int
+}
+
+func (p RetentionPolicy) String() string {
+	var rules []string
+	if p.KeepRuns > 0 {
+		rules = append(rules, fmt.Sprintf("%d runs", p.KeepRuns))
+	}
+	if p.KeepDays > 0 {
+		rules = append(rules, fmt.Sprintf("%d days", p.KeepDays))
+	}
+	return strings.Join(rules, " or ")
+}
+
+// cutoff returns time before which files must have been seen last to be removed.
+// Files of the latest run are never removed.
+func (p RetentionPolicy) cutoff(runs []time.Time, now time.Time) time.Time {
+	var cutoff time.Time
+	set := false
+	keep := func(t time.Time) {
+		if !set || t.Before(cutoff) {
+			cutoff, set = t, true
+		}
+	}
+	if p.KeepRuns > 0 {
+		if len(runs) <= p.KeepRuns {
+			keep(time.Time{})
+		} else {
+			keep(runs[len(runs)-p.KeepRuns])
+		}
+	}
+	if p.KeepDays > 0 {
+		keep(now.AddDate(0, 0, -p.KeepDays))
+	}
+	if len(runs) > 0 && runs[len(runs)-1].Before(cutoff) {
+		cutoff = runs[len(runs)-1]
+	}
+	return cutoff
+}
+
+// Pruner is implemented by destinations which remember files written by each job and can remove them by retention policy
+type Pruner interface {
+	// RecordRun remembers files which the successful run wrote or found up to date.
+	// complete is false for runs which saw only a part of the source, they do not count as retention runs.
+	RecordRun(jobID int, runTime time.Time, files []string, complete bool) error
+	Prune(ctx context.Context, jobID int, sourcePath, sourceType string, policy RetentionPolicy) (int64, error)
+}
+
+// Runs kept in the manifest, enough for any reasonable KeepRuns
+const maxManifestRuns = 1000
+
+// Folder in the destination root which keeps manifests, it is not part of the backup and is not copied when the backup is replicated
+const manifestDir = ".backup-app"
+
+// isManifestDir tells if walked path is the manifest folder in the root of the source
+func isManifestDir(sourcePath, path string, info os.FileInfo) bool {
+	return info.IsDir() && info.Name() == manifestDir && filepath.Dir(path) == filepath.Clean(sourcePath)
+}
+
+// retentionManifest lists files which the job wrote to the destination with the time of the last run which found them in the source
+type retentionManifest struct {
+	Runs  []time.Time          `json:"runs"`
+	Files map[string]time.Time `json:"files"`
+}
+
+func (d *LocalDestination) manifestPath(jobID int) string {
+	return filepath.Join(d.Root, manifestDir, fmt.Sprintf("job-%d.json", jobID))
+}
+
+func (d *LocalDestination) loadManifest(jobID int) (*retentionManifest, error) {
+	m := &retentionManifest{Files: map[string]time.Time{}}
+	data, err := os.ReadFile(d.manifestPath(jobID))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read retention manifest of job ID %d: %w", jobID, err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("retention manifest of job ID %d is damaged: %w", jobID, err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]time.Time{}
+	}
+	return m, nil
+}
+
+func (d *LocalDestination) saveManifest(jobID int, m *retentionManifest) error {
+	path := d.manifestPath(jobID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("can't create folder for retention manifest: %w", err)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("can't write retention manifest of job ID %d: %w", jobID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("can't write retention manifest of job ID %d: %w", jobID, err)
+	}
+	return nil
+}
+
+func (d *LocalDestination) RecordRun(jobID int, runTime time.Time, files []string, complete bool) error {
+	m, err := d.loadManifest(jobID)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		m.Files[f] = runTime
+	}
+	if complete {
+		m.Runs = append(m.Runs, runTime)
+		if len(m.Runs) > maxManifestRuns {
+			m.Runs = m.Runs[len(m.Runs)-maxManifestRuns:]
+		}
+	}
+	return d.saveManifest(jobID, m)
+}
+
+// Prune removes files written by the job which are gone from the source longer than the policy allows.
+// Files of other jobs and files written before the manifest existed are never touched.
+// Empty or missing source is refused, it usually means unmounted disk rather than deleted data.
+func (d *LocalDestination) Prune(ctx context.Context, jobID int, sourcePath, sourceType string, policy RetentionPolicy) (int64, error) {
+	if policy.KeepRuns <= 0 && policy.KeepDays <= 0 {
+		return 0, fmt.Errorf("retention policy is not set, nothing is pruned")
+	}
+
+	inSource := func(string) bool { return false }
+	if sourceType != SourceTypeCommand {
+		var err error
+		if inSource, err = sourceContains(sourcePath); err != nil {
+			return 0, err
+		}
+	}
+
+	m, err := d.loadManifest(jobID)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := policy.cutoff(m.Runs, time.Now())
+
+	var names []string
+	for name, seen := range m.Files {
+		if seen.Before(cutoff) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var freed int64
+	for _, name := range names {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if inSource(name) {
+			continue
+		}
+		path := filepath.Join(d.Root, filepath.FromSlash(name))
+		if rel, relErr := filepath.Rel(d.Root, path); relErr != nil || strings.HasPrefix(rel, "..") {
+			delete(m.Files, name)
+			continue
+		}
+		info, statErr := os.Lstat(path)
+		if os.IsNotExist(statErr) {
+			delete(m.Files, name)
+			continue
+		}
+		if statErr != nil {
+			err = statErr
+			break
+		}
+		if err = os.Remove(path); err != nil {
+			err = fmt.Errorf("can't remove '%s': %w", path, err)
+			break
+		}
+		freed += info.Size()
+		delete(m.Files, name)
+		removeEmptyParents(d.Root, filepath.Dir(path))
+	}
+
+	if saveErr := d.saveManifest(jobID, m); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		return freed, fmt.Errorf("error pruning destination '%s': %w", d.Root, err)
+	}
+	return freed, nil
+}
+
+// sourceContains checks that the source has data and returns function which tells if the file is still in the source
+func sourceContains(sourcePath string) (func(name string) bool, error) {
+	srcInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("access to source error '%s', pruning is refused: %w", sourcePath, err)
+	}
+	if !srcInfo.IsDir() {
+		base := filepath.Base(sourcePath)
+		return func(name string) bool { return name == base }, nil
+	}
+
+	entries, err := os.ReadDir(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read source '%s', pruning is refused: %w", sourcePath, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("source '%s' is empty, pruning is refused to keep the backup", sourcePath)
+	}
+	return func(name string) bool {
+		_, err := os.Lstat(filepath.Join(sourcePath, filepath.FromSlash(name)))
+		// Files which can't be checked are kept
+		return !os.IsNotExist(err)
+	}, nil
+}
+
+func removeEmptyParents(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// sourceFiles lists files of the source as they are named on destinations.
+// With changedPaths only these paths are listed.
+func sourceFiles(sourcePath string, changedPaths []string) ([]string, error) {
+	srcInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if !srcInfo.IsDir() {
+		return []string{filepath.Base(sourcePath)}, nil
+	}
+
+	roots := []string{sourcePath}
+	if len(changedPaths) > 0 {
+		roots = nil
+		for _, rel := range changedPaths {
+			roots = append(roots, filepath.Join(sourcePath, filepath.FromSlash(rel)))
+		}
+	}
+
+	var files []string
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if isManifestDir(sourcePath, path, info) {
+				return filepath.SkipDir
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(sourcePath, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
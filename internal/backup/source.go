@@ -0,0 +1,147 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source types of the backup job
+const (
+	SourceTypeFiles   = "files"   // file or directory copied as is
+	SourceTypeSQLite  = "sqlite"  // consistent snapshot of SQLite database made with online backup API
+	SourceTypeCommand = "command" // stdout of dump command (pg_dump, mysqldump, ...)
+)
+
+// fanOutCommand runs dump command and streams its stdout to all destinations.
+// Output is written to new file for every run, so failed dump never replaces the previous good one,
+// and the part of output which failed dump has written is removed from destinations.
+func fanOutCommand(ctx context.Context, targets []*fanOutTarget, command string) error {
+	if activeTargets(targets) == 0 {
+		return errAllDestinationsFailed
+	}
+
+	if err := CommandAllowed(command); err != nil {
+		return err
+	}
+
+	cmd := shellCommand(ctx, command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("can't get output of command: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("can't start command '%s': %w", command, err)
+	}
+	// Children of the killed shell can keep the output open, reading is stopped with the run
+	stop := context.AfterFunc(ctx, func() { stdout.Close() })
+	defer stop()
+
+	startTime := time.Now()
+	name := fmt.Sprintf("%s-%s.dump", commandName(command), startTime.Format("20060102-150405"))
+	log.Printf("Dump command '%s' started, output is written to '%s'", command, name)
+
+	streamErr := fanOutStream(ctx, targets, stdout, name, -1, startTime, false)
+	waitErr := cmd.Wait()
+	if waitErr != nil || streamErr != nil {
+		removeDump(ctx, targets, name)
+	}
+
+	if waitErr != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 500 {
+			msg = msg[len(msg)-500:]
+		}
+		return fmt.Errorf("dump command failed (%v): %s", waitErr, msg)
+	}
+	if streamErr != nil {
+		return fmt.Errorf("error reading output of command: %w", streamErr)
+	}
+	return nil
+}
+
+// removeDump deletes output of failed dump command, so it is not taken for a good dump
+func removeDump(ctx context.Context, targets []*fanOutTarget, name string) {
+	// Cleanup runs also when the run was cancelled
+	ctx = context.WithoutCancel(ctx)
+	for _, t := range targets {
+		t.written = slices.DeleteFunc(t.written, func(written string) bool { return written == name })
+		remover, ok := t.dest.(Remover)
+		if !ok {
+			log.Printf("Output of failed dump command '%s' is left on destination '%s', it can't remove files", name, t.dest)
+			continue
+		}
+		if err := remover.Remove(ctx, name); err != nil {
+			log.Printf("Can't remove output of failed dump command from destination '%s': %v", t.dest, err)
+		}
+	}
+}
+
+// ErrCommandNotAllowed is returned for shell commands which are not listed in allowed_commands of the configuration
+var ErrCommandNotAllowed = errors.New("command is not allowed in configuration")
+
+var (
+	allowedCommandsMu sync.RWMutex
+	allowedCommands   = map[string]bool{}
+)
+
+// SetAllowedCommands sets shell commands which dump sources and preconditions may run.
+// Jobs are edited without authentication, so only commands written in the configuration file are run.
+func SetAllowedCommands(commands []string) {
+	allowed := map[string]bool{}
+	for _, c := range commands {
+		if c = strings.TrimSpace(c); c != "" {
+			allowed[c] = true
+		}
+	}
+	allowedCommandsMu.Lock()
+	allowedCommands = allowed
+	allowedCommandsMu.Unlock()
+}
+
+// CommandAllowed checks that the command is listed in the configuration exactly as it is written
+func CommandAllowed(command string) error {
+	allowedCommandsMu.RLock()
+	defer allowedCommandsMu.RUnlock()
+	if !allowedCommands[strings.TrimSpace(command)] {
+		return fmt.Errorf("'%s': %w", command, ErrCommandNotAllowed)
+	}
+	return nil
+}
+
+// shellCommandWaitDelay limits waiting for output of child processes after the cancelled command is killed
+const shellCommandWaitDelay = 5 * time.Second
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.WaitDelay = shellCommandWaitDelay
+	return cmd
+}
+
+// commandName returns name of the executable without path and extension, used as dump file name
+func commandName(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "dump"
+	}
+	name := filepath.Base(fields[0])
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCheckCommandAllowed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses sh commands")
+	}
+	SetAllowedCommands([]string{" true ", "exit 3"})
+	t.Cleanup(func() { SetAllowedCommands(nil) })
+
+	tests := []struct {
+		command    string
+		notAllowed bool
+		fails      bool
+	}{
+		{command: "true"},
+		{command: "exit 3", fails: true},
+		{command: "true; rm -rf /tmp/x", notAllowed: true},
+		{command: "false", notAllowed: true},
+	}
+	for _, tt := range tests {
+		err := CheckCommand(context.Background(), tt.command)
+		if got := errors.Is(err, ErrCommandNotAllowed); got != tt.notAllowed {
+			t.Errorf("CheckCommand(%q) = %v, not allowed %v", tt.command, err, tt.notAllowed)
+		}
+		if (err != nil) != (tt.notAllowed || tt.fails) {
+			t.Errorf("CheckCommand(%q) = %v", tt.command, err)
+		}
+	}
+}
+
+func TestFailedDumpIsRemoved(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses sh commands")
+	}
+	good, failing := "printf 'good dump'", "echo 'partial dump'; exit 1"
+	SetAllowedCommands([]string{good, failing})
+	t.Cleanup(func() { SetAllowedCommands(nil) })
+
+	dests := []string{t.TempDir(), t.TempDir()}
+	opts := BackupOptions{SourceType: SourceTypeCommand}
+	if result := PerformBackup(context.Background(), 1, good, dests, opts); result.Status != "Success" {
+		t.Fatalf("good dump: %s %s", result.Status, result.Message)
+	}
+	if result := PerformBackup(context.Background(), 1, failing, dests, opts); result.Status != "Error" {
+		t.Fatalf("failing dump: %s %s", result.Status, result.Message)
+	}
+
+	for _, dir := range dests {
+		dumps, err := filepath.Glob(filepath.Join(dir, "*.dump"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dumps) != 1 || !strings.HasPrefix(filepath.Base(dumps[0]), "printf-") {
+			t.Fatalf("dumps in '%s' = %v, want only the good one", dir, dumps)
+		}
+		data, err := os.ReadFile(dumps[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "good dump" {
+			t.Errorf("dump has %q, want %q", data, "good dump")
+		}
+	}
+}
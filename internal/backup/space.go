@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Space policies decide what to do when destination has not enough free space before the run
+const (
+	SpacePolicyAbort = "abort" // do not start the copy
+	SpacePolicyPrune = "prune" // remove files of the job which are out of retention policy and check again
+)
+
+const StatusInsufficientSpace = "Insufficient space"
+
+var ErrInsufficientSpace = errors.New("insufficient free space on destination")
+
+// SpaceReporter is implemented by destinations which can tell how much free space they have
+type SpaceReporter interface {
+	FreeSpace(ctx context.Context) (int64, error)
+}
+
+func (d *LocalDestination) FreeSpace(ctx context.Context) (int64, error) {
+	// Destination folder can be not created yet, so the nearest existing parent is checked
+	path := filepath.Clean(d.Root)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return diskFreeSpace(path)
+}
+
+// EstimateRequiredBytes returns how many bytes the run will add to the destination.
+// For local destinations files which are not changed (same size and modification time) are not counted,
+// and for changed files only the growth against the existing copy is counted.
+// Single file source is compared with its copy at fileRelPath, empty fileRelPath is the destination path itself.
+func EstimateRequiredBytes(sourcePath, fileRelPath string, dest Destination) (int64, error) {
+	local, ok := dest.(*LocalDestination)
+	if !ok {
+		return GetDirSIze(sourcePath)
+	}
+
+	srcInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return 0, fmt.Errorf("access to source error '%s': %w", sourcePath, err)
+	}
+	if !srcInfo.IsDir() {
+		return changedBytes(srcInfo, filepath.Join(local.Root, filepath.FromSlash(fileRelPath))), nil
+	}
+
+	var required int64
+	err = filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isManifestDir(sourcePath, path, info) {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(sourcePath, path)
+		if err != nil {
+			return err
+		}
+		required += changedBytes(info, filepath.Join(local.Root, rel))
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error during estimating backup size '%s': %w", sourcePath, err)
+	}
+	return required, nil
+}
+
+func changedBytes(srcInfo os.FileInfo, dstPath string) int64 {
+	dstInfo, err := os.Stat(dstPath)
+	if err != nil {
+		return srcInfo.Size()
+	}
+	if srcInfo.ModTime().Equal(dstInfo.ModTime()) && srcInfo.Size() == dstInfo.Size() {
+		return 0
+	}
+	if grow := srcInfo.Size() - dstInfo.Size(); grow > 0 {
+		return grow
+	}
+	return 0
+}
+
+// checkFreeSpace makes sure destination can take the backup and still keep minFreeBytes free.
+// Destinations which can't report free space (e.g. cloud storage) are not checked.
+// fileRelPath is where the copy puts single file source, see EstimateRequiredBytes.
+func checkFreeSpace(ctx context.Context, jobID int, sourcePath, fileRelPath string, dest Destination, opts BackupOptions) error {
+	reporter, ok := dest.(SpaceReporter)
+	if !ok {
+		return nil
+	}
+
+	// Size of command output is not known before the run, only the reserve is checked
+	var required int64
+	if opts.SourceType != SourceTypeCommand {
+		var err error
+		required, err = EstimateRequiredBytes(sourcePath, fileRelPath, dest)
+		if err != nil {
+			return err
+		}
+	}
+
+	free, err := reporter.FreeSpace(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get free space of '%s': %w", dest, err)
+	}
+
+	if free-required >= opts.MinFreeBytes {
+		return nil
+	}
+
+	if opts.SpacePolicy == SpacePolicyPrune {
+		if pruner, ok := dest.(Pruner); ok {
+			log.Printf("Not enough space on '%s' for job ID %d (free %d, required %d, reserve %d), pruning files older than %s",
+				dest, jobID, free, required, opts.MinFreeBytes, opts.Retention)
+			freed, err := pruner.Prune(ctx, jobID, sourcePath, opts.SourceType, opts.Retention)
+			if err != nil {
+				return err
+			}
+			log.Printf("Pruned %d bytes on '%s' for job ID %d", freed, dest, jobID)
+
+			free, err = reporter.FreeSpace(ctx)
+			if err != nil {
+				return fmt.Errorf("can't get free space of '%s': %w", dest, err)
+			}
+			if free-required >= opts.MinFreeBytes {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: '%s' has %d bytes free, backup requires %d bytes and reserve is %d bytes",
+		ErrInsufficientSpace, dest, free, required, opts.MinFreeBytes)
+}
@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestEstimateSingleFileAfterCopy checks that the file copied by the previous run is found,
+// so unchanged single file source requires no space on both copy paths
+func TestEstimateSingleFileAfterCopy(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(source, []byte(strings.Repeat("x", 4096)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("local copy", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "copy.bin")
+		if result := PerformBackup(context.Background(), 1, source, []string{target}, BackupOptions{}); result.Status != "Success" {
+			t.Fatalf("backup: %s %s", result.Status, result.Message)
+		}
+		if _, err := os.Stat(target); err != nil {
+			t.Fatalf("file is not copied to the destination path: %v", err)
+		}
+
+		required, err := EstimateRequiredBytes(source, "", &LocalDestination{Root: target})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if required != 0 {
+			t.Errorf("required = %d bytes for copied file, want 0", required)
+		}
+	})
+
+	t.Run("fan-out copy", func(t *testing.T) {
+		targets := []string{t.TempDir(), t.TempDir()}
+		if result := PerformBackup(context.Background(), 1, source, targets, BackupOptions{}); result.Status != "Success" {
+			t.Fatalf("backup: %s %s", result.Status, result.Message)
+		}
+
+		for _, target := range targets {
+			required, err := EstimateRequiredBytes(source, filepath.Base(source), &LocalDestination{Root: target})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if required != 0 {
+				t.Errorf("required = %d bytes for file copied to '%s', want 0", required, target)
+			}
+		}
+	})
+
+	t.Run("missing copy", func(t *testing.T) {
+		required, err := EstimateRequiredBytes(source, "", &LocalDestination{Root: filepath.Join(t.TempDir(), "copy.bin")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if required != 4096 {
+			t.Errorf("required = %d bytes, want size of the file", required)
+		}
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package backup
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func diskFreeSpace(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, fmt.Errorf("statfs '%s': %w", path, err)
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
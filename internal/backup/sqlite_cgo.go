@@ -0,0 +1,115 @@
+//go:build cgo
+
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	sqliteBackupPagesPerStep = 256
+
+	// Writer can keep the database locked, the snapshot waits for it this many times before it fails
+	sqliteBusyRetries = 600
+	sqliteBusyDelay   = 100 * time.Millisecond
+)
+
+// snapshotSQLite copies live SQLite database into dir using online backup API,
+// so the copy is consistent even when database is written during the backup.
+func snapshotSQLite(ctx context.Context, dbPath, dir string) (string, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return "", fmt.Errorf("access to SQLite database error '%s': %w", dbPath, err)
+	}
+
+	snapshotPath := filepath.Join(dir, filepath.Base(dbPath))
+
+	srcDB, err := sql.Open("sqlite3", "file:"+filepath.ToSlash(dbPath)+"?mode=ro")
+	if err != nil {
+		return "", fmt.Errorf("can't open SQLite database '%s': %w", dbPath, err)
+	}
+	defer srcDB.Close()
+
+	dstDB, err := sql.Open("sqlite3", snapshotPath)
+	if err != nil {
+		return "", fmt.Errorf("can't create SQLite snapshot '%s': %w", snapshotPath, err)
+	}
+	defer dstDB.Close()
+
+	srcConn, err := srcDB.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't connect to SQLite database '%s': %w", dbPath, err)
+	}
+	defer srcConn.Close()
+
+	dstConn, err := dstDB.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't connect to SQLite snapshot '%s': %w", snapshotPath, err)
+	}
+	defer dstConn.Close()
+
+	err = dstConn.Raw(func(dstDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			dst, ok := dstDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected SQLite driver connection %T", dstDriverConn)
+			}
+			src, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected SQLite driver connection %T", srcDriverConn)
+			}
+
+			b, err := dst.Backup("main", src, "main")
+			if err != nil {
+				return fmt.Errorf("can't start SQLite backup: %w", err)
+			}
+
+			busy := 0
+			for {
+				done, err := b.Step(sqliteBackupPagesPerStep)
+				if err != nil {
+					if !sqliteBusy(err) || busy >= sqliteBusyRetries {
+						b.Finish()
+						return fmt.Errorf("SQLite backup step error: %w", err)
+					}
+					// Database is locked by writer, try again a bit later
+					busy++
+					select {
+					case <-ctx.Done():
+						b.Finish()
+						return ctx.Err()
+					case <-time.After(sqliteBusyDelay):
+					}
+					continue
+				}
+				busy = 0
+				if done {
+					break
+				}
+				if err := ctx.Err(); err != nil {
+					b.Finish()
+					return err
+				}
+			}
+			return b.Finish()
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("error making snapshot of SQLite database '%s': %w", dbPath, err)
+	}
+
+	return snapshotPath, nil
+}
+
+// sqliteBusy tells if SQLite error is about database locked by another connection
+func sqliteBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
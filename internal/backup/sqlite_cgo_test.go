@@ -0,0 +1,29 @@
+//go:build cgo
+
+package backup
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestClassifySQLiteError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"locked", fmt.Errorf("snapshot: %w", sqlite3.Error{Code: sqlite3.ErrLocked}), ErrorClassTransient},
+		{"busy", fmt.Errorf("snapshot: %w", sqlite3.Error{Code: sqlite3.ErrBusy}), ErrorClassTransient},
+		{"corrupt", fmt.Errorf("snapshot: %w", sqlite3.Error{Code: sqlite3.ErrCorrupt}), ErrorClassOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !cgo
+
+package backup
+
+import (
+	"context"
+	"errors"
+)
+
+// SQLite driver needs cgo, without it SQLite sources can't be backed up
+var errSQLiteWithoutCgo = errors.New("SQLite snapshot is not supported, backup-app is built without cgo")
+
+func snapshotSQLite(ctx context.Context, dbPath, dir string) (string, error) {
+	return "", errSQLiteWithoutCgo
+}
+
+func sqliteBusy(err error) bool {
+	return false
+}
@@ -16,7 +16,8 @@ func InitDB(dbPath string) (*sql.DB, error) {
 		return nil, fmt.Errorf("DB directory creation error '%s': %w", dbDir, err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath+"?_loc=auto")
+	// Busy timeout lets several instances share the database file
+	db, err := sql.Open("sqlite3", dbPath+"?_loc=auto&_busy_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("database open error '%s': %w", dbPath, err)
 	}
@@ -89,6 +90,194 @@ func runMigrations(db *sql.DB) error {
 			CREATE INDEX idx_backup_runs_job_id ON backup_runs(job_id);
 			CREATE INDEX idx_backup_runs_status ON backup_runs(status);
 		`,
+		4: `
+			ALTER TABLE backup_jobs ADD COLUMN destination_policy TEXT NOT NULL DEFAULT 'all';
+			CREATE TABLE backup_job_destinations (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_id INTEGER NOT NULL,
+				destination_path TEXT NOT NULL,
+				position INTEGER NOT NULL DEFAULT 0,
+				last_run_status TEXT,
+				last_run_message TEXT,
+				last_run_files INTEGER,
+				last_run_bytes INTEGER,
+				last_run_time DATETIME,
+				UNIQUE (job_id, destination_path),
+				FOREIGN KEY (job_id) REFERENCES backup_jobs(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_backup_job_destinations_job_id ON backup_job_destinations(job_id);
+			INSERT INTO backup_job_destinations (job_id, destination_path, position)
+				SELECT id, destination_path, 0 FROM backup_jobs;
+		`,
+		5: `
+			ALTER TABLE backup_jobs ADD COLUMN space_policy TEXT NOT NULL DEFAULT 'abort';
+			ALTER TABLE backup_jobs ADD COLUMN min_free_mb INTEGER NOT NULL DEFAULT 0;
+		`,
+		6: `
+			ALTER TABLE backup_jobs ADD COLUMN kind TEXT NOT NULL DEFAULT 'backup';
+			ALTER TABLE backup_jobs ADD COLUMN replica_of_job_id INTEGER;
+			CREATE TABLE backup_replicas (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				source_job_id INTEGER NOT NULL,
+				replication_job_id INTEGER NOT NULL,
+				source_path TEXT NOT NULL,
+				destination_path TEXT NOT NULL,
+				source_run_time DATETIME,
+				replicated_at DATETIME NOT NULL,
+				files_count INTEGER NOT NULL DEFAULT 0,
+				bytes_count INTEGER NOT NULL DEFAULT 0,
+				FOREIGN KEY (source_job_id) REFERENCES backup_jobs(id) ON DELETE CASCADE,
+				FOREIGN KEY (replication_job_id) REFERENCES backup_jobs(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_backup_replicas_source_job_id ON backup_replicas(source_job_id);
+		`,
+		7: `
+			ALTER TABLE backup_jobs ADD COLUMN source_type TEXT NOT NULL DEFAULT 'files';
+		`,
+		8: `
+			ALTER TABLE backup_runs ADD COLUMN trigger_source TEXT NOT NULL DEFAULT 'manual';
+			ALTER TABLE backup_runs ADD COLUMN files_count INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE backup_runs ADD COLUMN bytes_count INTEGER NOT NULL DEFAULT 0;
+			CREATE INDEX idx_backup_runs_start_time ON backup_runs(job_id, start_time);
+		`,
+		9: `
+			ALTER TABLE backup_jobs ADD COLUMN overlap_policy TEXT NOT NULL DEFAULT 'skip';
+		`,
+		10: `
+			ALTER TABLE backup_jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+		`,
+		11: `
+			ALTER TABLE backup_jobs ADD COLUMN retry_max_attempts INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE backup_jobs ADD COLUMN retry_delay_seconds INTEGER NOT NULL DEFAULT 60;
+			ALTER TABLE backup_jobs ADD COLUMN retry_backoff REAL NOT NULL DEFAULT 2;
+			ALTER TABLE backup_jobs ADD COLUMN retry_on TEXT NOT NULL DEFAULT 'transient';
+			ALTER TABLE backup_runs ADD COLUMN parent_run_id INTEGER;
+			ALTER TABLE backup_runs ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
+			CREATE INDEX idx_backup_runs_parent_run_id ON backup_runs(parent_run_id);
+		`,
+		12: `
+			ALTER TABLE backup_jobs ADD COLUMN missed_run_policy TEXT NOT NULL DEFAULT 'skip';
+			ALTER TABLE backup_jobs ADD COLUMN missed_grace_minutes INTEGER NOT NULL DEFAULT 60;
+		`,
+		13: `
+			CREATE TABLE backup_job_dependencies (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_id INTEGER NOT NULL,
+				depends_on_job_id INTEGER NOT NULL,
+				condition TEXT NOT NULL DEFAULT 'success',
+				UNIQUE (job_id, depends_on_job_id),
+				FOREIGN KEY (job_id) REFERENCES backup_jobs(id) ON DELETE CASCADE,
+				FOREIGN KEY (depends_on_job_id) REFERENCES backup_jobs(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_backup_job_dependencies_depends_on ON backup_job_dependencies(depends_on_job_id);
+		`,
+		14: `
+			ALTER TABLE backup_jobs ADD COLUMN window_start TEXT NOT NULL DEFAULT '';
+			ALTER TABLE backup_jobs ADD COLUMN window_end TEXT NOT NULL DEFAULT '';
+			ALTER TABLE backup_jobs ADD COLUMN blackout_dates TEXT NOT NULL DEFAULT '';
+			ALTER TABLE backup_jobs ADD COLUMN window_end_action TEXT NOT NULL DEFAULT 'cancel';
+			ALTER TABLE backup_jobs ADD COLUMN max_runtime_minutes INTEGER NOT NULL DEFAULT 0;
+		`,
+		15: `
+			ALTER TABLE backup_jobs ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+		`,
+		16: `
+			ALTER TABLE backup_jobs ADD COLUMN watch_changes BOOLEAN NOT NULL DEFAULT 0;
+			ALTER TABLE backup_jobs ADD COLUMN watch_debounce_seconds INTEGER NOT NULL DEFAULT 10;
+			ALTER TABLE backup_jobs ADD COLUMN watch_min_interval_seconds INTEGER NOT NULL DEFAULT 300;
+		`,
+		17: `
+			ALTER TABLE backup_jobs ADD COLUMN schedule_config TEXT NOT NULL DEFAULT '';
+		`,
+		18: `
+			CREATE TABLE settings (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			);
+		`,
+		19: `
+			CREATE TABLE scheduler_leases (
+				name TEXT PRIMARY KEY,
+				holder TEXT NOT NULL,
+				expires_at INTEGER NOT NULL
+			);
+			CREATE TABLE run_claims (
+				job_id INTEGER NOT NULL,
+				trigger TEXT NOT NULL,
+				fire_time TEXT NOT NULL,
+				holder TEXT NOT NULL,
+				claimed_at TEXT NOT NULL,
+				PRIMARY KEY (job_id, trigger, fire_time)
+			);
+		`,
+		20: `
+			ALTER TABLE backup_jobs ADD COLUMN schedule_action TEXT NOT NULL DEFAULT 'full';
+			CREATE TABLE backup_job_schedules (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_id INTEGER NOT NULL,
+				schedule TEXT NOT NULL,
+				schedule_config TEXT NOT NULL DEFAULT '',
+				action TEXT NOT NULL DEFAULT 'full',
+				position INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE INDEX idx_backup_job_schedules_job_id ON backup_job_schedules (job_id);
+			ALTER TABLE backup_runs ADD COLUMN action TEXT NOT NULL DEFAULT 'full';
+		`,
+		21: `
+			ALTER TABLE backup_runs ADD COLUMN instance TEXT NOT NULL DEFAULT '';
+		`,
+		22: `
+			CREATE TABLE backup_job_preconditions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_id INTEGER NOT NULL,
+				type TEXT NOT NULL,
+				value TEXT NOT NULL,
+				position INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE INDEX idx_backup_job_preconditions_job_id ON backup_job_preconditions (job_id);
+		`,
+		23: `
+			ALTER TABLE backup_jobs ADD COLUMN retention_runs INTEGER NOT NULL DEFAULT 7;
+			ALTER TABLE backup_jobs ADD COLUMN retention_days INTEGER NOT NULL DEFAULT 0;
+		`,
+		24: `
+			CREATE TABLE backup_job_schedules_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_id INTEGER NOT NULL,
+				schedule TEXT NOT NULL,
+				schedule_config TEXT NOT NULL DEFAULT '',
+				action TEXT NOT NULL DEFAULT 'full',
+				position INTEGER NOT NULL DEFAULT 0,
+				FOREIGN KEY (job_id) REFERENCES backup_jobs(id) ON DELETE CASCADE
+			);
+			INSERT INTO backup_job_schedules_new (id, job_id, schedule, schedule_config, action, position)
+				SELECT id, job_id, schedule, schedule_config, action, position FROM backup_job_schedules
+				WHERE job_id IN (SELECT id FROM backup_jobs);
+			DROP TABLE backup_job_schedules;
+			ALTER TABLE backup_job_schedules_new RENAME TO backup_job_schedules;
+			CREATE INDEX idx_backup_job_schedules_job_id ON backup_job_schedules (job_id);
+
+			CREATE TABLE backup_job_preconditions_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_id INTEGER NOT NULL,
+				type TEXT NOT NULL,
+				value TEXT NOT NULL,
+				position INTEGER NOT NULL DEFAULT 0,
+				FOREIGN KEY (job_id) REFERENCES backup_jobs(id) ON DELETE CASCADE
+			);
+			INSERT INTO backup_job_preconditions_new (id, job_id, type, value, position)
+				SELECT id, job_id, type, value, position FROM backup_job_preconditions
+				WHERE job_id IN (SELECT id FROM backup_jobs);
+			DROP TABLE backup_job_preconditions;
+			ALTER TABLE backup_job_preconditions_new RENAME TO backup_job_preconditions;
+			CREATE INDEX idx_backup_job_preconditions_job_id ON backup_job_preconditions (job_id);
+		`,
+		25: `
+			ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0;
+		`,
+		26: `
+			ALTER TABLE backup_jobs ADD COLUMN replica_run_id INTEGER NOT NULL DEFAULT 0;
+		`,
 	}
 
 	for version := currentVersion + 1; ; version++ {
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Dependency conditions decide when finished upstream job starts the dependent job
+const (
+	DependencyOnSuccess    = "success"    // upstream run finished with Success
+	DependencyOnCompletion = "completion" // upstream run finished with any result except cancelled, paused or skipped
+)
+
+var ErrDependencyCycle = errors.New("job dependencies make a cycle")
+
+// JobDependency means job JobID runs after job DependsOnJobID
+type JobDependency struct {
+	ID             int    `json:"id" db:"id"`
+	JobID          int    `json:"job_id" db:"job_id"`
+	DependsOnJobID int    `json:"depends_on_job_id" db:"depends_on_job_id"`
+	Condition      string `json:"condition" db:"condition"`
+}
+
+// Satisfied tells if upstream run with given status starts the dependent job
+func (d JobDependency) Satisfied(status string) bool {
+	switch status {
+	case "Success":
+		return true
+	case "", RunStatusQueued, RunStatusRunning, RunStatusSkipped, RunStatusInterrupted, "Cancelled", "Paused":
+		return false
+	}
+	return d.Condition == DependencyOnCompletion
+}
+
+// DependencyCondition returns condition of dependency on upstream job, empty string when job doesn't depend on it
+func (j *BackupJob) DependencyCondition(upstreamJobID int) string {
+	for _, d := range j.Dependencies {
+		if d.DependsOnJobID == upstreamJobID {
+			return d.Condition
+		}
+	}
+	return ""
+}
+
+const jobDependencyColumns = `id, job_id, depends_on_job_id, condition`
+
+func scanJobDependencies(rows *sql.Rows) ([]JobDependency, error) {
+	defer rows.Close()
+
+	var deps []JobDependency
+	for rows.Next() {
+		var d JobDependency
+		if err := rows.Scan(&d.ID, &d.JobID, &d.DependsOnJobID, &d.Condition); err != nil {
+			return nil, fmt.Errorf("error scanning dependency row: %w", err)
+		}
+		deps = append(deps, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration dependency rows: %w", err)
+	}
+	return deps, nil
+}
+
+// setJobDependencies replaces upstream jobs of the job. Saving is refused when new dependencies make a cycle.
+func setJobDependencies(tx *sql.Tx, jobID int, deps []JobDependency) error {
+	rows, err := tx.Query(`SELECT ` + jobDependencyColumns + ` FROM backup_job_dependencies;`)
+	if err != nil {
+		return fmt.Errorf("error getting job dependencies: %w", err)
+	}
+	all, err := scanJobDependencies(rows)
+	if err != nil {
+		return err
+	}
+
+	upstreams := map[int][]int{}
+	for _, d := range all {
+		if d.JobID != jobID {
+			upstreams[d.JobID] = append(upstreams[d.JobID], d.DependsOnJobID)
+		}
+	}
+	for _, d := range deps {
+		upstreams[jobID] = append(upstreams[jobID], d.DependsOnJobID)
+	}
+	if cycle := findDependencyCycle(upstreams, jobID); cycle != nil {
+		var ids []string
+		for _, id := range cycle {
+			ids = append(ids, fmt.Sprintf("%d", id))
+		}
+		return fmt.Errorf("%w: job IDs %s", ErrDependencyCycle, strings.Join(ids, " -> "))
+	}
+
+	if _, err := tx.Exec(`DELETE FROM backup_job_dependencies WHERE job_id = ?;`, jobID); err != nil {
+		return fmt.Errorf("error deleting dependencies of job ID %d: %w", jobID, err)
+	}
+	for _, d := range deps {
+		if d.Condition != DependencyOnCompletion {
+			d.Condition = DependencyOnSuccess
+		}
+		_, err := tx.Exec(`INSERT INTO backup_job_dependencies (job_id, depends_on_job_id, condition) VALUES (?, ?, ?);`,
+			jobID, d.DependsOnJobID, d.Condition)
+		if err != nil {
+			return fmt.Errorf("error saving dependency of job ID %d on job ID %d: %w", jobID, d.DependsOnJobID, err)
+		}
+	}
+	return nil
+}
+
+// findDependencyCycle walks upstream jobs from start and returns the path which comes back to start
+func findDependencyCycle(upstreams map[int][]int, start int) []int {
+	visited := map[int]bool{}
+	var walk func(id int, path []int) []int
+	walk = func(id int, path []int) []int {
+		for _, up := range upstreams[id] {
+			if up == start {
+				return append(path, up)
+			}
+			if visited[up] {
+				continue
+			}
+			visited[up] = true
+			if cycle := walk(up, append(path, up)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return walk(start, []int{start})
+}
+
+// GetJobDependencies returns upstream jobs of the job
+func (r *JobRepo) GetJobDependencies(jobID int) ([]JobDependency, error) {
+	rows, err := r.db.Query(`SELECT `+jobDependencyColumns+` FROM backup_job_dependencies WHERE job_id = ? ORDER BY id;`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting dependencies of job ID %d: %w", jobID, err)
+	}
+	return scanJobDependencies(rows)
+}
+
+// GetDependentJobs returns dependencies where the job is upstream, i.e. jobs which run after it
+func (r *JobRepo) GetDependentJobs(jobID int) ([]JobDependency, error) {
+	rows, err := r.db.Query(`SELECT `+jobDependencyColumns+` FROM backup_job_dependencies WHERE depends_on_job_id = ? ORDER BY id;`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting dependent jobs of job ID %d: %w", jobID, err)
+	}
+	return scanJobDependencies(rows)
+}
+
+func (r *JobRepo) getAllJobDependencies() (map[int][]JobDependency, error) {
+	rows, err := r.db.Query(`SELECT ` + jobDependencyColumns + ` FROM backup_job_dependencies ORDER BY job_id, id;`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting job dependencies: %w", err)
+	}
+	all, err := scanJobDependencies(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := map[int][]JobDependency{}
+	for _, d := range all {
+		deps[d.JobID] = append(deps[d.JobID], d)
+	}
+	return deps, nil
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		upstreams map[int][]int
+		start     int
+		want      []int
+	}{
+		{name: "no dependencies", upstreams: map[int][]int{}, start: 1},
+		{name: "chain", upstreams: map[int][]int{1: {2}, 2: {3}}, start: 1},
+		{name: "diamond", upstreams: map[int][]int{1: {2, 3}, 2: {4}, 3: {4}}, start: 1},
+		{name: "self", upstreams: map[int][]int{1: {1}}, start: 1, want: []int{1, 1}},
+		{name: "two jobs", upstreams: map[int][]int{1: {2}, 2: {1}}, start: 1, want: []int{1, 2, 1}},
+		{name: "long cycle", upstreams: map[int][]int{1: {2}, 2: {3}, 3: {4}, 4: {1}}, start: 1, want: []int{1, 2, 3, 4, 1}},
+		{name: "cycle after shared upstream", upstreams: map[int][]int{1: {2, 3}, 2: {4}, 3: {4}, 4: {1}}, start: 1, want: []int{1, 2, 4, 1}},
+		{name: "cycle not through start", upstreams: map[int][]int{1: {2}, 2: {3}, 3: {2}}, start: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findDependencyCycle(tt.upstreams, tt.start); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findDependencyCycle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
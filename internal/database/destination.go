@@ -0,0 +1,143 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// secretParams are query parameters of destination URLs which can hold credentials
+var secretParams = []string{"key", "sas", "sig"}
+
+// RedactDestination hides credentials written in the destination URL, references to environment variables are kept.
+// Destinations are shown in pages, API and logs, jobs created before credentials were moved to environment can still have them.
+func RedactDestination(spec string) string {
+	if !strings.Contains(spec, "://") || !strings.Contains(spec, "?") {
+		return spec
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return strings.SplitN(spec, "?", 2)[0]
+	}
+	query := u.Query()
+	redacted := false
+	for _, name := range secretParams {
+		if value := query.Get(name); value != "" && !strings.HasPrefix(value, "env:") {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return spec
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// JobDestination is one of the targets job writes to, with the result of the last run for this target.
+type JobDestination struct {
+	ID              int            `json:"id" db:"id"`
+	JobID           int            `json:"job_id" db:"job_id"`
+	DestinationPath string         `json:"destination_path" db:"destination_path"`
+	Position        int            `json:"position" db:"position"`
+	LastRunStatus   sql.NullString `json:"last_run_status" db:"last_run_status"`
+	LastRunMessage  sql.NullString `json:"last_run_message" db:"last_run_message"`
+	LastRunFiles    sql.NullInt64  `json:"last_run_files" db:"last_run_files"`
+	LastRunBytes    sql.NullInt64  `json:"last_run_bytes" db:"last_run_bytes"`
+	LastRunTime     sql.NullTime   `json:"last_run_time" db:"last_run_time"`
+}
+
+// DisplayPath is the destination with credentials hidden
+func (d JobDestination) DisplayPath() string {
+	return RedactDestination(d.DestinationPath)
+}
+
+const jobDestinationColumns = `id, job_id, destination_path, position, last_run_status, last_run_message,
+			last_run_files, last_run_bytes, last_run_time`
+
+func scanJobDestination(row rowScanner) (*JobDestination, error) {
+	var d JobDestination
+	err := row.Scan(&d.ID, &d.JobID, &d.DestinationPath, &d.Position, &d.LastRunStatus, &d.LastRunMessage,
+		&d.LastRunFiles, &d.LastRunBytes, &d.LastRunTime)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// setJobDestinations replaces job destinations list, keeping last run results of destinations which stay.
+func setJobDestinations(tx *sql.Tx, jobID int, paths []string) error {
+	if _, err := tx.Exec(`UPDATE backup_job_destinations SET position = -1 WHERE job_id = ?;`, jobID); err != nil {
+		return fmt.Errorf("error resetting destinations for job ID %d: %w", jobID, err)
+	}
+
+	for i, path := range paths {
+		_, err := tx.Exec(`INSERT INTO backup_job_destinations (job_id, destination_path, position) VALUES (?, ?, ?)
+			ON CONFLICT(job_id, destination_path) DO UPDATE SET position = excluded.position;`, jobID, path, i)
+		if err != nil {
+			return fmt.Errorf("error saving destination '%s' for job ID %d: %w", path, jobID, err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM backup_job_destinations WHERE job_id = ? AND position = -1;`, jobID); err != nil {
+		return fmt.Errorf("error deleting old destinations for job ID %d: %w", jobID, err)
+	}
+	return nil
+}
+
+func (r *JobRepo) GetJobDestinations(jobID int) ([]JobDestination, error) {
+	rows, err := r.db.Query(`SELECT `+jobDestinationColumns+` FROM backup_job_destinations
+			WHERE job_id = ? ORDER BY position;`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting destinations for job ID %d: %w", jobID, err)
+	}
+	defer rows.Close()
+
+	var destinations []JobDestination
+	for rows.Next() {
+		d, err := scanJobDestination(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning destination row: %w", err)
+		}
+		destinations = append(destinations, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration destination rows: %w", err)
+	}
+	return destinations, nil
+}
+
+func (r *JobRepo) getAllJobDestinations() (map[int][]JobDestination, error) {
+	rows, err := r.db.Query(`SELECT ` + jobDestinationColumns + ` FROM backup_job_destinations ORDER BY job_id, position;`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting job destinations: %w", err)
+	}
+	defer rows.Close()
+
+	destinations := map[int][]JobDestination{}
+	for rows.Next() {
+		d, err := scanJobDestination(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning destination row: %w", err)
+		}
+		destinations[d.JobID] = append(destinations[d.JobID], *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration destination rows: %w", err)
+	}
+	return destinations, nil
+}
+
+func (r *JobRepo) UpdateDestinationResult(jobID int, destinationPath, status, message string, files int, bytes int64, runTime time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE backup_job_destinations
+		SET last_run_status = ?, last_run_message = ?, last_run_files = ?, last_run_bytes = ?, last_run_time = ?
+		WHERE job_id = ? AND destination_path = ?;
+	`, status, message, files, bytes, runTime, jobID, destinationPath)
+	if err != nil {
+		return fmt.Errorf("error updating destination '%s' result for job ID %d: %w", destinationPath, jobID, err)
+	}
+	return nil
+}
@@ -1,24 +1,242 @@
 package database
 
 import (
+	"backup-app/internal/schedule"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
+// Destination policies decide overall run status when job has several destinations
+const (
+	DestinationPolicyAll = "all" // run is successful only when every destination succeeded
+	DestinationPolicyAny = "any" // run is successful when at least one destination succeeded
+)
+
+// Job kinds
+const (
+	JobKindBackup      = "backup"      // copies source path to destinations
+	JobKindReplication = "replication" // copies the latest successful backup of another job to destinations
+)
+
+// Source types, for sqlite SourcePath is database file and for command it is dump command line
+const (
+	SourceTypeFiles   = "files"
+	SourceTypeSQLite  = "sqlite"
+	SourceTypeCommand = "command"
+)
+
+// Space policies decide what happens when destination has not enough free space before the run
+const (
+	SpacePolicyAbort = "abort"
+	SpacePolicyPrune = "prune"
+)
+
+// Files gone from the source are kept for this number of runs when retention is not set
+const DefaultRetentionRuns = 7
+
+// Overlap policies decide what happens when job is started while its previous run is still working
+const (
+	OverlapPolicySkip   = "skip"   // new run is rejected
+	OverlapPolicyQueue  = "queue"  // new run waits for the current one, only one run can wait
+	OverlapPolicyCancel = "cancel" // current run is cancelled and new one starts
+)
+
+// Missed run policies decide what happens on startup with scheduled runs missed while the server was down
+const (
+	MissedRunSkip    = "skip"     // missed runs are forgotten
+	MissedRunRunOnce = "run_once" // one catch-up run is started, no matter how many runs were missed
+	MissedRunGrace   = "grace"    // catch-up run is started only when the last missed run is not older than grace window
+)
+
+// Window end actions decide what happens with the run which still works when backup window closes
+const (
+	WindowEndCancel = "cancel" // run is stopped with Timed out status
+	WindowEndPause  = "pause"  // run is stopped and started again when the window opens
+)
+
 type BackupJob struct {
-	ID              int            `json:"id" db:"id"`
-	Name            string         `json:"name" db:"name"`
-	SourcePath      string         `json:"source_path" db:"source_path"`
-	DestinationPath string         `json:"destination_path" db:"destination_path"`
-	Schedule        string         `json:"schedule" db:"schedule"`
-	IsActive        bool           `json:"is_active" db:"is_active"`
-	CreatedAt       sql.NullTime   `json:"created_at" db:"created_at"`
-	UpdatedAt       sql.NullTime   `json:"updated_at" db:"updated_at"`
-	LastRunStatus   sql.NullString `json:"last_run_status" db:"last_run_status"`
-	LastRunTime     sql.NullTime   `json:"last_run_time" db:"last_run_time"`
+	ID              int    `json:"id" db:"id"`
+	Name            string `json:"name" db:"name"`
+	SourcePath      string `json:"source_path" db:"source_path"`
+	DestinationPath string `json:"destination_path" db:"destination_path"`
+	Schedule        string `json:"schedule" db:"schedule"`
+	// Schedule settings as they are edited in the forms, Schedule is cron spec made from them
+	ScheduleConfig    schedule.Schedule `json:"schedule_config" db:"schedule_config"`
+	IsActive          bool              `json:"is_active" db:"is_active"`
+	CreatedAt         sql.NullTime      `json:"created_at" db:"created_at"`
+	UpdatedAt         sql.NullTime      `json:"updated_at" db:"updated_at"`
+	LastRunStatus     sql.NullString    `json:"last_run_status" db:"last_run_status"`
+	LastRunTime       sql.NullTime      `json:"last_run_time" db:"last_run_time"`
+	DestinationPolicy string            `json:"destination_policy" db:"destination_policy"`
+	Destinations      []JobDestination  `json:"destinations" db:"-"`
+	SpacePolicy       string            `json:"space_policy" db:"space_policy"`
+	MinFreeMB         int64             `json:"min_free_mb" db:"min_free_mb"`
+	Kind              string            `json:"kind" db:"kind"`
+	ReplicaOfJobID    sql.NullInt64     `json:"replica_of_job_id" db:"replica_of_job_id"`
+	SourceType        string            `json:"source_type" db:"source_type"`
+	OverlapPolicy     string            `json:"overlap_policy" db:"overlap_policy"`
+	// Files gone from the source are kept while they were seen by one of the last RetentionRuns runs
+	// or during the last RetentionDays days, 0 disables the rule
+	RetentionRuns int `json:"retention_runs" db:"retention_runs"`
+	RetentionDays int `json:"retention_days" db:"retention_days"`
+	// Run of the source job which replication job copies, 0 copies the latest completed run
+	ReplicaRunID int `json:"replica_run_id" db:"replica_run_id"`
+	// Priority in the run queue, bigger goes first
+	Priority int `json:"priority" db:"priority"`
+	// Retry policy, RetryMaxAttempts is number of retries after the failed run (0 disables retries)
+	RetryMaxAttempts  int     `json:"retry_max_attempts" db:"retry_max_attempts"`
+	RetryDelaySeconds int     `json:"retry_delay_seconds" db:"retry_delay_seconds"`
+	RetryBackoff      float64 `json:"retry_backoff" db:"retry_backoff"`
+	RetryOn           string  `json:"retry_on" db:"retry_on"` // comma separated error classes
+	// Upstream jobs, the job is started when they finish
+	Dependencies       []JobDependency `json:"dependencies" db:"-"`
+	MissedRunPolicy    string          `json:"missed_run_policy" db:"missed_run_policy"`
+	MissedGraceMinutes int             `json:"missed_grace_minutes" db:"missed_grace_minutes"`
+	// Backup window "15:04", empty allows runs at any time of day
+	WindowStart string `json:"window_start" db:"window_start"`
+	WindowEnd   string `json:"window_end" db:"window_end"`
+	// Days when runs are not allowed, e.g. "2025-12-31, 2026-01-01..2026-01-07"
+	BlackoutDates   string `json:"blackout_dates" db:"blackout_dates"`
+	WindowEndAction string `json:"window_end_action" db:"window_end_action"`
+	// Run is stopped with Timed out status after this time, 0 means no limit
+	MaxRuntimeMinutes int `json:"max_runtime_minutes" db:"max_runtime_minutes"`
+	// IANA time zone of schedule and backup window, empty is the server local time
+	TimeZone string `json:"time_zone" db:"time_zone"`
+	// Changes in source directory start incremental run of changed paths,
+	// after changes stop for debounce time and not more often than minimal interval
+	WatchChanges            bool `json:"watch_changes" db:"watch_changes"`
+	WatchDebounceSeconds    int  `json:"watch_debounce_seconds" db:"watch_debounce_seconds"`
+	WatchMinIntervalSeconds int  `json:"watch_min_interval_seconds" db:"watch_min_interval_seconds"`
+	// Action of the runs started by the main schedule
+	ScheduleAction string `json:"schedule_action" db:"schedule_action"`
+	// Additional schedules, each with its own action
+	Schedules []JobSchedule `json:"schedules" db:"-"`
+	// Checks before every run, the run is skipped when one of them fails
+	Preconditions []JobPrecondition `json:"preconditions" db:"-"`
+}
+
+// LoadLocation returns IANA time zone by name, empty name is the server local time
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone '%s': %w", name, err)
+	}
+	return loc, nil
+}
+
+// RetryDelay returns delay before the retry with given number (starting from 1)
+func (j *BackupJob) RetryDelay(retry int) time.Duration {
+	delay := time.Duration(j.RetryDelaySeconds) * time.Second
+	for i := 1; i < retry; i++ {
+		delay = time.Duration(float64(delay) * j.RetryBackoff)
+	}
+	return delay
+}
+
+// RetriesOn tells if failed run with given error class is retried
+func (j *BackupJob) RetriesOn(class string) bool {
+	for _, c := range strings.Split(j.RetryOn, ",") {
+		if strings.TrimSpace(c) == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *BackupJob) IsReplication() bool {
+	return j.Kind == JobKindReplication
+}
+
+// DestinationPaths returns all job destinations, the main DestinationPath is always the first one.
+func (j *BackupJob) DestinationPaths() []string {
+	paths := []string{j.DestinationPath}
+	for _, d := range j.Destinations {
+		if d.DestinationPath != j.DestinationPath {
+			paths = append(paths, d.DestinationPath)
+		}
+	}
+	return paths
+}
+
+// DisplayDestination is the main destination with credentials hidden
+func (j *BackupJob) DisplayDestination() string {
+	return RedactDestination(j.DestinationPath)
+}
+
+// DisplayDestinationPaths returns all job destinations with credentials hidden
+func (j *BackupJob) DisplayDestinationPaths() []string {
+	var paths []string
+	for _, p := range j.DestinationPaths() {
+		paths = append(paths, RedactDestination(p))
+	}
+	return paths
 }
 
+func (j *BackupJob) setDefaults() {
+	if j.ScheduleConfig.Kind == "" {
+		j.ScheduleConfig = schedule.FromSpec(j.Schedule)
+	} else if spec, err := j.ScheduleConfig.Spec(); err == nil {
+		j.Schedule = spec
+	}
+	if j.DestinationPolicy == "" {
+		j.DestinationPolicy = DestinationPolicyAll
+	}
+	if j.Kind == "" {
+		j.Kind = JobKindBackup
+	}
+	if j.SourceType == "" || j.Kind == JobKindReplication {
+		j.SourceType = SourceTypeFiles
+	}
+	if j.SpacePolicy == "" {
+		j.SpacePolicy = SpacePolicyAbort
+	}
+	if j.RetentionRuns < 0 {
+		j.RetentionRuns = 0
+	}
+	if j.RetentionDays < 0 {
+		j.RetentionDays = 0
+	}
+	if j.OverlapPolicy == "" {
+		j.OverlapPolicy = OverlapPolicySkip
+	}
+	if j.RetryDelaySeconds <= 0 {
+		j.RetryDelaySeconds = 60
+	}
+	if j.RetryBackoff < 1 {
+		j.RetryBackoff = 1
+	}
+	if j.MissedRunPolicy == "" {
+		j.MissedRunPolicy = MissedRunSkip
+	}
+	if j.MissedGraceMinutes <= 0 {
+		j.MissedGraceMinutes = 60
+	}
+	if j.WindowEndAction == "" {
+		j.WindowEndAction = WindowEndCancel
+	}
+	if j.MaxRuntimeMinutes < 0 {
+		j.MaxRuntimeMinutes = 0
+	}
+	if j.WatchDebounceSeconds <= 0 {
+		j.WatchDebounceSeconds = 10
+	}
+	if j.WatchMinIntervalSeconds < 0 {
+		j.WatchMinIntervalSeconds = 0
+	}
+	if j.ScheduleAction == "" {
+		j.ScheduleAction = ActionFull
+	}
+}
+
+// ErrJobNotFound is returned when backup job is not in the database, e.g. it was deleted
+var ErrJobNotFound = errors.New("not found")
+
 type JobRepo struct {
 	db *sql.DB
 }
@@ -27,17 +245,74 @@ func NewJobRepo(db *sql.DB) *JobRepo {
 	return &JobRepo{db: db}
 }
 
-func (r *JobRepo) CreateJob(name, sourcePath, destinationPath, schedule string, isActive bool) (*BackupJob, error) {
+const jobColumns = `id, name, source_path, destination_path, schedule, is_active, created_at, updated_at,
+			last_run_status, last_run_time, destination_policy, space_policy, min_free_mb,
+			kind, replica_of_job_id, source_type, overlap_policy, priority,
+			retry_max_attempts, retry_delay_seconds, retry_backoff, retry_on, missed_run_policy, missed_grace_minutes,
+			window_start, window_end, blackout_dates, window_end_action, max_runtime_minutes, time_zone,
+			watch_changes, watch_debounce_seconds, watch_min_interval_seconds, schedule_config, schedule_action,
+			retention_runs, retention_days, replica_run_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*BackupJob, error) {
+	var job BackupJob
+	var createdAtStr, updatedAtStr, scheduleConfig string
+	err := row.Scan(&job.ID, &job.Name, &job.SourcePath, &job.DestinationPath, &job.Schedule, &job.IsActive,
+		&createdAtStr, &updatedAtStr, &job.LastRunStatus, &job.LastRunTime, &job.DestinationPolicy,
+		&job.SpacePolicy, &job.MinFreeMB, &job.Kind, &job.ReplicaOfJobID, &job.SourceType, &job.OverlapPolicy, &job.Priority,
+		&job.RetryMaxAttempts, &job.RetryDelaySeconds, &job.RetryBackoff, &job.RetryOn, &job.MissedRunPolicy, &job.MissedGraceMinutes,
+		&job.WindowStart, &job.WindowEnd, &job.BlackoutDates, &job.WindowEndAction, &job.MaxRuntimeMinutes, &job.TimeZone,
+		&job.WatchChanges, &job.WatchDebounceSeconds, &job.WatchMinIntervalSeconds, &scheduleConfig, &job.ScheduleAction,
+		&job.RetentionRuns, &job.RetentionDays, &job.ReplicaRunID)
+	if err != nil {
+		return nil, err
+	}
+	job.ScheduleConfig = schedule.Unmarshal(scheduleConfig, job.Schedule)
+
+	parsedCreatedAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at for task with ID %d: %w", job.ID, err)
+	}
+	job.CreatedAt = sql.NullTime{Time: parsedCreatedAt, Valid: true}
+
+	parsedUpdatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated_at for task with ID %d: %w", job.ID, err)
+	}
+	job.UpdatedAt = sql.NullTime{Time: parsedUpdatedAt, Valid: true}
+
+	return &job, nil
+}
+
+func (r *JobRepo) CreateJob(job *BackupJob) (*BackupJob, error) {
 	now := time.Now()
+	job.setDefaults()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction for job '%s': %w", job.Name, err)
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO backup_jobs (name, source_path, destination_path, schedule, is_active, created_at, updated_at,
-				last_run_status, last_run_time)
-				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
-	result, err := r.db.Exec(query, name, sourcePath, destinationPath, schedule, isActive,
-		sql.NullTime{Time: now, Valid: true}.Time.Format(time.RFC3339Nano),
-		sql.NullTime{Time: now, Valid: true}.Time.Format(time.RFC3339Nano),
-		sql.NullString{}, sql.NullTime{})
+				last_run_status, last_run_time, destination_policy, space_policy, min_free_mb, kind, replica_of_job_id,
+				source_type, overlap_policy, priority, retry_max_attempts, retry_delay_seconds, retry_backoff, retry_on,
+				missed_run_policy, missed_grace_minutes, window_start, window_end, blackout_dates, window_end_action,
+				max_runtime_minutes, time_zone, watch_changes, watch_debounce_seconds, watch_min_interval_seconds, schedule_config,
+				schedule_action, retention_runs, retention_days, replica_run_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	result, err := tx.Exec(query, job.Name, job.SourcePath, job.DestinationPath, job.Schedule, job.IsActive,
+		now.Format(time.RFC3339Nano), now.Format(time.RFC3339Nano),
+		sql.NullString{}, sql.NullTime{}, job.DestinationPolicy, job.SpacePolicy, job.MinFreeMB, job.Kind, job.ReplicaOfJobID,
+		job.SourceType, job.OverlapPolicy, job.Priority, job.RetryMaxAttempts, job.RetryDelaySeconds, job.RetryBackoff, job.RetryOn,
+		job.MissedRunPolicy, job.MissedGraceMinutes, job.WindowStart, job.WindowEnd, job.BlackoutDates, job.WindowEndAction,
+		job.MaxRuntimeMinutes, job.TimeZone, job.WatchChanges, job.WatchDebounceSeconds, job.WatchMinIntervalSeconds,
+		job.ScheduleConfig.Marshal(), job.ScheduleAction, job.RetentionRuns, job.RetentionDays, job.ReplicaRunID)
 	if err != nil {
-		return nil, fmt.Errorf("backup job insert error '%s': %w", name, err)
+		return nil, fmt.Errorf("backup job insert error '%s': %w", job.Name, err)
 	}
 
 	id, err := result.LastInsertId()
@@ -45,139 +320,150 @@ func (r *JobRepo) CreateJob(name, sourcePath, destinationPath, schedule string,
 		return nil, fmt.Errorf("getting ID of new backup task error: %w", err)
 	}
 
-	/* return &BackupJob{
-		ID:              int(id),
-		Name:            name,
-		SourcePath:      sourcePath,
-		DestinationPath: destinationPath,
-		Schedule:        schedule,
-		IsActive:        isActive,
-		CreatedAt:       sql.NullTime{Time: now, Valid: true},
-		UpdatedAt:       sql.NullTime{Time: now, Valid: true},
-	}, nil */
-	return r.GetJobByID((int(id)))
+	job.ID = int(id)
+	if err := setJobDestinations(tx, job.ID, job.DestinationPaths()); err != nil {
+		return nil, err
+	}
+	if err := setJobDependencies(tx, job.ID, job.Dependencies); err != nil {
+		return nil, err
+	}
+	if err := setJobSchedules(tx, job.ID, job.Schedules); err != nil {
+		return nil, err
+	}
+	if err := setJobPreconditions(tx, job.ID, job.Preconditions); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error commiting backup job '%s': %w", job.Name, err)
+	}
 
+	return r.GetJobByID(job.ID)
 }
 
 func (r *JobRepo) GetJobByID(id int) (*BackupJob, error) {
-	var job BackupJob
-	var createdAtStr, updatedAtStr string
-	var lastRunStatus sql.NullString
-	var LastRunTime sql.NullTime
-	query := `SELECT id, name, source_path, destination_path, schedule, is_active, created_at, updated_at,
-			last_run_status, last_run_time
-			FROM backup_jobs WHERE id = ?;`
-	row := r.db.QueryRow(query, id)
-
-	err := row.Scan(&job.ID, &job.Name, &job.SourcePath, &job.DestinationPath, &job.Schedule, &job.IsActive, &createdAtStr, &updatedAtStr, &lastRunStatus, &LastRunTime)
+	row := r.db.QueryRow(`SELECT `+jobColumns+` FROM backup_jobs WHERE id = ?;`, id)
+
+	job, err := scanJob(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("backup task with ID %d not found", id)
+			return nil, fmt.Errorf("backup task with ID %d %w", id, ErrJobNotFound)
 		}
 		return nil, fmt.Errorf("error getting backup task with ID %d: %w", id, err)
 	}
 
-	parseCreatedAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	job.Destinations, err = r.GetJobDestinations(job.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing created_at for task with ID %d: %w", id, err)
+		return nil, err
 	}
-	job.CreatedAt = sql.NullTime{Time: parseCreatedAt, Valid: true}
-
-	parseUpdatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
+	job.Dependencies, err = r.GetJobDependencies(job.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing updated_at for task with ID %d: %w", id, err)
+		return nil, err
+	}
+	job.Schedules, err = r.GetJobSchedules(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	job.Preconditions, err = r.GetJobPreconditions(job.ID)
+	if err != nil {
+		return nil, err
 	}
-	job.UpdatedAt = sql.NullTime{Time: parseUpdatedAt, Valid: true}
-
-	job.LastRunStatus = lastRunStatus
-	job.LastRunTime = LastRunTime
 
-	return &job, nil
+	return job, nil
 }
 
 func (r *JobRepo) GetJobByName(name string) (*BackupJob, error) {
-	var job BackupJob
-	var createdAtStr, updatedAtStr string
-	var lastRunStatus sql.NullString
-	var lastRunTime sql.NullTime
-	query := `SELECT id, name, source_path, destination_path, schedule, is_active, created_at, updated_at. last_run_status, last_run_time
-			FROM backup_jobs WHERE name = ?;`
-	row := r.db.QueryRow(query, name)
-
-	err := row.Scan(&job.ID, &job.Name, &job.SourcePath, &job.DestinationPath, &job.Schedule, &job.IsActive, &createdAtStr, &updatedAtStr, &lastRunStatus, &lastRunTime)
+	row := r.db.QueryRow(`SELECT `+jobColumns+` FROM backup_jobs WHERE name = ?;`, name)
+
+	job, err := scanJob(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("backup task with '%s' not found", name)
+			return nil, fmt.Errorf("backup task with '%s' %w", name, ErrJobNotFound)
 		}
 		return nil, fmt.Errorf("error getting backup task '%s': %w", name, err)
 	}
 
-	parseCreatedAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	job.Destinations, err = r.GetJobDestinations(job.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing created_at for task '%s': %w", name, err)
+		return nil, err
 	}
-	job.CreatedAt = sql.NullTime{Time: parseCreatedAt, Valid: true}
-
-	parseUpdatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
+	job.Dependencies, err = r.GetJobDependencies(job.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing updated_at for task '%s': %w", name, err)
+		return nil, err
+	}
+	job.Schedules, err = r.GetJobSchedules(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	job.Preconditions, err = r.GetJobPreconditions(job.ID)
+	if err != nil {
+		return nil, err
 	}
-	job.UpdatedAt = sql.NullTime{Time: parseUpdatedAt, Valid: true}
-
-	job.LastRunStatus = lastRunStatus
-	job.LastRunTime = lastRunTime
 
-	return &job, nil
+	return job, nil
 }
 
-/* func (r *JobRepo) UpdateJob(job *BackupJob) error {
-	job.UpdatedAt = sql.NullTime{Time: time.Now(), Valid: true}
-	query := `UPDATE backup_jobs SET
-				name = ?, source_path = ?, destination_path = ?,
-				schedule = ?, is_active = ?, updated_at = ?
-				WHERE id = ?;`
-
-	result, err := r.db.Exec(query, job.Name, job.SourcePath, job.DestinationPath, job.Schedule, job.IsActive,
-		job.UpdatedAt.Time.Format("2006-01-02 15:04:05.000"), job.ID)
-	if err != nil {
-		return fmt.Errorf("error updating backup task '%s' (ID %d):%w", job.Name, job.ID, err)
-	}
+func (r *JobRepo) UpdateJob(job *BackupJob) (*BackupJob, error) {
+	job.setDefaults()
 
-	rowsAffected, err := result.RowsAffected()
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error getting updated rows quantity: %w", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("backup task with ID %d not found for update", job.ID)
+		return nil, fmt.Errorf("error starting transaction for job ID %d: %w", job.ID, err)
 	}
+	defer tx.Rollback()
 
-	return nil
-} */
-
-func (r *JobRepo) UpdateJob(id int, name, sourcePath, destinationPath, schedule string, isActive bool) (*BackupJob, error) {
-	stmt, err := r.db.Prepare(`
+	updatedAt := time.Now()
+	_, err = tx.Exec(`
 		UPDATE backup_jobs
 		SET name = ?, source_path = ?, destination_path = ?, schedule = ?,
-		is_active = ?, updated_at = ?
+		is_active = ?, updated_at = ?, destination_policy = ?, space_policy = ?, min_free_mb = ?,
+		kind = ?, replica_of_job_id = ?, source_type = ?, overlap_policy = ?, priority = ?,
+		retry_max_attempts = ?, retry_delay_seconds = ?, retry_backoff = ?, retry_on = ?,
+		missed_run_policy = ?, missed_grace_minutes = ?, window_start = ?, window_end = ?, blackout_dates = ?,
+		window_end_action = ?, max_runtime_minutes = ?, time_zone = ?, watch_changes = ?, watch_debounce_seconds = ?,
+		watch_min_interval_seconds = ?, schedule_config = ?, schedule_action = ?, retention_runs = ?, retention_days = ?,
+		replica_run_id = ?
 		WHERE id = ?;
-	`)
+	`, job.Name, job.SourcePath, job.DestinationPath, job.Schedule, job.IsActive,
+		updatedAt.Format(time.RFC3339Nano), job.DestinationPolicy, job.SpacePolicy, job.MinFreeMB,
+		job.Kind, job.ReplicaOfJobID, job.SourceType, job.OverlapPolicy, job.Priority,
+		job.RetryMaxAttempts, job.RetryDelaySeconds, job.RetryBackoff, job.RetryOn,
+		job.MissedRunPolicy, job.MissedGraceMinutes, job.WindowStart, job.WindowEnd, job.BlackoutDates,
+		job.WindowEndAction, job.MaxRuntimeMinutes, job.TimeZone, job.WatchChanges, job.WatchDebounceSeconds,
+		job.WatchMinIntervalSeconds, job.ScheduleConfig.Marshal(), job.ScheduleAction, job.RetentionRuns, job.RetentionDays,
+		job.ReplicaRunID, job.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error prepear UPDATE request: %w", err)
+		return nil, fmt.Errorf("error executing UPDATE request: %w", err)
 	}
-	defer stmt.Close()
 
-	updatedAt := time.Now()
-	_, err = stmt.Exec(name, sourcePath, destinationPath, schedule, isActive, updatedAt.Format(time.RFC3339Nano), id)
-	if err != nil {
-		return nil, fmt.Errorf("error executing UPDATE request: %w", err)
+	if err := setJobDestinations(tx, job.ID, job.DestinationPaths()); err != nil {
+		return nil, err
+	}
+	if err := setJobDependencies(tx, job.ID, job.Dependencies); err != nil {
+		return nil, err
+	}
+	if err := setJobSchedules(tx, job.ID, job.Schedules); err != nil {
+		return nil, err
+	}
+	if err := setJobPreconditions(tx, job.ID, job.Preconditions); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error commiting update of job ID %d: %w", job.ID, err)
 	}
 
-	return r.GetJobByID(id)
+	return r.GetJobByID(job.ID)
 }
 
 func (r *JobRepo) DeleteJob(id int) error {
-	query := `DELETE FROM backup_jobs WHERE id = ?;`
-	result, err := r.db.Exec(query, id)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction for deleting job ID %d: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM backup_jobs WHERE id = ?;`, id)
 	if err != nil {
 		return fmt.Errorf("error deleting backup task with ID %d: %w", id, err)
 	}
@@ -190,13 +476,30 @@ func (r *JobRepo) DeleteJob(id int) error {
 		return fmt.Errorf("backup task with ID %d not found for deleting", id)
 	}
 
+	// SQLite foreign keys are disabled by default, so related rows are removed here
+	related := []struct{ what, query string }{
+		{"destinations", `DELETE FROM backup_job_destinations WHERE job_id = ?;`},
+		{"replicas", `DELETE FROM backup_replicas WHERE source_job_id = ?1 OR replication_job_id = ?1;`},
+		{"dependencies", `DELETE FROM backup_job_dependencies WHERE job_id = ?1 OR depends_on_job_id = ?1;`},
+		{"schedules", `DELETE FROM backup_job_schedules WHERE job_id = ?;`},
+		{"preconditions", `DELETE FROM backup_job_preconditions WHERE job_id = ?;`},
+		{"runs", `DELETE FROM backup_runs WHERE job_id = ?;`},
+		{"run claims", `DELETE FROM run_claims WHERE job_id = ?;`},
+	}
+	for _, rel := range related {
+		if _, err := tx.Exec(rel.query, id); err != nil {
+			return fmt.Errorf("error deleting %s of backup task with ID %d: %w", rel.what, id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error commiting deletion of job ID %d: %w", id, err)
+	}
 	return nil
 }
 
 func (r *JobRepo) GetAllJobs() ([]BackupJob, error) {
-	query := `SELECT id, name, source_path, destination_path, schedule, is_active, created_at, updated_at, last_run_status, last_run_time 
-	FROM backup_jobs;`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(`SELECT ` + jobColumns + ` FROM backup_jobs;`)
 	if err != nil {
 		return nil, fmt.Errorf("erro getting all bakup jobs: %w", err)
 	}
@@ -204,37 +507,40 @@ func (r *JobRepo) GetAllJobs() ([]BackupJob, error) {
 
 	var jobs []BackupJob
 	for rows.Next() {
-		var job BackupJob
-		var createdAtStr, updatedAtStr string
-		var lastRunStatus sql.NullString
-		var lastRunTime sql.NullTime
-		if err := rows.Scan(&job.ID, &job.Name, &job.SourcePath, &job.DestinationPath,
-			&job.Schedule, &job.IsActive, &createdAtStr, &updatedAtStr, &lastRunStatus, &lastRunTime); err != nil {
-			return nil, fmt.Errorf("erro scaning row of backup task: %w", err)
-		}
-
-		parsedCreatedAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing created_at for task: %w", err)
-		}
-		job.CreatedAt = sql.NullTime{Time: parsedCreatedAt, Valid: true}
-
-		parsedUpdatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
+		job, err := scanJob(rows)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing updated_at for task: %w", err)
+			return nil, fmt.Errorf("erro scaning row of backup task: %w", err)
 		}
-		job.UpdatedAt = sql.NullTime{Time: parsedUpdatedAt, Valid: true}
-
-		job.LastRunStatus = lastRunStatus
-		job.LastRunTime = lastRunTime
-
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error duirng iteration backup tasks rows: %w", err)
 	}
 
+	destinations, err := r.getAllJobDestinations()
+	if err != nil {
+		return nil, err
+	}
+	dependencies, err := r.getAllJobDependencies()
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := r.getAllJobSchedules()
+	if err != nil {
+		return nil, err
+	}
+	preconditions, err := r.getAllJobPreconditions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		jobs[i].Destinations = destinations[jobs[i].ID]
+		jobs[i].Dependencies = dependencies[jobs[i].ID]
+		jobs[i].Schedules = schedules[jobs[i].ID]
+		jobs[i].Preconditions = preconditions[jobs[i].ID]
+	}
+
 	return jobs, nil
 }
 
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Precondition types, the run is skipped when any precondition of the job fails
+const (
+	PreconditionMount     = "mount"      // path is a mount point, e.g. USB disk is connected
+	PreconditionFile      = "file"       // marker file exists
+	PreconditionReachable = "reachable"  // host:port accepts TCP connections
+	PreconditionFreeSpace = "free_space" // MB free on the path, or on every local destination when path is not set
+	PreconditionCommand   = "command"    // shell command exits with 0
+)
+
+// JobPrecondition is checked before every run of the job
+type JobPrecondition struct {
+	ID    int    `json:"id" db:"id"`
+	JobID int    `json:"job_id" db:"job_id"`
+	Type  string `json:"type" db:"type"`
+	Value string `json:"value" db:"value"`
+}
+
+// String is the precondition as it is written in the forms, type and value
+func (p JobPrecondition) String() string {
+	return p.Type + " " + p.Value
+}
+
+// Validate checks that value of the precondition fits its type
+func (p JobPrecondition) Validate() error {
+	if p.Value == "" {
+		return fmt.Errorf("precondition '%s' has no value", p.Type)
+	}
+	switch p.Type {
+	case PreconditionMount, PreconditionFile, PreconditionCommand:
+	case PreconditionReachable:
+		if _, _, err := net.SplitHostPort(p.Value); err != nil {
+			return fmt.Errorf("precondition 'reachable %s' must be host:port: %w", p.Value, err)
+		}
+	case PreconditionFreeSpace:
+		if _, _, err := p.FreeSpace(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown precondition '%s', use mount, file, reachable, free_space or command", p.Type)
+	}
+	return nil
+}
+
+// FreeSpace returns minimal free MB and path of free_space precondition, value is "MB [path]"
+func (p JobPrecondition) FreeSpace() (minFreeMB int64, path string, err error) {
+	mb, path, _ := strings.Cut(p.Value, " ")
+	minFreeMB, err = strconv.ParseInt(mb, 10, 64)
+	if err != nil || minFreeMB <= 0 {
+		return 0, "", fmt.Errorf("precondition 'free_space %s' must start with positive number of MB", p.Value)
+	}
+	return minFreeMB, strings.TrimSpace(path), nil
+}
+
+// PreconditionsText is the list of preconditions for the forms, one per line
+func (j *BackupJob) PreconditionsText() string {
+	var lines []string
+	for _, p := range j.Preconditions {
+		lines = append(lines, p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+const jobPreconditionColumns = `id, job_id, type, value`
+
+func scanJobPreconditions(rows *sql.Rows) ([]JobPrecondition, error) {
+	defer rows.Close()
+
+	var preconditions []JobPrecondition
+	for rows.Next() {
+		var p JobPrecondition
+		if err := rows.Scan(&p.ID, &p.JobID, &p.Type, &p.Value); err != nil {
+			return nil, fmt.Errorf("error scanning precondition row: %w", err)
+		}
+		preconditions = append(preconditions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration precondition rows: %w", err)
+	}
+	return preconditions, nil
+}
+
+// setJobPreconditions replaces preconditions of the job
+func setJobPreconditions(tx *sql.Tx, jobID int, preconditions []JobPrecondition) error {
+	if _, err := tx.Exec(`DELETE FROM backup_job_preconditions WHERE job_id = ?;`, jobID); err != nil {
+		return fmt.Errorf("error deleting old preconditions for job ID %d: %w", jobID, err)
+	}
+
+	for i, p := range preconditions {
+		_, err := tx.Exec(`INSERT INTO backup_job_preconditions (job_id, type, value, position)
+			VALUES (?, ?, ?, ?);`, jobID, p.Type, p.Value, i)
+		if err != nil {
+			return fmt.Errorf("error saving precondition '%s' for job ID %d: %w", p, jobID, err)
+		}
+	}
+	return nil
+}
+
+func (r *JobRepo) GetJobPreconditions(jobID int) ([]JobPrecondition, error) {
+	rows, err := r.db.Query(`SELECT `+jobPreconditionColumns+` FROM backup_job_preconditions
+			WHERE job_id = ? ORDER BY position;`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting preconditions for job ID %d: %w", jobID, err)
+	}
+	return scanJobPreconditions(rows)
+}
+
+func (r *JobRepo) getAllJobPreconditions() (map[int][]JobPrecondition, error) {
+	rows, err := r.db.Query(`SELECT ` + jobPreconditionColumns + ` FROM backup_job_preconditions ORDER BY job_id, position;`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting job preconditions: %w", err)
+	}
+	all, err := scanJobPreconditions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	preconditions := map[int][]JobPrecondition{}
+	for _, p := range all {
+		preconditions[p.JobID] = append(preconditions[p.JobID], p)
+	}
+	return preconditions, nil
+}
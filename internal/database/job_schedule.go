@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"backup-app/internal/schedule"
+)
+
+// Schedule actions decide what the run does
+const (
+	ActionFull        = "full"        // copy every source file
+	ActionIncremental = "incremental" // copy only files which are missing or changed on destinations
+	ActionVerify      = "verify"      // compare checksums of destination copies with source
+	ActionPrune       = "prune"       // remove files of the job which are out of retention policy from destinations
+)
+
+// ValidAction tells if action is one of the schedule actions
+func ValidAction(action string) bool {
+	switch action {
+	case ActionFull, ActionIncremental, ActionVerify, ActionPrune:
+		return true
+	}
+	return false
+}
+
+// IsBackupAction tells if the action copies source, verify and prune runs only check or clean destinations
+func IsBackupAction(action string) bool {
+	return action == "" || action == ActionFull || action == ActionIncremental
+}
+
+// JobSchedule is additional schedule of the job with its own action,
+// the main schedule of the job uses BackupJob.Schedule and BackupJob.ScheduleAction
+type JobSchedule struct {
+	ID             int               `json:"id" db:"id"`
+	JobID          int               `json:"job_id" db:"job_id"`
+	Schedule       string            `json:"schedule" db:"schedule"`
+	ScheduleConfig schedule.Schedule `json:"schedule_config" db:"schedule_config"`
+	Action         string            `json:"action" db:"action"`
+}
+
+func (s *JobSchedule) setDefaults() {
+	if s.ScheduleConfig.Kind == "" {
+		s.ScheduleConfig = schedule.FromSpec(s.Schedule)
+	} else if spec, err := s.ScheduleConfig.Spec(); err == nil {
+		s.Schedule = spec
+	}
+	if s.Action == "" {
+		s.Action = ActionFull
+	}
+}
+
+// String is the schedule as it is written in the forms, action and cron spec
+func (s JobSchedule) String() string {
+	return s.Action + " " + s.Schedule
+}
+
+// AllSchedules returns main schedule of the job followed by additional ones, ID of the main schedule is 0
+func (j *BackupJob) AllSchedules() []JobSchedule {
+	all := []JobSchedule{{JobID: j.ID, Schedule: j.Schedule, ScheduleConfig: j.ScheduleConfig, Action: j.ScheduleAction}}
+	return append(all, j.Schedules...)
+}
+
+// SchedulesText is the list of additional schedules for the forms, one per line
+func (j *BackupJob) SchedulesText() string {
+	var lines []string
+	for _, s := range j.Schedules {
+		lines = append(lines, s.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+const jobScheduleColumns = `id, job_id, schedule, schedule_config, action`
+
+func scanJobSchedules(rows *sql.Rows) ([]JobSchedule, error) {
+	defer rows.Close()
+
+	var schedules []JobSchedule
+	for rows.Next() {
+		var s JobSchedule
+		var config string
+		if err := rows.Scan(&s.ID, &s.JobID, &s.Schedule, &config, &s.Action); err != nil {
+			return nil, fmt.Errorf("error scanning schedule row: %w", err)
+		}
+		s.ScheduleConfig = schedule.Unmarshal(config, s.Schedule)
+		schedules = append(schedules, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration schedule rows: %w", err)
+	}
+	return schedules, nil
+}
+
+// setJobSchedules replaces additional schedules of the job
+func setJobSchedules(tx *sql.Tx, jobID int, schedules []JobSchedule) error {
+	if _, err := tx.Exec(`DELETE FROM backup_job_schedules WHERE job_id = ?;`, jobID); err != nil {
+		return fmt.Errorf("error deleting old schedules for job ID %d: %w", jobID, err)
+	}
+
+	for i := range schedules {
+		s := &schedules[i]
+		s.setDefaults()
+		_, err := tx.Exec(`INSERT INTO backup_job_schedules (job_id, schedule, schedule_config, action, position)
+			VALUES (?, ?, ?, ?, ?);`, jobID, s.Schedule, s.ScheduleConfig.Marshal(), s.Action, i)
+		if err != nil {
+			return fmt.Errorf("error saving schedule '%s' for job ID %d: %w", s.Schedule, jobID, err)
+		}
+	}
+	return nil
+}
+
+func (r *JobRepo) GetJobSchedules(jobID int) ([]JobSchedule, error) {
+	rows, err := r.db.Query(`SELECT `+jobScheduleColumns+` FROM backup_job_schedules
+			WHERE job_id = ? ORDER BY position;`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting schedules for job ID %d: %w", jobID, err)
+	}
+	return scanJobSchedules(rows)
+}
+
+func (r *JobRepo) getAllJobSchedules() (map[int][]JobSchedule, error) {
+	rows, err := r.db.Query(`SELECT ` + jobScheduleColumns + ` FROM backup_job_schedules ORDER BY job_id, position;`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting job schedules: %w", err)
+	}
+	all, err := scanJobSchedules(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := map[int][]JobSchedule{}
+	for _, s := range all {
+		schedules[s.JobID] = append(schedules[s.JobID], s)
+	}
+	return schedules, nil
+}
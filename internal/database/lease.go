@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Lease is held by one instance at a time, other instances can take it after it expires
+type Lease struct {
+	Name      string    `json:"name"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type LeaseRepo struct {
+	db *sql.DB
+}
+
+func NewLeaseRepo(db *sql.DB) *LeaseRepo {
+	return &LeaseRepo{db: db}
+}
+
+// AcquireLease takes or renews the lease for holder until now+ttl.
+// false is returned when the lease is held by another holder and has not expired.
+func (r *LeaseRepo) AcquireLease(name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO scheduler_leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE scheduler_leases.holder = excluded.holder OR scheduler_leases.expires_at <= ?;
+	`, name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("error acquiring lease '%s': %w", name, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking lease '%s': %w", name, err)
+	}
+	return affected > 0, nil
+}
+
+// ReleaseLease lets other instances take the lease without waiting for its expiration
+func (r *LeaseRepo) ReleaseLease(name, holder string) error {
+	if _, err := r.db.Exec(`DELETE FROM scheduler_leases WHERE name = ? AND holder = ?;`, name, holder); err != nil {
+		return fmt.Errorf("error releasing lease '%s': %w", name, err)
+	}
+	return nil
+}
+
+// GetLease returns current holder of the lease, nil when nobody holds it
+func (r *LeaseRepo) GetLease(name string) (*Lease, error) {
+	lease := Lease{Name: name}
+	var expiresAt int64
+	err := r.db.QueryRow(`SELECT holder, expires_at FROM scheduler_leases WHERE name = ?;`, name).Scan(&lease.Holder, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting lease '%s': %w", name, err)
+	}
+	lease.ExpiresAt = time.UnixMilli(expiresAt)
+	return &lease, nil
+}
+
+// ClaimRun records that holder executes the scheduled run of the job fired at fireTime.
+// false is returned when the run was already claimed, so it is never executed twice.
+func (r *LeaseRepo) ClaimRun(jobID int, trigger string, fireTime time.Time, holder string) (bool, error) {
+	result, err := r.db.Exec(`INSERT OR IGNORE INTO run_claims (job_id, trigger, fire_time, holder, claimed_at) VALUES (?, ?, ?, ?, ?);`,
+		jobID, trigger, fireTime.UTC().Format(time.RFC3339), holder, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, fmt.Errorf("error claiming run of job ID %d at %s: %w", jobID, fireTime.Format(time.RFC3339), err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking claim of job ID %d: %w", jobID, err)
+	}
+	return affected > 0, nil
+}
+
+// PruneRunClaims deletes claims made before the given time
+func (r *LeaseRepo) PruneRunClaims(before time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM run_claims WHERE claimed_at < ?;`, before.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("error deleting old run claims: %w", err)
+	}
+	return nil
+}
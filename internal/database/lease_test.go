@@ -0,0 +1,103 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestLeaseRepo(t *testing.T) *LeaseRepo {
+	t.Helper()
+	db, err := InitDB(filepath.Join(t.TempDir(), "backup.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewLeaseRepo(db)
+}
+
+func TestAcquireLease(t *testing.T) {
+	repo := newTestLeaseRepo(t)
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	ttl := 30 * time.Second
+
+	acquire := func(holder string, at time.Time, want bool) {
+		t.Helper()
+		ok, err := repo.AcquireLease("scheduler", holder, ttl, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Fatalf("AcquireLease(%s, %s) = %v, want %v", holder, at.Format(time.TimeOnly), ok, want)
+		}
+	}
+	holder := func(want string, expiresAt time.Time) {
+		t.Helper()
+		lease, err := repo.GetLease("scheduler")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lease == nil || lease.Holder != want || !lease.ExpiresAt.Equal(expiresAt) {
+			t.Fatalf("lease = %+v, want held by %s until %s", lease, want, expiresAt.Format(time.TimeOnly))
+		}
+	}
+
+	acquire("a", now, true)
+	acquire("b", now.Add(10*time.Second), false)
+	holder("a", now.Add(ttl))
+
+	// Renewal moves expiration, so the lease is still held when the first term ends
+	acquire("a", now.Add(20*time.Second), true)
+	holder("a", now.Add(20*time.Second+ttl))
+	acquire("b", now.Add(ttl), false)
+
+	// Expired lease is taken over by another instance
+	acquire("b", now.Add(20*time.Second+ttl), true)
+	holder("b", now.Add(20*time.Second+2*ttl))
+	acquire("a", now.Add(30*time.Second+ttl), false)
+
+	// Only the holder can release the lease
+	if err := repo.ReleaseLease("scheduler", "a"); err != nil {
+		t.Fatal(err)
+	}
+	holder("b", now.Add(20*time.Second+2*ttl))
+	if err := repo.ReleaseLease("scheduler", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if lease, err := repo.GetLease("scheduler"); err != nil || lease != nil {
+		t.Fatalf("released lease = %+v, %v", lease, err)
+	}
+	acquire("a", now.Add(30*time.Second+ttl), true)
+}
+
+func TestClaimRun(t *testing.T) {
+	repo := newTestLeaseRepo(t)
+	fireTime := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+
+	claim := func(jobID int, trigger string, at time.Time, holder string, want bool) {
+		t.Helper()
+		ok, err := repo.ClaimRun(jobID, trigger, at, holder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Fatalf("ClaimRun(%d, %s, %s, %s) = %v, want %v", jobID, trigger, at.Format(time.RFC3339), holder, ok, want)
+		}
+	}
+
+	claim(1, "cron", fireTime, "a", true)
+	claim(1, "cron", fireTime, "b", false)
+	claim(1, "cron", fireTime, "a", false)
+	// Same moment in another location is the same run
+	claim(1, "cron", fireTime.In(time.FixedZone("EET", 2*3600)), "b", false)
+
+	claim(1, "catchup", fireTime, "b", true)
+	claim(2, "cron", fireTime, "b", true)
+	claim(1, "cron", fireTime.Add(time.Hour), "b", true)
+
+	// Pruned claims can be made again
+	if err := repo.PruneRunClaims(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	claim(1, "cron", fireTime, "b", true)
+}
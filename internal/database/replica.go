@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BackupReplica is a verified copy of job backup made by replication job.
+// Restore can be done from the original destination or from any replica.
+type BackupReplica struct {
+	ID               int          `json:"id" db:"id"`
+	SourceJobID      int          `json:"source_job_id" db:"source_job_id"`
+	ReplicationJobID int          `json:"replication_job_id" db:"replication_job_id"`
+	SourcePath       string       `json:"source_path" db:"source_path"`
+	DestinationPath  string       `json:"destination_path" db:"destination_path"`
+	SourceRunTime    sql.NullTime `json:"source_run_time" db:"source_run_time"`
+	ReplicatedAt     time.Time    `json:"replicated_at" db:"replicated_at"`
+	FilesCount       int          `json:"files_count" db:"files_count"`
+	BytesCount       int64        `json:"bytes_count" db:"bytes_count"`
+}
+
+type ReplicaRepo struct {
+	db *sql.DB
+}
+
+func NewReplicaRepo(db *sql.DB) *ReplicaRepo {
+	return &ReplicaRepo{db: db}
+}
+
+func (r *ReplicaRepo) CreateReplica(replica *BackupReplica) error {
+	result, err := r.db.Exec(`INSERT INTO backup_replicas (source_job_id, replication_job_id, source_path, destination_path,
+				source_run_time, replicated_at, files_count, bytes_count)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		replica.SourceJobID, replica.ReplicationJobID, replica.SourcePath, replica.DestinationPath,
+		replica.SourceRunTime, replica.ReplicatedAt, replica.FilesCount, replica.BytesCount)
+	if err != nil {
+		return fmt.Errorf("replica insert error for job ID %d: %w", replica.SourceJobID, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting ID of new replica error: %w", err)
+	}
+	replica.ID = int(id)
+	return nil
+}
+
+// GetLatestReplicas returns the newest replica for every location where backup of the job was copied to
+func (r *ReplicaRepo) GetLatestReplicas(sourceJobID int) ([]BackupReplica, error) {
+	rows, err := r.db.Query(`SELECT id, source_job_id, replication_job_id, source_path, destination_path,
+				source_run_time, replicated_at, files_count, bytes_count
+			FROM backup_replicas
+			WHERE id IN (SELECT MAX(id) FROM backup_replicas WHERE source_job_id = ? GROUP BY destination_path)
+			ORDER BY replicated_at DESC;`, sourceJobID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting replicas for job ID %d: %w", sourceJobID, err)
+	}
+	defer rows.Close()
+
+	var replicas []BackupReplica
+	for rows.Next() {
+		var rep BackupReplica
+		if err := rows.Scan(&rep.ID, &rep.SourceJobID, &rep.ReplicationJobID, &rep.SourcePath, &rep.DestinationPath,
+			&rep.SourceRunTime, &rep.ReplicatedAt, &rep.FilesCount, &rep.BytesCount); err != nil {
+			return nil, fmt.Errorf("error scanning replica row: %w", err)
+		}
+		replicas = append(replicas, rep)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration replica rows: %w", err)
+	}
+	return replicas, nil
+}
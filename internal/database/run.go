@@ -0,0 +1,342 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Trigger sources of the run
+const (
+	RunTriggerCron       = "cron"
+	RunTriggerManual     = "manual"
+	RunTriggerAPI        = "api"
+	RunTriggerRetry      = "retry"
+	RunTriggerCatchUp    = "catchup"    // run missed while the server was down
+	RunTriggerDependency = "dependency" // upstream job finished
+	RunTriggerResume     = "resume"     // run paused at the end of backup window
+	RunTriggerWatch      = "watch"      // files changed in the source directory
+)
+
+const (
+	RunStatusQueued      = "Queued" // run is accepted and waits for its turn
+	RunStatusRunning     = "Running"
+	RunStatusSkipped     = "Skipped"     // run was not started, the reason is in the message
+	RunStatusInterrupted = "Interrupted" // server stopped while the run was working
+)
+
+// runTimeLayout is the same format which backup_runs.start_time gets by default
+const runTimeLayout = "2006-01-02 15:04:05.000"
+
+// BackupRun is one execution of the backup job
+type BackupRun struct {
+	ID         int          `json:"id" db:"id"`
+	JobID      int          `json:"job_id" db:"job_id"`
+	StartTime  time.Time    `json:"start_time" db:"start_time"`
+	EndTime    sql.NullTime `json:"end_time" db:"end_time"`
+	Status     string       `json:"status" db:"status"`
+	Message    string       `json:"message" db:"message"`
+	Trigger    string       `json:"trigger" db:"trigger_source"`
+	FilesCount int          `json:"files_count" db:"files_count"`
+	BytesCount int64        `json:"bytes_count" db:"bytes_count"`
+	// Retries keep ID of the original run, Attempt is 1 for the original run
+	ParentRunID sql.NullInt64 `json:"parent_run_id" db:"parent_run_id"`
+	Attempt     int           `json:"attempt" db:"attempt"`
+	Action      string        `json:"action" db:"action"`
+	// Instance of the server which owns queued or running run
+	Instance string `json:"instance" db:"instance"`
+}
+
+func (r BackupRun) Duration() time.Duration {
+	if !r.EndTime.Valid {
+		return time.Since(r.StartTime)
+	}
+	return r.EndTime.Time.Sub(r.StartTime)
+}
+
+// IsCompletedBackup tells if the run is full or incremental backup which was started and finished.
+// Run which failed on some destinations still completed on others, results of destinations tell which ones.
+func (r BackupRun) IsCompletedBackup() bool {
+	if !r.EndTime.Valid || r.Status == RunStatusSkipped || r.FilesCount == 0 && r.Status != "Success" {
+		return false
+	}
+	return r.Action == ActionFull || r.Action == ActionIncremental
+}
+
+// RunFilter limits history of the job runs, zero values are not used
+type RunFilter struct {
+	Status  string
+	Trigger string
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Offset  int
+}
+
+type RunRepo struct {
+	db *sql.DB
+	// Instance is saved with every run, so on startup the server finds runs it left unfinished
+	instance string
+}
+
+func NewRunRepo(db *sql.DB) *RunRepo {
+	return &RunRepo{db: db}
+}
+
+// SetInstance sets ID of this server instance which owns new runs
+func (r *RunRepo) SetInstance(instance string) {
+	r.instance = instance
+}
+
+// StartRun opens the run row with Running status, it must be closed with FinishRun
+func (r *RunRepo) StartRun(jobID int, trigger string, startTime time.Time) (*BackupRun, error) {
+	return r.StartAttempt(jobID, trigger, ActionFull, 0, 1, startTime)
+}
+
+// StartAttempt opens the run row for retry of the original run parentRunID
+func (r *RunRepo) StartAttempt(jobID int, trigger, action string, parentRunID, attempt int, startTime time.Time) (*BackupRun, error) {
+	return r.insertRun(jobID, RunStatusRunning, trigger, action, parentRunID, attempt, startTime)
+}
+
+// QueueRun saves accepted run which waits for its turn, it is started with MarkRunStarted.
+// Queued runs are started again when the server restarts before they begin.
+func (r *RunRepo) QueueRun(jobID int, trigger, action string, parentRunID, attempt int, queuedAt time.Time) (*BackupRun, error) {
+	return r.insertRun(jobID, RunStatusQueued, trigger, action, parentRunID, attempt, queuedAt)
+}
+
+func (r *RunRepo) insertRun(jobID int, status, trigger, action string, parentRunID, attempt int, startTime time.Time) (*BackupRun, error) {
+	if action == "" {
+		action = ActionFull
+	}
+	parent := sql.NullInt64{Int64: int64(parentRunID), Valid: parentRunID > 0}
+	result, err := r.db.Exec(`INSERT INTO backup_runs (job_id, start_time, status, trigger_source, parent_run_id, attempt, action, instance)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		jobID, startTime.Local().Format(runTimeLayout), status, trigger, parent, attempt, action, r.instance)
+	if err != nil {
+		return nil, fmt.Errorf("run insert error for job ID %d: %w", jobID, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting ID of new run error: %w", err)
+	}
+
+	return &BackupRun{
+		ID:          int(id),
+		JobID:       jobID,
+		StartTime:   startTime,
+		Status:      status,
+		Trigger:     trigger,
+		ParentRunID: parent,
+		Attempt:     attempt,
+		Action:      action,
+		Instance:    r.instance,
+	}, nil
+}
+
+// MarkRunStarted moves queued run to Running status, start time becomes the time when it really started
+func (r *RunRepo) MarkRunStarted(id int, startTime time.Time) error {
+	_, err := r.db.Exec(`UPDATE backup_runs SET status = ?, start_time = ? WHERE id = ?;`,
+		RunStatusRunning, startTime.Local().Format(runTimeLayout), id)
+	if err != nil {
+		return fmt.Errorf("error starting run ID %d: %w", id, err)
+	}
+	return nil
+}
+
+// GetUnfinishedRuns returns queued and running runs of this instance from the oldest one.
+// Runs saved before instances were recorded belong to any instance.
+func (r *RunRepo) GetUnfinishedRuns() ([]BackupRun, error) {
+	rows, err := r.db.Query(`SELECT `+runColumns+` FROM backup_runs
+			WHERE status IN (?, ?) AND instance IN (?, '') ORDER BY id;`, RunStatusQueued, RunStatusRunning, r.instance)
+	if err != nil {
+		return nil, fmt.Errorf("error getting unfinished runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []BackupRun
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning unfinished run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration runs rows: %w", err)
+	}
+	return runs, nil
+}
+
+// RecordNotStartedRun saves attempt to run the job which was rejected or cancelled before it started
+func (r *RunRepo) RecordNotStartedRun(jobID int, trigger, action string, parentRunID, attempt int, status, message string, runTime time.Time) error {
+	run, err := r.StartAttempt(jobID, trigger, action, parentRunID, attempt, runTime)
+	if err != nil {
+		return err
+	}
+	return r.FinishRun(run.ID, status, message, 0, 0, runTime)
+}
+
+func (r *RunRepo) FinishRun(id int, status, message string, files int, bytes int64, endTime time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE backup_runs
+		SET end_time = ?, status = ?, message = ?, files_count = ?, bytes_count = ?
+		WHERE id = ?;
+	`, endTime.Local().Format(runTimeLayout), status, message, files, bytes, id)
+	if err != nil {
+		return fmt.Errorf("error finishing run ID %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *RunRepo) GetRunByID(id int) (*BackupRun, error) {
+	row := r.db.QueryRow(`SELECT `+runColumns+` FROM backup_runs WHERE id = ?;`, id)
+	run, err := scanRun(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("run with ID %d not found", id)
+		}
+		return nil, fmt.Errorf("error getting run with ID %d: %w", id, err)
+	}
+	return run, nil
+}
+
+// GetLastFinishedRun returns the newest backup run of the job which was started and finished, nil if there is no such run.
+// Verify and prune runs are not counted.
+func (r *RunRepo) GetLastFinishedRun(jobID int) (*BackupRun, error) {
+	row := r.db.QueryRow(`SELECT `+runColumns+` FROM backup_runs
+			WHERE job_id = ? AND end_time IS NOT NULL AND status NOT IN (?, ?) AND action IN (?, ?)
+			ORDER BY end_time DESC, id DESC LIMIT 1;`, jobID, RunStatusRunning, RunStatusSkipped, ActionFull, ActionIncremental)
+	run, err := scanRun(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting last run of job ID %d: %w", jobID, err)
+	}
+	return run, nil
+}
+
+// GetLastRunStart returns start time of the newest run of the job with the action, whatever its status, zero if there is no such run
+func (r *RunRepo) GetLastRunStart(jobID int, action string) (time.Time, error) {
+	var startTime sql.NullString
+	err := r.db.QueryRow(`SELECT MAX(start_time) FROM backup_runs WHERE job_id = ? AND action = ?;`, jobID, action).Scan(&startTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting last %s run of job ID %d: %w", action, jobID, err)
+	}
+	if !startTime.Valid {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(runTimeLayout, startTime.String, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing start time of last %s run of job ID %d: %w", action, jobID, err)
+	}
+	return t, nil
+}
+
+// GetJobRuns returns runs of the job from the newest one and total count of runs matching the filter
+func (r *RunRepo) GetJobRuns(jobID int, filter RunFilter) ([]BackupRun, int, error) {
+	where := []string{"job_id = ?"}
+	args := []any{jobID}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Trigger != "" {
+		where = append(where, "trigger_source = ?")
+		args = append(args, filter.Trigger)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "start_time >= ?")
+		args = append(args, filter.From.Local().Format(runTimeLayout))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "start_time < ?")
+		args = append(args, filter.To.Local().Format(runTimeLayout))
+	}
+	whereSQL := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM backup_runs WHERE `+whereSQL+`;`, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting runs for job ID %d: %w", jobID, err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := r.db.Query(`SELECT `+runColumns+` FROM backup_runs WHERE `+whereSQL+`
+			ORDER BY start_time DESC, id DESC LIMIT ? OFFSET ?;`, append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting runs for job ID %d: %w", jobID, err)
+	}
+	defer rows.Close()
+
+	var runs []BackupRun
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning run of job ID %d: %w", jobID, err)
+		}
+		runs = append(runs, *run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error during iteration runs rows: %w", err)
+	}
+
+	return runs, total, nil
+}
+
+// GetRecentSuccessfulRuns returns up to perAction newest successful runs for every job and action, newest first
+func (r *RunRepo) GetRecentSuccessfulRuns(perAction int) ([]BackupRun, error) {
+	rows, err := r.db.Query(`SELECT `+runColumns+` FROM (
+				SELECT *, ROW_NUMBER() OVER (PARTITION BY job_id, action ORDER BY start_time DESC, id DESC) AS n
+				FROM backup_runs WHERE status = ? AND end_time IS NOT NULL
+			) WHERE n <= ? ORDER BY job_id, start_time DESC, id DESC;`, "Success", perAction)
+	if err != nil {
+		return nil, fmt.Errorf("error getting recent successful runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []BackupRun
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration runs rows: %w", err)
+	}
+	return runs, nil
+}
+
+const runColumns = `id, job_id, start_time, end_time, status, message, trigger_source, files_count, bytes_count,
+			parent_run_id, attempt, action, instance`
+
+func scanRun(row rowScanner) (*BackupRun, error) {
+	var run BackupRun
+	var startTimeStr string
+	var endTimeStr, message sql.NullString
+	err := row.Scan(&run.ID, &run.JobID, &startTimeStr, &endTimeStr, &run.Status, &message,
+		&run.Trigger, &run.FilesCount, &run.BytesCount, &run.ParentRunID, &run.Attempt, &run.Action, &run.Instance)
+	if err != nil {
+		return nil, err
+	}
+	run.Message = message.String
+
+	run.StartTime, err = time.ParseInLocation(runTimeLayout, startTimeStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing start_time for run with ID %d: %w", run.ID, err)
+	}
+	if endTimeStr.Valid {
+		endTime, err := time.ParseInLocation(runTimeLayout, endTimeStr.String, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing end_time for run with ID %d: %w", run.ID, err)
+		}
+		run.EndTime = sql.NullTime{Time: endTime, Valid: true}
+	}
+	return &run, nil
+}
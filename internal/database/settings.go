@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const settingSchedulerPause = "scheduler_pause"
+
+// SchedulerPause is maintenance mode of the scheduler, scheduled runs are skipped while it is active
+type SchedulerPause struct {
+	Since time.Time `json:"since"`
+	// Zero Until means the pause lasts until it is resumed by hand
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// ActiveAt tells if the pause is still active at given time
+func (p *SchedulerPause) ActiveAt(t time.Time) bool {
+	return p != nil && (p.Until.IsZero() || t.Before(p.Until))
+}
+
+// SettingsRepo keeps application settings changed at runtime as key-value pairs
+type SettingsRepo struct {
+	db *sql.DB
+}
+
+func NewSettingsRepo(db *sql.DB) *SettingsRepo {
+	return &SettingsRepo{db: db}
+}
+
+// GetSetting returns value of the setting, empty string when it is not set
+func (r *SettingsRepo) GetSetting(key string) (string, error) {
+	var value string
+	err := r.db.QueryRow(`SELECT value FROM settings WHERE key = ?;`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting setting '%s': %w", key, err)
+	}
+	return value, nil
+}
+
+func (r *SettingsRepo) SetSetting(key, value string) error {
+	_, err := r.db.Exec(`INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value;`, key, value)
+	if err != nil {
+		return fmt.Errorf("error saving setting '%s': %w", key, err)
+	}
+	return nil
+}
+
+func (r *SettingsRepo) DeleteSetting(key string) error {
+	if _, err := r.db.Exec(`DELETE FROM settings WHERE key = ?;`, key); err != nil {
+		return fmt.Errorf("error deleting setting '%s': %w", key, err)
+	}
+	return nil
+}
+
+// GetSchedulerPause returns saved maintenance mode, nil when the scheduler is not paused
+func (r *SettingsRepo) GetSchedulerPause() (*SchedulerPause, error) {
+	value, err := r.GetSetting(settingSchedulerPause)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var pause SchedulerPause
+	if err := json.Unmarshal([]byte(value), &pause); err != nil {
+		return nil, fmt.Errorf("wrong scheduler pause setting: %w", err)
+	}
+	return &pause, nil
+}
+
+// SetSchedulerPause saves maintenance mode, nil pause removes it
+func (r *SettingsRepo) SetSchedulerPause(pause *SchedulerPause) error {
+	if pause == nil {
+		return r.DeleteSetting(settingSchedulerPause)
+	}
+	data, err := json.Marshal(pause)
+	if err != nil {
+		return fmt.Errorf("error encoding scheduler pause: %w", err)
+	}
+	return r.SetSetting(settingSchedulerPause, string(data))
+}
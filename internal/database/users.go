@@ -12,6 +12,7 @@ type User struct {
 	ID           int          `json:"id" db:"id"`
 	Username     string       `json:"username" db:"username"`
 	PasswordHash string       `json:"-" db:"password_hash"`
+	IsAdmin      bool         `json:"is_admin" db:"is_admin"`
 	CreatedAt    sql.NullTime `json:"created_at" db:"created_at"`
 }
 
@@ -52,10 +53,10 @@ func (r *UserRepo) CreateUser(username, password string) (*User, error) {
 func (r *UserRepo) GetUserByUsername(username string) (*User, error) {
 	var user User
 	var createdAtStr string
-	query := `SELECT id, username, password_hash, created_at FROM users WHERE username=?;`
+	query := `SELECT id, username, password_hash, is_admin, created_at FROM users WHERE username=?;`
 	row := r.db.QueryRow(query, username)
 
-	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &createdAtStr)
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.IsAdmin, &createdAtStr)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user '%s' not found", username)
@@ -102,6 +103,28 @@ func (r *UserRepo) UpdateUser(user *User, newPassword string) error {
 	return nil
 }
 
+// SetAdmin gives or takes away admin rights, admins pause the scheduler and run jobs during maintenance
+func (r *UserRepo) SetAdmin(user *User, admin bool) error {
+	_, err := r.db.Exec(`UPDATE users SET is_admin = ? WHERE id = ?;`, admin, user.ID)
+	if err != nil {
+		return fmt.Errorf("user update error '%s': %w", user.Username, err)
+	}
+	user.IsAdmin = admin
+	return nil
+}
+
+// AuthenticateAdmin checks password of the user and that the user is admin
+func (r *UserRepo) AuthenticateAdmin(username, password string) (*User, error) {
+	user, err := r.AuthenticateUser(username, password)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsAdmin {
+		return nil, fmt.Errorf("user '%s' is not admin", username)
+	}
+	return user, nil
+}
+
 func (r *UserRepo) DeleteUser(id int) error {
 	query := `DELETE FROM users WHERE id = ?;`
 	result, err := r.db.Exec(query, id)
@@ -120,7 +143,7 @@ func (r *UserRepo) DeleteUser(id int) error {
 }
 
 func (r *UserRepo) GetAllUsers() ([]User, error) {
-	query := `SELECT id, username, password_hash, created_at FROM users;`
+	query := `SELECT id, username, password_hash, is_admin, created_at FROM users;`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("getting all users error: %w", err)
@@ -132,7 +155,7 @@ func (r *UserRepo) GetAllUsers() ([]User, error) {
 	for rows.Next() {
 		var user User
 		var createdAtStr string
-		if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &createdAtStr); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.IsAdmin, &createdAtStr); err != nil {
 			return nil, fmt.Errorf("user row scanning error: %w", err)
 		}
 
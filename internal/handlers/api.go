@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"backup-app/internal/database"
+	"backup-app/internal/schedule"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// apiJob is job as it is returned by JSON API
+type apiJob struct {
+	ID               int                        `json:"id"`
+	Name             string                     `json:"name"`
+	Kind             string                     `json:"kind"`
+	SourceType       string                     `json:"source_type"`
+	SourcePath       string                     `json:"source_path"`
+	DestinationPaths []string                   `json:"destination_paths"`
+	Schedule         string                     `json:"schedule"`
+	ScheduleConfig   schedule.Schedule          `json:"schedule_config"`
+	ScheduleText     string                     `json:"schedule_description"`
+	ScheduleAction   string                     `json:"schedule_action"`
+	Schedules        []apiJobSchedule           `json:"schedules,omitempty"`
+	TimeZone         string                     `json:"time_zone,omitempty"`
+	IsActive         bool                       `json:"is_active"`
+	Priority         int                        `json:"priority"`
+	Dependencies     []database.JobDependency   `json:"dependencies,omitempty"`
+	Preconditions    []database.JobPrecondition `json:"preconditions,omitempty"`
+	LastRunStatus    string                     `json:"last_run_status,omitempty"`
+	LastRunTime      *time.Time                 `json:"last_run_time,omitempty"`
+	Manual           bool                       `json:"manual"`
+	NextRuns         []time.Time                `json:"next_runs"`
+	ScheduleError    string                     `json:"schedule_error,omitempty"`
+}
+
+// apiJobSchedule is additional schedule of the job with its action
+type apiJobSchedule struct {
+	Action         string            `json:"action"`
+	Schedule       string            `json:"schedule"`
+	ScheduleConfig schedule.Schedule `json:"schedule_config"`
+	ScheduleText   string            `json:"schedule_description"`
+}
+
+func (wh *WebHandlers) toAPIJob(job *database.BackupJob, nextCount int) apiJob {
+	result := apiJob{
+		ID:               job.ID,
+		Name:             job.Name,
+		Kind:             job.Kind,
+		SourceType:       job.SourceType,
+		SourcePath:       job.SourcePath,
+		DestinationPaths: job.DisplayDestinationPaths(),
+		Schedule:         job.Schedule,
+		ScheduleConfig:   job.ScheduleConfig,
+		ScheduleText:     job.ScheduleConfig.String(),
+		ScheduleAction:   job.ScheduleAction,
+		TimeZone:         job.TimeZone,
+		IsActive:         job.IsActive,
+		Priority:         job.Priority,
+		Dependencies:     job.Dependencies,
+		Preconditions:    job.Preconditions,
+		LastRunStatus:    job.LastRunStatus.String,
+		NextRuns:         []time.Time{},
+	}
+	if job.LastRunTime.Valid {
+		result.LastRunTime = &job.LastRunTime.Time
+	}
+	for _, s := range job.Schedules {
+		result.Schedules = append(result.Schedules, apiJobSchedule{
+			Action:         s.Action,
+			Schedule:       s.Schedule,
+			ScheduleConfig: s.ScheduleConfig,
+			ScheduleText:   s.ScheduleConfig.String(),
+		})
+	}
+
+	if wh.NextRunsFunc != nil {
+		runs, err := wh.NextRunsFunc(job, nextCount)
+		switch {
+		case err != nil:
+			result.ScheduleError = err.Error()
+		case len(runs) == 0:
+			result.Manual = true
+		default:
+			result.NextRuns = runs
+		}
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// APIJobsHandler returns all jobs with the next run time
+func (wh *WebHandlers) APIJobsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs, err := wh.JobRepo.GetAllJobs()
+	if err != nil {
+		log.Printf("APIJobsHandler: Error getting backup tasks: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "can't get jobs")
+		return
+	}
+
+	result := []apiJob{}
+	for _, job := range jobs {
+		result = append(result, wh.toAPIJob(&job, 1))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// APIJobHandler returns one job, query parameter next sets how many next run times are returned (default 5)
+func (wh *WebHandlers) APIJobHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	job, err := wh.JobRepo.GetJobByID(jobID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	count := 5
+	if n, err := strconv.Atoi(r.URL.Query().Get("next")); err == nil && n > 0 && n <= 100 {
+		count = n
+	}
+	writeJSON(w, http.StatusOK, wh.toAPIJob(job, count))
+}
+
+// APIJobRunsHandler returns run history of the job.
+// Query parameters: status, trigger, from, to (YYYY-MM-DD), limit (default 50) and offset.
+func (wh *WebHandlers) APIJobRunsHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	if _, err := wh.JobRepo.GetJobByID(jobID); err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	filter, err := runFilterFromQuery(q)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Limit = 50
+	if limit, err := strconv.Atoi(q.Get("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	if offset, err := strconv.Atoi(q.Get("offset")); err == nil && offset > 0 {
+		filter.Offset = offset
+	}
+
+	runs, total, err := wh.RunRepo.GetJobRuns(jobID, filter)
+	if err != nil {
+		log.Printf("APIJobRunsHandler: Error getting runs of job ID %d: %v", jobID, err)
+		writeJSONError(w, http.StatusInternalServerError, "can't get run history")
+		return
+	}
+	if runs == nil {
+		runs = []database.BackupRun{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+		"runs":   runs,
+	})
+}
+
+// APIRunJobHandler starts the job in background, the run is recorded with "api" trigger.
+// 409 is returned when the run is rejected because the job is already running.
+func (wh *WebHandlers) APIRunJobHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	job, err := wh.JobRepo.GetJobByID(jobID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if wh.SchedulerRunFunc == nil {
+		writeJSONError(w, http.StatusInternalServerError, "backup runner is not available")
+		return
+	}
+
+	// During maintenance only admin starts jobs
+	admin := wh.adminUser(r)
+	if admin == nil && wh.pauseState() != nil {
+		if admin = wh.requireAPIAdmin(w, r); admin == nil {
+			return
+		}
+	}
+
+	log.Printf("Starting asynchronous backup for job ID %d from API: %s", job.ID, job.Name)
+	queued, err := wh.SchedulerRunFunc(job, database.RunTriggerAPI, admin != nil)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	status := "started"
+	if queued {
+		status = "queued"
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": job.ID, "status": status})
+}
+
+// APICancelJobHandler stops the working run of the job, 409 is returned when the job is not running
+func (wh *WebHandlers) APICancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	if _, err := wh.JobRepo.GetJobByID(jobID); err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if wh.SchedulerCancelFunc == nil {
+		writeJSONError(w, http.StatusInternalServerError, "backup runner is not available")
+		return
+	}
+
+	if err := wh.SchedulerCancelFunc(jobID); err != nil {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	log.Printf("Cancellation of job ID %d requested from API", jobID)
+	writeJSON(w, http.StatusAccepted, map[string]any{"job_id": jobID, "status": "cancelling"})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"backup-app/internal/database"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Realm of HTTP basic authentication, browsers show it in the login prompt
+const adminRealm = "backup-app admin"
+
+// adminUser returns admin whose credentials are sent with the request, nil when there are no credentials or they are wrong
+func (wh *WebHandlers) adminUser(r *http.Request) *database.User {
+	username, password, ok := r.BasicAuth()
+	if !ok || wh.UserRepo == nil {
+		return nil
+	}
+	user, err := wh.UserRepo.AuthenticateAdmin(username, password)
+	if err != nil {
+		log.Printf("Admin authentication from %s failed: %v", r.RemoteAddr, err)
+		return nil
+	}
+	return user
+}
+
+// requireAdmin returns admin who sent the request, otherwise it asks for admin login and returns nil
+func (wh *WebHandlers) requireAdmin(w http.ResponseWriter, r *http.Request) *database.User {
+	if user := wh.adminUser(r); user != nil {
+		return user
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", adminRealm))
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusUnauthorized)
+	fmt.Fprintf(w, `<div class="message error">Error: admin login is required</div>`)
+	return nil
+}
+
+// requireAPIAdmin is requireAdmin for JSON API
+func (wh *WebHandlers) requireAPIAdmin(w http.ResponseWriter, r *http.Request) *database.User {
+	if user := wh.adminUser(r); user != nil {
+		return user
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", adminRealm))
+	writeJSONError(w, http.StatusUnauthorized, "admin credentials are required")
+	return nil
+}
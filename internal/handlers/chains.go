@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"backup-app/internal/database"
+	"log"
+	"net/http"
+	"path/filepath"
+)
+
+// chainNode is a job in the dependency chain with jobs which run after it
+type chainNode struct {
+	Job       database.BackupJob
+	Condition string
+	Children  []chainNode
+}
+
+// buildChains returns trees of dependent jobs starting from jobs which don't depend on others.
+// Jobs which are not part of any chain are not included.
+func buildChains(jobs []database.BackupJob) []chainNode {
+	byID := map[int]database.BackupJob{}
+	dependents := map[int][]database.JobDependency{}
+	for _, job := range jobs {
+		byID[job.ID] = job
+		for _, dep := range job.Dependencies {
+			dependents[dep.DependsOnJobID] = append(dependents[dep.DependsOnJobID], dep)
+		}
+	}
+
+	var build func(job database.BackupJob, condition string, path map[int]bool) chainNode
+	build = func(job database.BackupJob, condition string, path map[int]bool) chainNode {
+		node := chainNode{Job: job, Condition: condition}
+		path[job.ID] = true
+		for _, dep := range dependents[job.ID] {
+			child, ok := byID[dep.JobID]
+			if !ok || path[child.ID] {
+				continue
+			}
+			node.Children = append(node.Children, build(child, dep.Condition, path))
+		}
+		delete(path, job.ID)
+		return node
+	}
+
+	var chains []chainNode
+	for _, job := range jobs {
+		if len(job.Dependencies) == 0 && len(dependents[job.ID]) > 0 {
+			chains = append(chains, build(job, "", map[int]bool{}))
+		}
+	}
+	return chains
+}
+
+func (wh *WebHandlers) JobChainsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs, err := wh.JobRepo.GetAllJobs()
+	if err != nil {
+		log.Printf("JobChainsHandler: Error getting backup tasks: %v", err)
+		http.Error(w, "Problem with getting list of tasks", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := wh.Templates.Clone()
+	if err != nil {
+		log.Printf("JobChainsHandler: Error template cloning: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err = tmpl.ParseFiles(filepath.Join("web", "templates", "job_chains.html"))
+	if err != nil {
+		log.Printf("JobChainsHandler: Error parsing job_chains.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Chains []chainNode
+	}{
+		Chains: buildChains(jobs),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("JobChainsHandler: Error rendering job_chains.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -3,34 +3,113 @@ package handlers
 import (
 	"backup-app/internal/backup"
 	"backup-app/internal/database"
+	"backup-app/internal/schedule"
+	"backup-app/internal/window"
+	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type WebHandlers struct {
-	Templates           *template.Template
-	UserRepo            *database.UserRepo
-	JobRepo             *database.JobRepo
-	SchedulerReloadFunc func()
+	Templates            *template.Template
+	UserRepo             *database.UserRepo
+	JobRepo              *database.JobRepo
+	ReplicaRepo          *database.ReplicaRepo
+	RunRepo              *database.RunRepo
+	SchedulerReloadFunc  func(jobID int)
+	SchedulerRunFunc     func(job *database.BackupJob, trigger string, admin bool) (queued bool, err error)
+	SchedulerCancelFunc  func(jobID int) error
+	SchedulerPauseFunc   func(until time.Time, reason string) (*database.SchedulerPause, error)
+	SchedulerResumeFunc  func() error
+	PauseStateFunc       func() *database.SchedulerPause
+	LeaderInfoFunc       func() (instanceID string, leader bool)
+	NextRunsFunc         func(job *database.BackupJob, count int) ([]time.Time, error)
+	ValidateScheduleFunc func(job *database.BackupJob) error
+	ScheduleLoadFunc     func(from, to time.Time) (*schedule.Load, error)
 }
 
-func NewWebHandlers(tmpl *template.Template, userRepo *database.UserRepo, jobRepo *database.JobRepo) *WebHandlers {
+func NewWebHandlers(tmpl *template.Template, userRepo *database.UserRepo, jobRepo *database.JobRepo, replicaRepo *database.ReplicaRepo, runRepo *database.RunRepo) *WebHandlers {
 	return &WebHandlers{
-		Templates: tmpl,
-		UserRepo:  userRepo,
-		JobRepo:   jobRepo,
+		Templates:   tmpl,
+		UserRepo:    userRepo,
+		JobRepo:     jobRepo,
+		ReplicaRepo: replicaRepo,
+		RunRepo:     runRepo,
 	}
 }
 
-func (wh *WebHandlers) SetSchedulerReloadFunc(f func()) {
+func (wh *WebHandlers) SetSchedulerReloadFunc(f func(jobID int)) {
 	wh.SchedulerReloadFunc = f
 }
 
+func (wh *WebHandlers) SetSchedulerRunFunc(f func(job *database.BackupJob, trigger string, admin bool) (bool, error)) {
+	wh.SchedulerRunFunc = f
+}
+
+func (wh *WebHandlers) SetSchedulerCancelFunc(f func(jobID int) error) {
+	wh.SchedulerCancelFunc = f
+}
+
+func (wh *WebHandlers) SetSchedulerPauseFunc(f func(until time.Time, reason string) (*database.SchedulerPause, error)) {
+	wh.SchedulerPauseFunc = f
+}
+
+func (wh *WebHandlers) SetSchedulerResumeFunc(f func() error) {
+	wh.SchedulerResumeFunc = f
+}
+
+func (wh *WebHandlers) SetPauseStateFunc(f func() *database.SchedulerPause) {
+	wh.PauseStateFunc = f
+}
+
+func (wh *WebHandlers) SetLeaderInfoFunc(f func() (string, bool)) {
+	wh.LeaderInfoFunc = f
+}
+
+func (wh *WebHandlers) SetValidateScheduleFunc(f func(job *database.BackupJob) error) {
+	wh.ValidateScheduleFunc = f
+}
+
+func (wh *WebHandlers) SetNextRunsFunc(f func(job *database.BackupJob, count int) ([]time.Time, error)) {
+	wh.NextRunsFunc = f
+}
+
+func (wh *WebHandlers) SetScheduleLoadFunc(f func(from, to time.Time) (*schedule.Load, error)) {
+	wh.ScheduleLoadFunc = f
+}
+
+// nextRun is shown in "next run" column, Time is zero for manual jobs
+type nextRun struct {
+	Manual bool
+	Time   time.Time
+	Error  string
+}
+
+func (wh *WebHandlers) nextRun(job *database.BackupJob) nextRun {
+	if wh.NextRunsFunc == nil {
+		return nextRun{}
+	}
+	runs, err := wh.NextRunsFunc(job, 1)
+	switch {
+	case err != nil:
+		return nextRun{Error: err.Error()}
+	case len(runs) == 0:
+		return nextRun{Manual: true}
+	default:
+		return nextRun{Time: runs[0]}
+	}
+}
+
 func (wh *WebHandlers) HomeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
@@ -57,10 +136,27 @@ func (wh *WebHandlers) HomeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	replicas := map[int][]database.BackupReplica{}
+	nextRuns := map[int]nextRun{}
+	for _, job := range jobs {
+		nextRuns[job.ID] = wh.nextRun(&job)
+
+		jobReplicas, err := wh.ReplicaRepo.GetLatestReplicas(job.ID)
+		if err != nil {
+			log.Printf("Error getting replicas for job ID %d: %v", job.ID, err)
+			continue
+		}
+		replicas[job.ID] = jobReplicas
+	}
+
 	data := struct {
-		Jobs []database.BackupJob
+		Jobs     []database.BackupJob
+		Replicas map[int][]database.BackupReplica
+		NextRuns map[int]nextRun
 	}{
-		Jobs: jobs,
+		Jobs:     jobs,
+		Replicas: replicas,
+		NextRuns: nextRuns,
 	}
 
 	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
@@ -88,7 +184,22 @@ func (wh *WebHandlers) CreateJobFormHandler(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "layout.html", nil); err != nil {
+	jobs, err := wh.JobRepo.GetAllJobs()
+	if err != nil {
+		log.Printf("Error getting backup tasks: %v", err)
+		http.Error(w, "Problem with getting backup tasks", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Jobs           []database.BackupJob
+		ScheduleConfig schedule.Schedule
+	}{
+		Jobs:           jobs,
+		ScheduleConfig: schedule.Schedule{Kind: schedule.KindManual},
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
 		log.Printf("Error rendering create_job.html: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
@@ -107,15 +218,26 @@ func (wh *WebHandlers) CreateJobHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	name := r.FormValue("name")
-	sourcePath := r.FormValue("source_path")
-	destinationPath := r.FormValue("destination_path")
-	schedule := r.FormValue("schedule")
-	isActiveStr := r.FormValue("is_active")
+	job := jobFromForm(r)
+	name := job.Name
 
-	isActive := (isActiveStr == "true")
+	if err := wh.prepareReplicationJob(job); err != nil {
+		log.Printf("CreateJobHandler: Wrong replication settings: %v", err)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+
+	if err := wh.validateJob(job); err != nil {
+		log.Printf("CreateJobHandler: Wrong job settings: %v", err)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
 
-	if name == "" || sourcePath == "" || destinationPath == "" || schedule == "" {
+	if job.Name == "" || job.SourcePath == "" && !job.IsReplication() || job.DestinationPath == "" || job.Schedule == "" {
 		log.Println("Not all fields filled with necessary info.")
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusBadRequest)
@@ -123,10 +245,15 @@ func (wh *WebHandlers) CreateJobHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	_, err := wh.JobRepo.CreateJob(name, sourcePath, destinationPath, schedule, isActive)
+	created, err := wh.JobRepo.CreateJob(job)
 	if err != nil {
 		log.Printf("Error creating backup task in DB: %v", err)
 		w.Header().Set("Content-Type", "text/html")
+		if errors.Is(err, database.ErrDependencyCycle) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		if database.IsUniqueConstraintError(err) {
 			fmt.Fprintf(w, `<div class="message error">Error: Task with this name or paths is exists.</div>`)
@@ -139,7 +266,7 @@ func (wh *WebHandlers) CreateJobHandler(w http.ResponseWriter, r *http.Request)
 	log.Printf("Successfully created new backup task: %s", name)
 
 	if wh.SchedulerReloadFunc != nil {
-		wh.SchedulerReloadFunc()
+		wh.SchedulerReloadFunc(created.ID)
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -149,6 +276,297 @@ func (wh *WebHandlers) CreateJobHandler(w http.ResponseWriter, r *http.Request)
 		`, name)
 }
 
+// jobFromForm reads job fields from create/edit form.
+// Additional destinations are entered one per line.
+func jobFromForm(r *http.Request) *database.BackupJob {
+	job := &database.BackupJob{
+		Name:              strings.TrimSpace(r.FormValue("name")),
+		SourcePath:        strings.TrimSpace(r.FormValue("source_path")),
+		DestinationPath:   strings.TrimSpace(r.FormValue("destination_path")),
+		ScheduleConfig:    scheduleFromForm(r),
+		TimeZone:          strings.TrimSpace(r.FormValue("time_zone")),
+		IsActive:          r.FormValue("is_active") == "true",
+		DestinationPolicy: r.FormValue("destination_policy"),
+	}
+
+	if job.DestinationPolicy != database.DestinationPolicyAny {
+		job.DestinationPolicy = database.DestinationPolicyAll
+	}
+
+	job.Schedule, _ = job.ScheduleConfig.Spec()
+	job.ScheduleAction = r.FormValue("schedule_action")
+	if job.ScheduleAction == "" {
+		job.ScheduleAction = database.ActionFull
+	}
+	job.Schedules = schedulesFromForm(r.FormValue("extra_schedules"))
+	job.Preconditions = preconditionsFromForm(r.FormValue("preconditions"))
+
+	job.SpacePolicy = r.FormValue("space_policy")
+	if job.SpacePolicy != database.SpacePolicyPrune {
+		job.SpacePolicy = database.SpacePolicyAbort
+	}
+	if minFree, err := strconv.ParseInt(r.FormValue("min_free_mb"), 10, 64); err == nil && minFree > 0 {
+		job.MinFreeMB = minFree
+	}
+	job.RetentionRuns, _ = strconv.Atoi(r.FormValue("retention_runs"))
+	job.RetentionDays, _ = strconv.Atoi(r.FormValue("retention_days"))
+	if r.FormValue("retention_runs") == "" && r.FormValue("retention_days") == "" {
+		job.RetentionRuns = database.DefaultRetentionRuns
+	}
+
+	if priority, err := strconv.Atoi(r.FormValue("priority")); err == nil {
+		job.Priority = priority
+	}
+
+	job.RetryMaxAttempts, _ = strconv.Atoi(r.FormValue("retry_max_attempts"))
+	if job.RetryMaxAttempts < 0 {
+		job.RetryMaxAttempts = 0
+	}
+	job.RetryDelaySeconds, _ = strconv.Atoi(r.FormValue("retry_delay_seconds"))
+	job.RetryBackoff, _ = strconv.ParseFloat(r.FormValue("retry_backoff"), 64)
+	var retryOn []string
+	for _, class := range r.Form["retry_on"] {
+		if slices.Contains(backup.ErrorClasses, class) {
+			retryOn = append(retryOn, class)
+		}
+	}
+	job.RetryOn = strings.Join(retryOn, ",")
+
+	job.MissedRunPolicy = r.FormValue("missed_run_policy")
+	switch job.MissedRunPolicy {
+	case database.MissedRunRunOnce, database.MissedRunGrace:
+	default:
+		job.MissedRunPolicy = database.MissedRunSkip
+	}
+	job.MissedGraceMinutes, _ = strconv.Atoi(r.FormValue("missed_grace_minutes"))
+
+	for _, idStr := range r.Form["depends_on"] {
+		upstreamID, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		job.Dependencies = append(job.Dependencies, database.JobDependency{
+			DependsOnJobID: upstreamID,
+			Condition:      r.FormValue("depends_condition_" + idStr),
+		})
+	}
+
+	job.WindowStart = strings.TrimSpace(r.FormValue("window_start"))
+	job.WindowEnd = strings.TrimSpace(r.FormValue("window_end"))
+	job.BlackoutDates = strings.TrimSpace(r.FormValue("blackout_dates"))
+	job.WindowEndAction = r.FormValue("window_end_action")
+	if job.WindowEndAction != database.WindowEndPause {
+		job.WindowEndAction = database.WindowEndCancel
+	}
+	job.MaxRuntimeMinutes, _ = strconv.Atoi(r.FormValue("max_runtime_minutes"))
+
+	job.WatchChanges = r.FormValue("watch_changes") == "true"
+	job.WatchDebounceSeconds, _ = strconv.Atoi(r.FormValue("watch_debounce_seconds"))
+	job.WatchMinIntervalSeconds, _ = strconv.Atoi(r.FormValue("watch_min_interval_seconds"))
+
+	job.OverlapPolicy = r.FormValue("overlap_policy")
+	switch job.OverlapPolicy {
+	case database.OverlapPolicyQueue, database.OverlapPolicyCancel:
+	default:
+		job.OverlapPolicy = database.OverlapPolicySkip
+	}
+
+	job.SourceType = r.FormValue("source_type")
+	switch job.SourceType {
+	case database.SourceTypeSQLite, database.SourceTypeCommand:
+	default:
+		job.SourceType = database.SourceTypeFiles
+	}
+
+	job.Kind = r.FormValue("kind")
+	if job.Kind != database.JobKindReplication {
+		job.Kind = database.JobKindBackup
+	}
+	if sourceJobID, err := strconv.Atoi(r.FormValue("replica_of_job_id")); err == nil && job.Kind == database.JobKindReplication {
+		job.ReplicaOfJobID = sql.NullInt64{Int64: int64(sourceJobID), Valid: true}
+		job.ReplicaRunID, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("replica_run_id")))
+	}
+
+	for _, line := range strings.Split(r.FormValue("extra_destinations"), "\n") {
+		if path := strings.TrimSpace(line); path != "" {
+			job.Destinations = append(job.Destinations, database.JobDestination{DestinationPath: path})
+		}
+	}
+
+	return job
+}
+
+// scheduleFromForm reads schedule settings, forms without schedule_kind send raw cron spec in schedule field.
+// Times and month days are separated by commas or spaces.
+func scheduleFromForm(r *http.Request) schedule.Schedule {
+	kind := r.FormValue("schedule_kind")
+	if kind == "" {
+		return schedule.FromSpec(r.FormValue("schedule"))
+	}
+
+	s := schedule.Schedule{Kind: kind}
+	switch kind {
+	case schedule.KindInterval:
+		s.Interval = strings.TrimSpace(r.FormValue("schedule_interval"))
+	case schedule.KindCron:
+		s.Cron = strings.TrimSpace(r.FormValue("schedule_cron"))
+	case schedule.KindDaily, schedule.KindWeekly, schedule.KindMonthly:
+		s.Times = splitList(r.FormValue("schedule_times"))
+		if kind == schedule.KindWeekly {
+			for _, d := range r.Form["schedule_weekdays"] {
+				if day, err := strconv.Atoi(d); err == nil {
+					s.Weekdays = append(s.Weekdays, day)
+				}
+			}
+		}
+		if kind == schedule.KindMonthly {
+			for _, d := range splitList(r.FormValue("schedule_month_days")) {
+				day, err := strconv.Atoi(d)
+				if err != nil {
+					day = -1 // reported by validation
+				}
+				s.MonthDays = append(s.MonthDays, day)
+			}
+		}
+	}
+	return s
+}
+
+// schedulesFromForm reads additional schedules entered one per line as action and cron spec, e.g. "verify 0 3 1 * *"
+func schedulesFromForm(value string) []database.JobSchedule {
+	var schedules []database.JobSchedule
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		spec := strings.Join(fields[1:], " ")
+		schedules = append(schedules, database.JobSchedule{
+			Action:         strings.ToLower(fields[0]),
+			Schedule:       spec,
+			ScheduleConfig: schedule.FromSpec(spec),
+		})
+	}
+	return schedules
+}
+
+// preconditionsFromForm reads preconditions entered one per line as type and value, e.g. "mount /mnt/usb"
+func preconditionsFromForm(value string) []database.JobPrecondition {
+	var preconditions []database.JobPrecondition
+	for _, line := range strings.Split(value, "\n") {
+		kind, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if kind == "" {
+			continue
+		}
+		preconditions = append(preconditions, database.JobPrecondition{
+			Type:  strings.ToLower(kind),
+			Value: strings.TrimSpace(value),
+		})
+	}
+	return preconditions
+}
+
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+}
+
+// validateJob checks job settings which can't be fixed silently when the form is read
+func (wh *WebHandlers) validateJob(job *database.BackupJob) error {
+	if err := job.ScheduleConfig.Validate(); err != nil {
+		return err
+	}
+	if wh.ValidateScheduleFunc != nil {
+		if err := wh.ValidateScheduleFunc(job); err != nil {
+			return err
+		}
+	}
+	if _, err := window.Parse(job.WindowStart, job.WindowEnd, job.BlackoutDates); err != nil {
+		return err
+	}
+	for _, s := range job.AllSchedules() {
+		if s.Action == database.ActionFull || job.SourceType == database.SourceTypeFiles && !job.IsReplication() {
+			continue
+		}
+		// Old dumps of command jobs are removed by retention
+		if s.Action == database.ActionPrune && job.SourceType == database.SourceTypeCommand && !job.IsReplication() {
+			continue
+		}
+		return fmt.Errorf("%s runs are possible only for files backup job", s.Action)
+	}
+	for _, p := range job.Preconditions {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if p.Type == database.PreconditionCommand {
+			if err := backup.CommandAllowed(p.Value); err != nil {
+				return fmt.Errorf("precondition command %w, add it to allowed_commands in config.yaml", err)
+			}
+		}
+	}
+	if job.SourceType == database.SourceTypeCommand && !job.IsReplication() {
+		if err := backup.CommandAllowed(job.SourcePath); err != nil {
+			return fmt.Errorf("dump command %w, add it to allowed_commands in config.yaml", err)
+		}
+	}
+	if job.RetentionRuns <= 0 && job.RetentionDays <= 0 {
+		return fmt.Errorf("set number of runs or days to keep files which are gone from the source")
+	}
+	for _, path := range job.DestinationPaths() {
+		if _, err := backup.NewDestination(path); err != nil {
+			return err
+		}
+	}
+	if job.WatchChanges {
+		if job.IsReplication() || job.SourceType != database.SourceTypeFiles {
+			return fmt.Errorf("only files backup job can be started by changes in the source")
+		}
+		if info, err := os.Stat(job.SourcePath); err != nil || !info.IsDir() {
+			return fmt.Errorf("source '%s' must be existing directory to watch its changes", job.SourcePath)
+		}
+	}
+	return nil
+}
+
+// prepareReplicationJob checks source job of replication job and the run it copies.
+// Source path must be one of the source job destinations, when it is empty any destination holding the run is replicated.
+func (wh *WebHandlers) prepareReplicationJob(job *database.BackupJob) error {
+	if !job.IsReplication() {
+		return nil
+	}
+	if !job.ReplicaOfJobID.Valid {
+		return fmt.Errorf("choose job which backups should be replicated")
+	}
+	if int(job.ReplicaOfJobID.Int64) == job.ID {
+		return fmt.Errorf("job can't replicate itself")
+	}
+
+	sourceJob, err := wh.JobRepo.GetJobByID(int(job.ReplicaOfJobID.Int64))
+	if err != nil {
+		return err
+	}
+	if sourceJob.IsReplication() {
+		return fmt.Errorf("job '%s' is replication job itself", sourceJob.Name)
+	}
+	if job.SourcePath != "" && !slices.Contains(sourceJob.DestinationPaths(), job.SourcePath) {
+		return fmt.Errorf("'%s' is not destination of job '%s'", job.SourcePath, sourceJob.Name)
+	}
+	if job.ReplicaRunID < 0 {
+		job.ReplicaRunID = 0
+	}
+	if job.ReplicaRunID > 0 {
+		run, err := wh.RunRepo.GetRunByID(job.ReplicaRunID)
+		if err != nil {
+			return err
+		}
+		if run.JobID != sourceJob.ID || !run.IsCompletedBackup() {
+			return fmt.Errorf("run ID %d is not completed backup run of job '%s'", run.ID, sourceJob.Name)
+		}
+	}
+	return nil
+}
+
 func (wh *WebHandlers) JobsHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "<h1>Backup Tasks (under construction)</h1><p> Here will be list of your backup tasks.</p>")
@@ -209,10 +627,19 @@ func (wh *WebHandlers) EditJobFormHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	jobs, err := wh.JobRepo.GetAllJobs()
+	if err != nil {
+		log.Printf("EditJobFormHandler: Error getting backup tasks: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	data := struct {
-		Job *database.BackupJob
+		Job  *database.BackupJob
+		Jobs []database.BackupJob
 	}{
-		Job: job,
+		Job:  job,
+		Jobs: jobs,
 	}
 
 	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
@@ -248,18 +675,30 @@ func (wh *WebHandlers) UpdateJobHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	name := r.FormValue("name")
-	sourcePath := r.FormValue("source_path")
-	destinationPath := r.FormValue("destination_path")
-	schedule := r.FormValue("schedule")
-	isActiveStr := r.FormValue("is_active")
+	job := jobFromForm(r)
+	job.ID = jobID
+	name := job.Name
 
-	isActive := (isActiveStr == "true")
+	if err := wh.prepareReplicationJob(job); err != nil {
+		log.Printf("UpdateJobHandler: Wrong replication settings for job ID %d: %v", jobID, err)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
 
-	log.Printf("UpdateJobHandler: Job ID %d, Form values - Name: %s, Source: %s, Dest: %s, Schedule: %s, Active: %t",
-		jobID, name, sourcePath, destinationPath, schedule, isActive)
+	if err := wh.validateJob(job); err != nil {
+		log.Printf("UpdateJobHandler: Wrong settings for job ID %d: %v", jobID, err)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+
+	log.Printf("UpdateJobHandler: Job ID %d, Form values - Name: %s, Source: %s, Dest: %v, Schedule: %s, Active: %t",
+		jobID, job.Name, job.SourcePath, job.DisplayDestinationPaths(), job.Schedule, job.IsActive)
 
-	if name == "" || sourcePath == "" || destinationPath == "" || schedule == "" {
+	if job.Name == "" || job.SourcePath == "" && !job.IsReplication() || job.DestinationPath == "" || job.Schedule == "" {
 		log.Println("UpdateJobHandler: Not all fields filled with necessary info. Sending Bad Request.")
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusBadRequest)
@@ -267,10 +706,15 @@ func (wh *WebHandlers) UpdateJobHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	_, err = wh.JobRepo.UpdateJob(jobID, name, sourcePath, destinationPath, schedule, isActive)
+	_, err = wh.JobRepo.UpdateJob(job)
 	if err != nil {
 		log.Printf("UpdateJobHandler: Error updating backup task in DB (ID %d): %v", jobID, err)
 		w.Header().Set("Content-Type", "text/html")
+		if errors.Is(err, database.ErrDependencyCycle) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 
 		if database.IsUniqueConstraintError(err) {
@@ -284,7 +728,7 @@ func (wh *WebHandlers) UpdateJobHandler(w http.ResponseWriter, r *http.Request)
 	log.Printf("UpdateJobHandler: Successfully updated backup task: %s (ID: %d)", name, jobID)
 
 	if wh.SchedulerReloadFunc != nil {
-		wh.SchedulerReloadFunc()
+		wh.SchedulerReloadFunc(jobID)
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -321,7 +765,7 @@ func (wh *WebHandlers) DeleteJobHandler(w http.ResponseWriter, r *http.Request)
 	log.Printf("DeleteJobHandler: Successfully deleted backup task with ID: %d", jobID)
 
 	if wh.SchedulerReloadFunc != nil {
-		wh.SchedulerReloadFunc()
+		wh.SchedulerReloadFunc(jobID)
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -353,23 +797,70 @@ func (wh *WebHandlers) RunBackupHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	go func() {
-		log.Printf("Starting asynchronous backup for job ID %d: %s", job.ID, job.Name)
-		result := backup.PerformLocalBackup(job.ID, job.SourcePath, job.DestinationPath)
+	if wh.SchedulerRunFunc == nil {
+		log.Printf("RunBackupHandler: Scheduler run function is not set")
+		http.Error(w, "Backup runner is not available", http.StatusInternalServerError)
+		return
+	}
 
-		err := wh.JobRepo.UpdateJobStatusAndLastRun(result.JobID, result.Status, result.Time)
-		if err != nil {
-			log.Printf("Failed to update job status for ID %d: %v", result.JobID, err)
-		} else {
-			log.Printf("Job ID %d status updated to '%s' (Duration: %s)", result.JobID, result.Status, result.Duration.String())
+	// During maintenance only admin starts jobs
+	admin := wh.adminUser(r)
+	if admin == nil && wh.pauseState() != nil {
+		if admin = wh.requireAdmin(w, r); admin == nil {
+			return
 		}
-	}()
+	}
 
-	log.Printf("RunBackupHandler: Backup initiated for job ID %d. Sending success response.", jobID)
+	log.Printf("Starting asynchronous backup for job ID %d: %s", job.ID, job.Name)
+	queued, err := wh.SchedulerRunFunc(job, database.RunTriggerManual, admin != nil)
 
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `<div class="status-indicator" id="job-status-%d">
+	switch {
+	case err != nil:
+		log.Printf("RunBackupHandler: Backup for job ID %d not started: %v", jobID, err)
+		fmt.Fprintf(w, `<div class="status-indicator" id="job-status-%d">
+                       <span class="status-error">Skipped: %s</span>
+                     </div>`, jobID, template.HTMLEscapeString(err.Error()))
+	case queued:
+		log.Printf("RunBackupHandler: Backup for job ID %d queued.", jobID)
+		fmt.Fprintf(w, `<div class="status-indicator" id="job-status-%d">
+                       <span class="status-pending">Backup queued, waiting for previous run...</span>
+                     </div>`, jobID)
+	default:
+		log.Printf("RunBackupHandler: Backup initiated for job ID %d. Sending success response.", jobID)
+		fmt.Fprintf(w, `<div class="status-indicator" id="job-status-%d">
                        <span class="status-pending">Backup started...</span>
                      </div>`, jobID)
+	}
+}
+
+// CancelJobHandler stops the working run of the job, the run gets Cancelled status when the copy stops
+func (wh *WebHandlers) CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("CancelJobHandler: Invalid job ID in URL: %v", err)
+		http.Error(w, "Incorrect ID task", http.StatusBadRequest)
+		return
+	}
+
+	if wh.SchedulerCancelFunc == nil {
+		log.Printf("CancelJobHandler: Scheduler cancel function is not set")
+		http.Error(w, "Backup runner is not available", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	if err := wh.SchedulerCancelFunc(jobID); err != nil {
+		log.Printf("CancelJobHandler: Run of job ID %d not cancelled: %v", jobID, err)
+		fmt.Fprintf(w, `<div class="status-indicator" id="job-status-%d">
+                       <span class="status-error">Not cancelled: %s</span>
+                     </div>`, jobID, template.HTMLEscapeString(err.Error()))
+		return
+	}
+	log.Printf("CancelJobHandler: Cancellation requested for job ID %d", jobID)
+	fmt.Fprintf(w, `<div class="status-indicator" id="job-status-%d">
+                       <span class="status-pending">Cancelling...</span>
+                     </div>`, jobID)
 }
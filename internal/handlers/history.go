@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"backup-app/internal/database"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const historyPageSize = 20
+
+// runFilterFromQuery reads filter of the run history from URL query.
+// Dates are accepted as 2006-01-02, "to" date is included completely.
+func runFilterFromQuery(q url.Values) (database.RunFilter, error) {
+	filter := database.RunFilter{
+		Status:  q.Get("status"),
+		Trigger: q.Get("trigger"),
+	}
+
+	if from := q.Get("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("wrong 'from' date '%s', expected YYYY-MM-DD", from)
+		}
+		filter.From = t
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("wrong 'to' date '%s', expected YYYY-MM-DD", to)
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+func (wh *WebHandlers) JobHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("JobHistoryHandler: Invalid job ID in URL: %v", err)
+		http.Error(w, "Incorrect ID request", http.StatusBadRequest)
+		return
+	}
+
+	job, err := wh.JobRepo.GetJobByID(jobID)
+	if err != nil {
+		log.Printf("JobHistoryHandler: Error getting job by ID %d: %v", jobID, err)
+		http.NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	filter, err := runFilterFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	filter.Limit = historyPageSize
+	filter.Offset = (page - 1) * historyPageSize
+
+	runs, total, err := wh.RunRepo.GetJobRuns(jobID, filter)
+	if err != nil {
+		log.Printf("JobHistoryHandler: Error getting runs of job ID %d: %v", jobID, err)
+		http.Error(w, "Problem with getting run history", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := wh.Templates.Clone()
+	if err != nil {
+		log.Printf("JobHistoryHandler: Error template cloning: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err = tmpl.ParseFiles(filepath.Join("web", "templates", "job_history.html"))
+	if err != nil {
+		log.Printf("JobHistoryHandler: Error parsing job_history.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Query without page is used to build links of pagination
+	q.Del("page")
+
+	data := struct {
+		Job      *database.BackupJob
+		Runs     []database.BackupRun
+		Total    int
+		Page     int
+		PrevPage int
+		NextPage int
+		Query    template.URL
+		Filter   url.Values
+	}{
+		Job:    job,
+		Runs:   runs,
+		Total:  total,
+		Page:   page,
+		Query:  template.URL(q.Encode()),
+		Filter: r.URL.Query(),
+	}
+	if page > 1 {
+		data.PrevPage = page - 1
+	}
+	if page*historyPageSize < total {
+		data.NextPage = page + 1
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("JobHistoryHandler: Error rendering job_history.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
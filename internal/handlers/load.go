@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"backup-app/internal/schedule"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLoadHours = 24
+	maxLoadHours     = 7 * 24
+)
+
+// loadStorage is one storage on the timeline page, every job writing to it has its own row
+type loadStorage struct {
+	Name      string
+	Rows      []loadRow
+	Conflicts int
+}
+
+type loadRow struct {
+	JobID   int
+	JobName string
+	Bars    []loadBar
+}
+
+// loadBar is a run on the timeline, Left and Width are percents of the period
+type loadBar struct {
+	Run   schedule.PlannedRun
+	Left  float64
+	Width float64
+}
+
+// loadTick is a time label above the timeline
+type loadTick struct {
+	Label string
+	Left  float64
+}
+
+// loadPeriod reads period of the timeline from query parameter hours, it starts now
+func loadPeriod(r *http.Request) (from, to time.Time, err error) {
+	hours := defaultLoadHours
+	if value := r.URL.Query().Get("hours"); value != "" {
+		hours, err = strconv.Atoi(value)
+		if err != nil || hours <= 0 || hours > maxLoadHours {
+			return time.Time{}, time.Time{}, fmt.Errorf("hours must be a number from 1 to %d", maxLoadHours)
+		}
+	}
+	from = time.Now().Truncate(time.Minute)
+	return from, from.Add(time.Duration(hours) * time.Hour), nil
+}
+
+// percentOf returns position of t in the load period in percents, limited to the period
+func percentOf(load *schedule.Load, t time.Time) float64 {
+	p := float64(t.Sub(load.From)) / float64(load.To.Sub(load.From)) * 100
+	return min(max(p, 0), 100)
+}
+
+func loadStorages(load *schedule.Load) []loadStorage {
+	var storages []loadStorage
+	for _, name := range load.Storages() {
+		storage := loadStorage{Name: name}
+		rowIndex := map[int]int{}
+		for _, run := range load.StorageRuns(name) {
+			i, ok := rowIndex[run.JobID]
+			if !ok {
+				i = len(storage.Rows)
+				rowIndex[run.JobID] = i
+				storage.Rows = append(storage.Rows, loadRow{JobID: run.JobID, JobName: run.JobName})
+			}
+			left := percentOf(load, run.Start)
+			storage.Rows[i].Bars = append(storage.Rows[i].Bars, loadBar{
+				Run:   run,
+				Left:  left,
+				Width: max(percentOf(load, run.End)-left, 0.3),
+			})
+		}
+		for _, c := range load.Conflicts {
+			if c.Storage == name {
+				storage.Conflicts++
+			}
+		}
+		storages = append(storages, storage)
+	}
+	return storages
+}
+
+// loadTicks returns labels of hours, for long periods the labels are placed every few hours.
+// Hours are counted by the clock of the period location, so labels stay on full hours in zones with half-hour offsets and on DST change.
+func loadTicks(load *schedule.Load) []loadTick {
+	hours := int(load.To.Sub(load.From) / time.Hour)
+	step := max(hours/12, 1)
+	first := schedule.RoundUp(load.From.Add(time.Minute), time.Hour)
+	var ticks []loadTick
+	var last time.Time
+	for i := 0; ; i += step {
+		t := time.Date(first.Year(), first.Month(), first.Day(), first.Hour()+i, 0, 0, 0, first.Location())
+		if !t.Before(load.To) {
+			break
+		}
+		// Hour which is skipped when DST starts is the same time as the next one
+		if len(ticks) > 0 && !t.After(last) {
+			continue
+		}
+		last = t
+		label := t.Format("15:04")
+		if t.Hour() == 0 || hours > 24 {
+			label = t.Format("02.01 15:04")
+		}
+		ticks = append(ticks, loadTick{Label: label, Left: percentOf(load, t)})
+	}
+	return ticks
+}
+
+// APIScheduleLoadHandler returns timeline of scheduled runs with conflicts and suggested start times.
+// Query parameter hours sets length of the period from now (default 24, up to one week).
+func (wh *WebHandlers) APIScheduleLoadHandler(w http.ResponseWriter, r *http.Request) {
+	if wh.ScheduleLoadFunc == nil {
+		writeJSONError(w, http.StatusInternalServerError, "scheduler is not available")
+		return
+	}
+	from, to, err := loadPeriod(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	load, err := wh.ScheduleLoadFunc(from, to)
+	if err != nil {
+		log.Printf("APIScheduleLoadHandler: Error calculating schedule load: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "can't calculate schedule load")
+		return
+	}
+	writeJSON(w, http.StatusOK, load)
+}
+
+func (wh *WebHandlers) ScheduleLoadHandler(w http.ResponseWriter, r *http.Request) {
+	if wh.ScheduleLoadFunc == nil {
+		http.Error(w, "Scheduler is not available", http.StatusInternalServerError)
+		return
+	}
+	from, to, err := loadPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	load, err := wh.ScheduleLoadFunc(from, to)
+	if err != nil {
+		log.Printf("ScheduleLoadHandler: Error calculating schedule load: %v", err)
+		http.Error(w, "Can't calculate schedule load", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := wh.Templates.Clone()
+	if err != nil {
+		log.Printf("ScheduleLoadHandler: Error template cloning: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err = tmpl.ParseFiles(filepath.Join("web", "templates", "schedule_load.html"))
+	if err != nil {
+		log.Printf("ScheduleLoadHandler: Error parsing schedule_load.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Load     *schedule.Load
+		Hours    int
+		Storages []loadStorage
+		Ticks    []loadTick
+	}{
+		Load:     load,
+		Hours:    int(to.Sub(from) / time.Hour),
+		Storages: loadStorages(load),
+		Ticks:    loadTicks(load),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("ScheduleLoadHandler: Error rendering schedule_load.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
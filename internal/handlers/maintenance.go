@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"backup-app/internal/database"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formats of pause end, form input datetime-local sends the first one
+var pauseUntilLayouts = []string{"2006-01-02T15:04", "2006-01-02 15:04", time.RFC3339}
+
+// pauseUntil reads end of the pause from "until" (time in server zone or RFC3339) or "for" (duration) parameter.
+// Zero time means pause without end.
+func pauseUntil(r *http.Request) (time.Time, error) {
+	if d := strings.TrimSpace(r.FormValue("for")); d != "" {
+		duration, err := time.ParseDuration(d)
+		if err != nil || duration <= 0 {
+			return time.Time{}, fmt.Errorf("wrong pause duration '%s'", d)
+		}
+		return time.Now().Add(duration), nil
+	}
+	until := strings.TrimSpace(r.FormValue("until"))
+	if until == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range pauseUntilLayouts {
+		if t, err := time.ParseInLocation(layout, until, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("wrong pause end '%s', expected YYYY-MM-DD HH:MM", until)
+}
+
+func pauseText(pause *database.SchedulerPause) string {
+	text := "Scheduler is paused since " + pause.Since.Format("2006-01-02 15:04")
+	if !pause.Until.IsZero() {
+		text += " until " + pause.Until.Format("2006-01-02 15:04")
+	}
+	if pause.Reason != "" {
+		text += ": " + pause.Reason
+	}
+	return text
+}
+
+func (wh *WebHandlers) pauseState() *database.SchedulerPause {
+	if wh.PauseStateFunc == nil {
+		return nil
+	}
+	return wh.PauseStateFunc()
+}
+
+// MaintenancePageHandler shows maintenance mode of the scheduler with pause and resume controls
+func (wh *WebHandlers) MaintenancePageHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := wh.Templates.Clone()
+	if err != nil {
+		log.Printf("MaintenancePageHandler: Error template cloning: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err = tmpl.ParseFiles(filepath.Join("web", "templates", "maintenance.html"))
+	if err != nil {
+		log.Printf("MaintenancePageHandler: Error parsing maintenance.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Pause     *database.SchedulerPause
+		PauseText string
+		Instance  string
+		Leader    bool
+	}{
+		Pause:  wh.pauseState(),
+		Leader: true,
+	}
+	if wh.LeaderInfoFunc != nil {
+		data.Instance, data.Leader = wh.LeaderInfoFunc()
+	}
+	if data.Pause != nil {
+		data.PauseText = pauseText(data.Pause)
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout.html", data); err != nil {
+		log.Printf("MaintenancePageHandler: Error rendering maintenance.html: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// MaintenanceBannerHandler returns banner shown on every page while the scheduler is paused, empty otherwise
+func (wh *WebHandlers) MaintenanceBannerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	pause := wh.pauseState()
+	if pause == nil {
+		return
+	}
+	fmt.Fprintf(w, `<div class="maintenance-banner">
+                       %s. Scheduled runs are skipped, admin can still start jobs manually.
+                       <button hx-post="/maintenance/resume" hx-target="closest .maintenance-banner" hx-swap="outerHTML" class="button">Resume</button>
+                     </div>`, template.HTMLEscapeString(pauseText(pause)))
+}
+
+// PauseSchedulerHandler turns maintenance mode on, only admin can do it
+func (wh *WebHandlers) PauseSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	admin := wh.requireAdmin(w, r)
+	if admin == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if wh.SchedulerPauseFunc == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `<div class="message error">Error: scheduler is not available</div>`)
+		return
+	}
+
+	until, err := pauseUntil(r)
+	if err == nil {
+		var pause *database.SchedulerPause
+		pause, err = wh.SchedulerPauseFunc(until, strings.TrimSpace(r.FormValue("reason")))
+		if err == nil {
+			log.Printf("PauseSchedulerHandler: Scheduler paused from web UI by '%s'", admin.Username)
+			fmt.Fprintf(w, `<div class="message success">%s.</div>`, template.HTMLEscapeString(pauseText(pause)))
+			return
+		}
+	}
+	log.Printf("PauseSchedulerHandler: Scheduler not paused: %v", err)
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+}
+
+// ResumeSchedulerHandler turns maintenance mode off, only admin can do it
+func (wh *WebHandlers) ResumeSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	admin := wh.requireAdmin(w, r)
+	if admin == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if wh.SchedulerResumeFunc == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `<div class="message error">Error: scheduler is not available</div>`)
+		return
+	}
+	if err := wh.SchedulerResumeFunc(); err != nil {
+		log.Printf("ResumeSchedulerHandler: Scheduler not resumed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `<div class="message error">Error: %s</div>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+	log.Printf("ResumeSchedulerHandler: Scheduler resumed from web UI by '%s'", admin.Username)
+	fmt.Fprintf(w, `<div class="message success">Scheduler resumed, scheduled runs are started again.</div>`)
+}
+
+// apiSchedulerState is maintenance mode and leadership of the instance as it is returned by JSON API
+type apiSchedulerState struct {
+	Paused   bool                     `json:"paused"`
+	Pause    *database.SchedulerPause `json:"pause,omitempty"`
+	Instance string                   `json:"instance,omitempty"`
+	Leader   bool                     `json:"leader"`
+}
+
+// APISchedulerHandler returns maintenance mode of the scheduler and if this instance is the leader
+func (wh *WebHandlers) APISchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	pause := wh.pauseState()
+	state := apiSchedulerState{Paused: pause != nil, Pause: pause, Leader: true}
+	if wh.LeaderInfoFunc != nil {
+		state.Instance, state.Leader = wh.LeaderInfoFunc()
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// APIPauseSchedulerHandler pauses scheduled runs, admin credentials are required. Parameters: until (YYYY-MM-DD HH:MM or RFC3339) or for (duration), reason.
+func (wh *WebHandlers) APIPauseSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	admin := wh.requireAPIAdmin(w, r)
+	if admin == nil {
+		return
+	}
+	if wh.SchedulerPauseFunc == nil {
+		writeJSONError(w, http.StatusInternalServerError, "scheduler is not available")
+		return
+	}
+	until, err := pauseUntil(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pause, err := wh.SchedulerPauseFunc(until, strings.TrimSpace(r.FormValue("reason")))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("Scheduler paused from API by '%s'", admin.Username)
+	writeJSON(w, http.StatusOK, apiSchedulerState{Paused: true, Pause: pause})
+}
+
+// APIResumeSchedulerHandler resumes scheduled runs, admin credentials are required
+func (wh *WebHandlers) APIResumeSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	admin := wh.requireAPIAdmin(w, r)
+	if admin == nil {
+		return
+	}
+	if wh.SchedulerResumeFunc == nil {
+		writeJSONError(w, http.StatusInternalServerError, "scheduler is not available")
+		return
+	}
+	if err := wh.SchedulerResumeFunc(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("Scheduler resumed from API by '%s'", admin.Username)
+	writeJSON(w, http.StatusOK, apiSchedulerState{Paused: false})
+}
@@ -0,0 +1,227 @@
+package schedule
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// PlannedRun is an upcoming scheduled run with the time it is expected to take
+type PlannedRun struct {
+	JobID    int       `json:"job_id"`
+	JobName  string    `json:"job_name"`
+	Priority int       `json:"priority"`
+	Action   string    `json:"action"`
+	Schedule string    `json:"schedule"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	// Estimated is true when the duration comes from the run history, false for the default duration
+	Estimated bool `json:"estimated"`
+	// Storages which the run writes to, runs on the same storage compete for its disk and network
+	Storages []string `json:"storages"`
+	Conflict bool     `json:"conflict"`
+	// Location of the job schedule, suggested times are rounded in it. Location of Start is used when it is nil.
+	Location *time.Location `json:"-"`
+}
+
+func (r PlannedRun) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Conflict is a group of runs of different jobs which write to the same storage at the same time
+type Conflict struct {
+	Storage string       `json:"storage"`
+	Start   time.Time    `json:"start"`
+	End     time.Time    `json:"end"`
+	Runs    []PlannedRun `json:"runs"`
+}
+
+// Suggestion moves schedule of the job, so its run starts after the runs it overlaps with.
+// NewSchedule and NewSpec are the moved schedule, they are empty when the schedule can't be moved as a whole.
+type Suggestion struct {
+	JobID       int       `json:"job_id"`
+	JobName     string    `json:"job_name"`
+	Action      string    `json:"action"`
+	Schedule    string    `json:"schedule"`
+	Current     time.Time `json:"current"`
+	Suggested   time.Time `json:"suggested"`
+	NewSchedule *Schedule `json:"new_schedule,omitempty"`
+	NewSpec     string    `json:"new_spec,omitempty"`
+	// Reason why the schedule can't be moved
+	Note string `json:"note,omitempty"`
+}
+
+func (s Suggestion) Shift() time.Duration {
+	return s.Suggested.Sub(s.Current)
+}
+
+// Load is the timeline of upcoming runs between From and To with conflicts found in it
+type Load struct {
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	Runs        []PlannedRun `json:"runs"`
+	Conflicts   []Conflict   `json:"conflicts"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// Storages returns storages used by the runs in order of their first run
+func (l *Load) Storages() []string {
+	var storages []string
+	seen := map[string]bool{}
+	for _, r := range l.Runs {
+		for _, s := range r.Storages {
+			if !seen[s] {
+				seen[s] = true
+				storages = append(storages, s)
+			}
+		}
+	}
+	return storages
+}
+
+// StorageRuns returns runs which write to the storage
+func (l *Load) StorageRuns(storage string) []PlannedRun {
+	var runs []PlannedRun
+	for _, r := range l.Runs {
+		if slices.Contains(r.Storages, storage) {
+			runs = append(runs, r)
+		}
+	}
+	return runs
+}
+
+// AnalyzeLoad sorts runs, finds conflicts on each storage and suggests staggered start times.
+// Suggested times are rounded up to step in location of the job schedule, runs of jobs with higher priority keep their times.
+func AnalyzeLoad(from, to time.Time, runs []PlannedRun, step time.Duration) *Load {
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].Start.Equal(runs[j].Start) {
+			return runs[i].Start.Before(runs[j].Start)
+		}
+		return runs[i].Priority > runs[j].Priority
+	})
+	load := &Load{From: from, To: to, Runs: runs}
+
+	for _, storage := range load.Storages() {
+		var group []int
+		var groupEnd time.Time
+		closeGroup := func() {
+			jobs := map[int]bool{}
+			for _, i := range group {
+				jobs[runs[i].JobID] = true
+			}
+			if len(jobs) < 2 {
+				return
+			}
+			conflict := Conflict{Storage: storage, Start: runs[group[0]].Start, End: groupEnd}
+			for _, i := range group {
+				runs[i].Conflict = true
+				conflict.Runs = append(conflict.Runs, runs[i])
+			}
+			load.Conflicts = append(load.Conflicts, conflict)
+		}
+		for i, r := range runs {
+			if !slices.Contains(r.Storages, storage) {
+				continue
+			}
+			if len(group) > 0 && !r.Start.Before(groupEnd) {
+				closeGroup()
+				group = nil
+			}
+			if len(group) == 0 || r.End.After(groupEnd) {
+				groupEnd = r.End
+			}
+			group = append(group, i)
+		}
+		closeGroup()
+	}
+
+	load.Suggestions = stagger(runs, step)
+	return load
+}
+
+// stagger places runs one by one at the earliest time when their storages are free.
+// Only the first move of each job schedule is suggested, later runs of the schedule move with it.
+func stagger(runs []PlannedRun, step time.Duration) []Suggestion {
+	type interval struct {
+		start, end time.Time
+		jobID      int
+	}
+	type scheduleKey struct {
+		jobID    int
+		schedule string
+	}
+	busy := map[string][]interval{}
+	suggested := map[scheduleKey]bool{}
+	var suggestions []Suggestion
+
+	for _, r := range runs {
+		loc := r.Location
+		if loc == nil {
+			loc = r.Start.Location()
+		}
+		start := r.Start.In(loc)
+		for moved := true; moved; {
+			moved = false
+			end := start.Add(r.Duration())
+			for _, s := range r.Storages {
+				for _, b := range busy[s] {
+					if b.jobID != r.JobID && start.Before(b.end) && b.start.Before(end) {
+						start = RoundUp(b.end.In(loc), step)
+						end = start.Add(r.Duration())
+						moved = true
+					}
+				}
+			}
+		}
+		for _, s := range r.Storages {
+			busy[s] = append(busy[s], interval{start: start, end: start.Add(r.Duration()), jobID: r.JobID})
+		}
+
+		key := scheduleKey{r.JobID, r.Schedule}
+		if start.Equal(r.Start) || suggested[key] {
+			continue
+		}
+		suggested[key] = true
+		suggestion := Suggestion{
+			JobID:     r.JobID,
+			JobName:   r.JobName,
+			Action:    r.Action,
+			Schedule:  r.Schedule,
+			Current:   r.Start,
+			Suggested: start.In(r.Start.Location()),
+		}
+		// Schedule fires by the wall clock of its location, which can differ from the elapsed time on DST change
+		shift := wallClock(start).Sub(wallClock(r.Start.In(loc)))
+		if newSchedule, err := FromSpec(r.Schedule).Shift(shift); err != nil {
+			suggestion.Note = err.Error()
+		} else {
+			suggestion.NewSchedule = &newSchedule
+			suggestion.NewSpec, _ = newSchedule.Spec()
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions
+}
+
+// RoundUp returns the first time not before t whose clock in location of t is a multiple of step.
+// Step should divide an hour or a day, e.g. 5 minutes or 1 hour.
+func RoundUp(t time.Time, step time.Duration) time.Time {
+	if step <= 0 {
+		return t
+	}
+	year, month, day := t.Date()
+	clock := wallClock(t).Sub(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	clock = (clock + step - 1) / step * step
+	rounded := time.Date(year, month, day, 0, 0, 0, int(clock), t.Location())
+	// Wall clock which repeats when DST ends is resolved to its first occurrence
+	for rounded.Before(t) {
+		rounded = rounded.Add(step)
+	}
+	return rounded
+}
+
+// wallClock returns the clock of t in its location as UTC time, so wall clock times can be subtracted
+func wallClock(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,102 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundUp(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*3600+30*60)
+	kathmandu := time.FixedZone("NPT", 5*3600+45*60)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		step time.Duration
+		want time.Time
+	}{
+		{"exact", time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC), 5 * time.Minute, time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"minutes", time.Date(2026, 3, 1, 10, 16, 30, 0, time.UTC), 5 * time.Minute, time.Date(2026, 3, 1, 10, 20, 0, 0, time.UTC)},
+		{"half hour offset", time.Date(2026, 3, 1, 10, 10, 0, 0, kolkata), time.Hour, time.Date(2026, 3, 1, 11, 0, 0, 0, kolkata)},
+		{"quarter hour offset", time.Date(2026, 3, 1, 10, 50, 0, 0, kathmandu), 20 * time.Minute, time.Date(2026, 3, 1, 11, 0, 0, 0, kathmandu)},
+		{"next day", time.Date(2026, 3, 1, 23, 58, 0, 0, kolkata), 5 * time.Minute, time.Date(2026, 3, 2, 0, 0, 0, 0, kolkata)},
+		{"no step", time.Date(2026, 3, 1, 10, 16, 30, 0, time.UTC), 0, time.Date(2026, 3, 1, 10, 16, 30, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundUp(tt.t, tt.step); !got.Equal(tt.want) {
+				t.Errorf("RoundUp(%s, %s) = %s, want %s", tt.t, tt.step, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShift(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		shift    time.Duration
+		want     string
+		wantErr  bool
+	}{
+		{"daily", Schedule{Kind: KindDaily, Times: []string{"02:00"}}, 25 * time.Minute, "25 2 * * *", false},
+		{"daily over midnight", Schedule{Kind: KindDaily, Times: []string{"23:50"}}, 20 * time.Minute, "10 0 * * *", false},
+		{"weekly over midnight", Schedule{Kind: KindWeekly, Times: []string{"23:30"}, Weekdays: []int{0, 6}}, time.Hour, "30 0 * * 0,1", false},
+		{"weekly back over midnight", Schedule{Kind: KindWeekly, Times: []string{"00:10"}, Weekdays: []int{0}}, -20 * time.Minute, "50 23 * * 6", false},
+		{"monthly", Schedule{Kind: KindMonthly, Times: []string{"03:00"}, MonthDays: []int{1, 15}}, 90 * time.Minute, "30 4 1,15 * *", false},
+		{"monthly over midnight", Schedule{Kind: KindMonthly, Times: []string{"23:00"}, MonthDays: []int{1}}, 2 * time.Hour, "", true},
+		{"times on different days", Schedule{Kind: KindWeekly, Times: []string{"01:00", "23:00"}, Weekdays: []int{1}}, 2 * time.Hour, "", true},
+		{"times out of grid", Schedule{Kind: KindDaily, Times: []string{"02:00", "02:50"}}, 15 * time.Minute, "", true},
+		{"interval", Schedule{Kind: KindInterval, Interval: "4h"}, time.Hour, "", true},
+		{"custom cron", Schedule{Kind: KindCron, Cron: "0 */2 * * *"}, time.Hour, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shifted, err := tt.schedule.Shift(tt.shift)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Shift(%s) = %+v, want error", tt.shift, shifted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec, _ := shifted.Spec(); spec != tt.want {
+				t.Errorf("Shift(%s) spec = %q, want %q", tt.shift, spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLoadSuggestsScheduleInJobLocation(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*3600+30*60)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	first := PlannedRun{
+		JobID: 1, JobName: "db", Priority: 10, Action: "backup", Schedule: "0 2 * * *",
+		Start: time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC), End: time.Date(2026, 3, 1, 2, 40, 0, 0, time.UTC),
+		Storages: []string{"/mnt/backup"},
+	}
+	// 07:30 in Kolkata is 02:00 UTC
+	second := PlannedRun{
+		JobID: 2, JobName: "files", Action: "backup", Schedule: "30 7 * * *",
+		Start: time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC), End: time.Date(2026, 3, 1, 2, 10, 0, 0, time.UTC),
+		Storages: []string{"/mnt/backup"}, Location: kolkata,
+	}
+
+	load := AnalyzeLoad(from, from.Add(24*time.Hour), []PlannedRun{second, first}, time.Hour)
+	if len(load.Suggestions) != 1 {
+		t.Fatalf("suggestions = %+v, want one", load.Suggestions)
+	}
+	s := load.Suggestions[0]
+	// Next full hour in Kolkata after 08:10 is 09:00, which is 03:30 UTC
+	if want := time.Date(2026, 3, 1, 3, 30, 0, 0, time.UTC); !s.Suggested.Equal(want) {
+		t.Errorf("suggested = %s, want %s", s.Suggested, want)
+	}
+	if s.Suggested.Location() != time.UTC {
+		t.Errorf("suggested time is in %s, want location of the run start", s.Suggested.Location())
+	}
+	if s.JobID != 2 || s.NewSpec != "0 9 * * *" || s.NewSchedule == nil {
+		t.Errorf("suggestion = %+v, want job 2 moved to 0 9 * * *", s)
+	}
+}
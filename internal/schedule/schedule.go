@@ -0,0 +1,306 @@
+// Package schedule keeps job schedule as structured settings and converts it to cron spec.
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule kinds
+const (
+	KindManual   = "manual"   // started only by hand, API or other triggers
+	KindInterval = "interval" // every Interval
+	KindDaily    = "daily"    // every day at Times
+	KindWeekly   = "weekly"   // on Weekdays at Times
+	KindMonthly  = "monthly"  // on MonthDays at Times
+	KindCron     = "cron"     // custom cron spec
+)
+
+// Parser reads cron specs: 5 fields with optional seconds field first, descriptors like @daily and @every 4h
+var Parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ManualSpec is the schedule of jobs which are not started by cron
+const ManualSpec = "manual"
+
+var weekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+type Schedule struct {
+	Kind string `json:"kind"`
+	// Times of day "15:04" for daily, weekly and monthly schedules
+	Times []string `json:"times,omitempty"`
+	// 0 is Sunday
+	Weekdays  []int  `json:"weekdays,omitempty"`
+	MonthDays []int  `json:"month_days,omitempty"`
+	Interval  string `json:"interval,omitempty"` // e.g. "4h" or "30m"
+	Cron      string `json:"cron,omitempty"`
+}
+
+// Validate checks settings of the schedule kind and that they can be written as one cron spec
+func (s Schedule) Validate() error {
+	_, err := s.Spec()
+	return err
+}
+
+// Spec returns cron spec of the schedule, "manual" for manual schedule
+func (s Schedule) Spec() (string, error) {
+	switch s.Kind {
+	case KindManual, "":
+		return ManualSpec, nil
+	case KindInterval:
+		d, err := time.ParseDuration(strings.TrimSpace(s.Interval))
+		if err != nil {
+			return "", fmt.Errorf("wrong interval '%s', expected e.g. 30m or 4h", s.Interval)
+		}
+		if d < time.Minute {
+			return "", fmt.Errorf("interval must be at least 1 minute")
+		}
+		return "@every " + d.String(), nil
+	case KindDaily, KindWeekly, KindMonthly:
+		minutes, hours, err := s.clock()
+		if err != nil {
+			return "", err
+		}
+		dom, dow := "*", "*"
+		switch s.Kind {
+		case KindWeekly:
+			if len(s.Weekdays) == 0 {
+				return "", fmt.Errorf("choose at least one day of week")
+			}
+			for _, d := range s.Weekdays {
+				if d < 0 || d > 6 {
+					return "", fmt.Errorf("wrong day of week %d", d)
+				}
+			}
+			dow = joinInts(s.Weekdays)
+		case KindMonthly:
+			if len(s.MonthDays) == 0 {
+				return "", fmt.Errorf("choose at least one day of month")
+			}
+			for _, d := range s.MonthDays {
+				if d < 1 || d > 31 {
+					return "", fmt.Errorf("wrong day of month %d", d)
+				}
+			}
+			dom = joinInts(s.MonthDays)
+		}
+		return fmt.Sprintf("%s %s %s * %s", minutes, hours, dom, dow), nil
+	case KindCron:
+		spec := strings.TrimSpace(s.Cron)
+		if spec == "" {
+			return "", fmt.Errorf("cron spec is empty")
+		}
+		if _, err := Parser.Parse(spec); err != nil {
+			return "", fmt.Errorf("invalid cron spec '%s': %w", spec, err)
+		}
+		return spec, nil
+	}
+	return "", fmt.Errorf("unknown schedule kind '%s'", s.Kind)
+}
+
+// clock returns minute and hour fields for Times. Cron spec runs at every combination of its hours and minutes,
+// so times must make such combinations, e.g. 02:00, 02:30, 14:00, 14:30.
+func (s Schedule) clock() (string, string, error) {
+	if len(s.Times) == 0 {
+		return "", "", fmt.Errorf("set at least one time of day")
+	}
+	var minutes, hours []int
+	times := map[[2]int]bool{}
+	for _, t := range s.Times {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(t))
+		if err != nil {
+			return "", "", fmt.Errorf("wrong time '%s', expected HH:MM", t)
+		}
+		times[[2]int{parsed.Hour(), parsed.Minute()}] = true
+		if !slices.Contains(hours, parsed.Hour()) {
+			hours = append(hours, parsed.Hour())
+		}
+		if !slices.Contains(minutes, parsed.Minute()) {
+			minutes = append(minutes, parsed.Minute())
+		}
+	}
+	if len(hours)*len(minutes) != len(times) {
+		return "", "", fmt.Errorf("times %s can't be one schedule, every hour must have the same minutes", strings.Join(s.Times, ", "))
+	}
+	return joinInts(minutes), joinInts(hours), nil
+}
+
+func joinInts(values []int) string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	parts := make([]string, len(sorted))
+	for i, v := range sorted {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// FromSpec makes structured schedule from cron spec.
+// Specs which don't match daily, weekly, monthly or interval form are kept as custom cron.
+func FromSpec(spec string) Schedule {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == ManualSpec {
+		return Schedule{Kind: KindManual}
+	}
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		if d, err := time.ParseDuration(strings.TrimSpace(interval)); err == nil && d >= time.Minute {
+			return Schedule{Kind: KindInterval, Interval: d.String()}
+		}
+	}
+
+	custom := Schedule{Kind: KindCron, Cron: spec}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 || fields[3] != "*" {
+		return custom
+	}
+	minutes, ok1 := parseList(fields[0], 0, 59)
+	hours, ok2 := parseList(fields[1], 0, 23)
+	if !ok1 || !ok2 {
+		return custom
+	}
+	var times []string
+	for _, h := range hours {
+		for _, m := range minutes {
+			times = append(times, fmt.Sprintf("%02d:%02d", h, m))
+		}
+	}
+
+	switch {
+	case fields[2] == "*" && fields[4] == "*":
+		return Schedule{Kind: KindDaily, Times: times}
+	case fields[2] == "*":
+		if days, ok := parseList(fields[4], 0, 6); ok {
+			return Schedule{Kind: KindWeekly, Times: times, Weekdays: days}
+		}
+	case fields[4] == "*":
+		if days, ok := parseList(fields[2], 1, 31); ok {
+			return Schedule{Kind: KindMonthly, Times: times, MonthDays: days}
+		}
+	}
+	return custom
+}
+
+// Shift returns the schedule with its times moved by d, so suggested start time can be applied.
+// Interval and custom cron schedules are not shifted, neither are times of weekly schedule which move to
+// different days or times of monthly schedule which move to another day.
+func (s Schedule) Shift(d time.Duration) (Schedule, error) {
+	switch s.Kind {
+	case KindDaily, KindWeekly, KindMonthly:
+	default:
+		return Schedule{}, fmt.Errorf("%s schedule can't be shifted, change it by hand", s)
+	}
+
+	const minutesPerDay = 24 * 60
+	shifted := s
+	shifted.Times = nil
+	dayShift := 0
+	for i, t := range s.Times {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(t))
+		if err != nil {
+			return Schedule{}, fmt.Errorf("wrong time '%s', expected HH:MM", t)
+		}
+		minutes := parsed.Hour()*60 + parsed.Minute() + int(d/time.Minute)
+		day := minutes / minutesPerDay
+		if minutes < 0 {
+			day = (minutes - minutesPerDay + 1) / minutesPerDay
+		}
+		if i > 0 && day != dayShift {
+			return Schedule{}, fmt.Errorf("times %s move to different days", strings.Join(s.Times, ", "))
+		}
+		dayShift = day
+		minutes -= day * minutesPerDay
+		shifted.Times = append(shifted.Times, fmt.Sprintf("%02d:%02d", minutes/60, minutes%60))
+	}
+
+	if dayShift != 0 {
+		switch s.Kind {
+		case KindWeekly:
+			shifted.Weekdays = make([]int, len(s.Weekdays))
+			for i, w := range s.Weekdays {
+				shifted.Weekdays[i] = ((w+dayShift)%7 + 7) % 7
+			}
+			slices.Sort(shifted.Weekdays)
+		case KindMonthly:
+			return Schedule{}, fmt.Errorf("times %s move to another day of month", strings.Join(s.Times, ", "))
+		}
+	}
+	if err := shifted.Validate(); err != nil {
+		return Schedule{}, err
+	}
+	return shifted, nil
+}
+
+// parseList reads comma separated numbers of cron field
+func parseList(field string, min, max int) ([]int, bool) {
+	var values []int
+	for _, part := range strings.Split(field, ",") {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < min || v > max {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	return slices.Compact(values), true
+}
+
+// String describes the schedule for people
+func (s Schedule) String() string {
+	switch s.Kind {
+	case KindManual, "":
+		return "manual"
+	case KindInterval:
+		return "every " + s.Interval
+	case KindDaily:
+		return "daily at " + strings.Join(s.Times, ", ")
+	case KindWeekly:
+		var days []string
+		for _, d := range s.Weekdays {
+			if d >= 0 && d < len(weekdayNames) {
+				days = append(days, weekdayNames[d])
+			}
+		}
+		return fmt.Sprintf("weekly on %s at %s", strings.Join(days, ", "), strings.Join(s.Times, ", "))
+	case KindMonthly:
+		return fmt.Sprintf("monthly on day %s at %s", joinInts(s.MonthDays), strings.Join(s.Times, ", "))
+	}
+	return "cron " + s.Cron
+}
+
+// HasWeekday is used by the job forms
+func (s Schedule) HasWeekday(day int) bool {
+	return slices.Contains(s.Weekdays, day)
+}
+
+// TimesText and MonthDaysText are values of the job form inputs
+func (s Schedule) TimesText() string {
+	return strings.Join(s.Times, ", ")
+}
+
+func (s Schedule) MonthDaysText() string {
+	return strings.ReplaceAll(joinInts(s.MonthDays), ",", ", ")
+}
+
+// Marshal returns JSON kept in the database
+func (s Schedule) Marshal() string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Unmarshal reads schedule from database, spec is used when the job was saved before structured schedules
+func Unmarshal(data, spec string) Schedule {
+	var s Schedule
+	if data == "" || json.Unmarshal([]byte(data), &s) != nil || s.Kind == "" {
+		return FromSpec(spec)
+	}
+	return s
+}
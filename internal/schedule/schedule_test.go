@@ -0,0 +1,75 @@
+package schedule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromSpecRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		// want is the schedule read back from spec, same as schedule when it is empty
+		want *Schedule
+	}{
+		{name: "manual", schedule: Schedule{Kind: KindManual}},
+		{name: "interval", schedule: Schedule{Kind: KindInterval, Interval: "4h0m0s"}},
+		{name: "interval in minutes", schedule: Schedule{Kind: KindInterval, Interval: "90m"}, want: &Schedule{Kind: KindInterval, Interval: "1h30m0s"}},
+		{name: "daily", schedule: Schedule{Kind: KindDaily, Times: []string{"02:00"}}},
+		{name: "daily grid", schedule: Schedule{Kind: KindDaily, Times: []string{"14:30", "02:00", "02:30", "14:00"}},
+			want: &Schedule{Kind: KindDaily, Times: []string{"02:00", "02:30", "14:00", "14:30"}}},
+		{name: "weekly", schedule: Schedule{Kind: KindWeekly, Times: []string{"23:45"}, Weekdays: []int{0, 6}}},
+		{name: "weekly unsorted days", schedule: Schedule{Kind: KindWeekly, Times: []string{"01:05"}, Weekdays: []int{5, 1, 3}},
+			want: &Schedule{Kind: KindWeekly, Times: []string{"01:05"}, Weekdays: []int{1, 3, 5}}},
+		{name: "monthly", schedule: Schedule{Kind: KindMonthly, Times: []string{"03:00"}, MonthDays: []int{1, 15, 31}}},
+		{name: "cron with seconds", schedule: Schedule{Kind: KindCron, Cron: "30 0 2 * * *"}},
+		{name: "cron with step", schedule: Schedule{Kind: KindCron, Cron: "*/15 * * * *"}},
+		{name: "cron with months", schedule: Schedule{Kind: KindCron, Cron: "0 2 1 1,7 *"}},
+		{name: "cron with days of month and week", schedule: Schedule{Kind: KindCron, Cron: "0 2 1 * 1"}},
+		{name: "descriptor", schedule: Schedule{Kind: KindCron, Cron: "@daily"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := tt.schedule.Spec()
+			if err != nil {
+				t.Fatalf("Spec() error: %v", err)
+			}
+			want := tt.schedule
+			if tt.want != nil {
+				want = *tt.want
+			}
+			got := FromSpec(spec)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("FromSpec(%q) = %+v, want %+v", spec, got, want)
+			}
+			if again, err := got.Spec(); err != nil || again != spec {
+				t.Errorf("Spec() of read schedule = %q, %v, want %q", again, err, spec)
+			}
+		})
+	}
+}
+
+func TestScheduleSpecErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"short interval", Schedule{Kind: KindInterval, Interval: "30s"}},
+		{"wrong interval", Schedule{Kind: KindInterval, Interval: "daily"}},
+		{"no times", Schedule{Kind: KindDaily}},
+		{"wrong time", Schedule{Kind: KindDaily, Times: []string{"25:00"}}},
+		{"times out of grid", Schedule{Kind: KindDaily, Times: []string{"02:00", "14:30"}}},
+		{"no weekdays", Schedule{Kind: KindWeekly, Times: []string{"02:00"}}},
+		{"wrong weekday", Schedule{Kind: KindWeekly, Times: []string{"02:00"}, Weekdays: []int{7}}},
+		{"wrong month day", Schedule{Kind: KindMonthly, Times: []string{"02:00"}, MonthDays: []int{32}}},
+		{"wrong cron", Schedule{Kind: KindCron, Cron: "0 25 * * *"}},
+		{"unknown kind", Schedule{Kind: "yearly"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if spec, err := tt.schedule.Spec(); err == nil {
+				t.Errorf("Spec() = %q, want error", spec)
+			}
+		})
+	}
+}
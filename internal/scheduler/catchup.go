@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Limit of schedule steps when looking for the last missed run, e.g. every minute job down for a week
+const maxMissedRunsCheck = 100000
+
+// Longest delay between the time cron job should fire and its start, used to find the scheduled fire time
+const cronFireLookback = time.Minute
+
+// lastMissedRun returns the newest time when the schedule should have fired after since and before now
+func lastMissedRun(schedule cron.Schedule, since, now time.Time) (time.Time, bool) {
+	var last time.Time
+	next := schedule.Next(since)
+	for i := 0; i < maxMissedRunsCheck && !next.IsZero() && !next.After(now); i++ {
+		last = next
+		next = schedule.Next(next)
+	}
+	return last, !last.IsZero()
+}
+
+// catchUp starts runs of the job schedules which were missed while the server was down.
+// Runs start one after another, so overlap policy of the job doesn't drop them.
+func (sm *SchedulerManager) catchUp(job *database.BackupJob, schedules []parsedSchedule, now time.Time) {
+	var attempts []runAttempt
+	for _, s := range schedules {
+		if sm.catchUpMissedRun(job, s, now) {
+			attempts = append(attempts, runAttempt{number: 1, action: s.Action})
+		}
+	}
+	if len(attempts) == 0 {
+		return
+	}
+	go func() {
+		for _, attempt := range attempts {
+			sm.runAttempt(job, database.RunTriggerCatchUp, attempt)
+		}
+	}()
+}
+
+// catchUpMissedRun tells if the missed run of the schedule must be started.
+// Decision is made by missed run policy of the job, skipped run is recorded.
+func (sm *SchedulerManager) catchUpMissedRun(job *database.BackupJob, s parsedSchedule, now time.Time) bool {
+	since := job.CreatedAt.Time
+	if job.LastRunTime.Valid {
+		since = job.LastRunTime.Time
+	}
+	// Schedules with different actions are caught up by the last run of their own action
+	if lastRun, err := sm.RunRepo.GetLastRunStart(job.ID, s.Action); err != nil {
+		log.Printf("Scheduler: Can't get last %s run of job ID %d: %v", s.Action, job.ID, err)
+	} else if !lastRun.IsZero() {
+		since = lastRun
+	}
+
+	missed, ok := lastMissedRun(s.cron, since, now)
+	if !ok || !sm.claimRun(job.ID, s.claim(database.RunTriggerCatchUp), missed) {
+		return false
+	}
+
+	var reason string
+	switch job.MissedRunPolicy {
+	case database.MissedRunRunOnce:
+	case database.MissedRunGrace:
+		grace := time.Duration(job.MissedGraceMinutes) * time.Minute
+		if now.Sub(missed) > grace {
+			reason = fmt.Sprintf("missed %s run at %s is older than grace window %s", s.Action, missed.Format("2006-01-02 15:04"), grace)
+		}
+	default:
+		reason = fmt.Sprintf("missed %s run at %s, job policy is to skip missed runs", s.Action, missed.Format("2006-01-02 15:04"))
+	}
+
+	if reason != "" {
+		log.Printf("Scheduler: Job '%s' (ID: %d) %s", job.Name, job.ID, reason)
+		if err := sm.RunRepo.RecordNotStartedRun(job.ID, database.RunTriggerCatchUp, s.Action, 0, 1, database.RunStatusSkipped, "Run skipped: "+reason, now); err != nil {
+			log.Printf("Scheduler: Failed to record skipped run for job ID %d: %v", job.ID, err)
+		}
+		return false
+	}
+
+	log.Printf("Scheduler: Job '%s' (ID: %d) missed %s run at %s, starting catch-up run", job.Name, job.ID, s.Action, missed.Format("2006-01-02 15:04"))
+	return true
+}
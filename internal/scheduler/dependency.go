@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"log"
+)
+
+// triggerDependents starts jobs which run after the finished job.
+// Job with several upstream jobs starts when all of them finished with their condition after its own last run.
+func (sm *SchedulerManager) triggerDependents(upstream *database.BackupJob, result backup.BackupResult) {
+	deps, err := sm.JobRepo.GetDependentJobs(upstream.ID)
+	if err != nil {
+		log.Printf("Scheduler: Failed to load jobs depending on job ID %d: %v", upstream.ID, err)
+		return
+	}
+
+	for _, dep := range deps {
+		if !dep.Satisfied(result.Status) {
+			log.Printf("Scheduler: Job ID %d is not started, job '%s' finished with '%s' and condition is %s",
+				dep.JobID, upstream.Name, result.Status, dep.Condition)
+			continue
+		}
+
+		job, err := sm.JobRepo.GetJobByID(dep.JobID)
+		if err != nil {
+			log.Printf("Scheduler: Failed to load dependent job ID %d: %v", dep.JobID, err)
+			continue
+		}
+		if !job.IsActive {
+			log.Printf("Scheduler: Dependent job '%s' (ID: %d) is inactive, not started", job.Name, job.ID)
+			continue
+		}
+		if !sm.upstreamsReady(job, upstream.ID) {
+			log.Printf("Scheduler: Dependent job '%s' (ID: %d) waits for other upstream jobs", job.Name, job.ID)
+			continue
+		}
+
+		log.Printf("Scheduler: Job '%s' (ID: %d) finished, starting dependent job '%s' (ID: %d)", upstream.Name, upstream.ID, job.Name, job.ID)
+		if _, err := sm.StartJob(job, database.RunTriggerDependency, false); err != nil {
+			log.Printf("Scheduler: Dependent job ID %d not started: %v", job.ID, err)
+		}
+	}
+}
+
+// upstreamsReady checks upstream jobs other than the one which just finished
+func (sm *SchedulerManager) upstreamsReady(job *database.BackupJob, finishedJobID int) bool {
+	for _, dep := range job.Dependencies {
+		if dep.DependsOnJobID == finishedJobID {
+			continue
+		}
+		last, err := sm.RunRepo.GetLastFinishedRun(dep.DependsOnJobID)
+		if err != nil {
+			log.Printf("Scheduler: %v", err)
+			return false
+		}
+		if last == nil || !dep.Satisfied(last.Status) {
+			return false
+		}
+		if job.LastRunTime.Valid && last.EndTime.Time.Before(job.LastRunTime.Time) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const allHours = 1<<24 - 1
+
+// dstSchedule fixes cron schedule around daylight saving time changes, like classic cron does:
+// run at the time which doesn't exist because clock moved forward is started right after the change,
+// run at the time which repeats because clock moved back is started only once.
+// Schedules which run every hour are not changed.
+type dstSchedule struct {
+	spec *cron.SpecSchedule
+}
+
+func (s dstSchedule) Next(t time.Time) time.Time {
+	next := s.spec.Next(t)
+	if next.IsZero() || s.spec.Hour&allHours == allHours {
+		return next
+	}
+	if skipped := s.skippedBetween(t, next); !skipped.IsZero() {
+		return skipped
+	}
+	// Schedule can have several runs in the repeated hour, e.g. every 30 minutes at 1 o'clock
+	for !next.IsZero() && s.repeated(next) {
+		next = s.spec.Next(next)
+	}
+	return next
+}
+
+// skippedBetween returns the run which fell into the hour skipped by clock moving forward between t and next
+func (s dstSchedule) skippedBetween(t, next time.Time) time.Time {
+	_, offsetBefore := t.In(s.spec.Location).Zone()
+	_, offsetAfter := next.In(s.spec.Location).Zone()
+	if offsetAfter <= offsetBefore {
+		return time.Time{}
+	}
+
+	// Wall clock without the change tells when the skipped run should have been
+	fixed := *s.spec
+	fixed.Location = time.FixedZone("", offsetBefore)
+	if run := fixed.Next(t); !run.IsZero() && run.Before(next) {
+		return run
+	}
+	return time.Time{}
+}
+
+// repeated tells if the same wall clock time already was a run before the clock moved back
+func (s dstSchedule) repeated(next time.Time) bool {
+	local := next.In(s.spec.Location)
+	_, offset := local.Zone()
+	_, offsetBefore := local.Add(-2 * time.Hour).Zone()
+	if offsetBefore <= offset {
+		return false
+	}
+
+	earlier := next.Add(-time.Duration(offsetBefore-offset) * time.Second).In(s.spec.Location)
+	if earlier.Format(time.DateTime) != local.Format(time.DateTime) {
+		return false
+	}
+	return s.spec.Next(earlier.Add(-time.Second)).Equal(earlier)
+}
@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDSTScheduleNext(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data is not available: %v", err)
+	}
+	at := func(month time.Month, day, hour, minute int, zone string) string {
+		return time.Date(2026, month, day, hour, minute, 0, 0, loc).Format("01-02 15:04") + " " + zone
+	}
+
+	// Clock moves forward from 02:00 to 03:00 on 2026-03-08 and back from 02:00 to 01:00 on 2026-11-01
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want []string
+	}{
+		{
+			name: "spring forward runs skipped time after the change",
+			spec: "30 2 * * *",
+			from: time.Date(2026, 3, 8, 0, 0, 0, 0, loc),
+			want: []string{at(3, 8, 3, 30, "EDT"), at(3, 9, 2, 30, "EDT"), at(3, 10, 2, 30, "EDT")},
+		},
+		{
+			name: "spring forward on weekly schedule",
+			spec: "0 2 * * 0",
+			from: time.Date(2026, 3, 1, 12, 0, 0, 0, loc),
+			want: []string{at(3, 8, 3, 0, "EDT"), at(3, 15, 2, 0, "EDT")},
+		},
+		{
+			name: "spring forward keeps runs outside skipped hour",
+			spec: "0 4 * * *",
+			from: time.Date(2026, 3, 7, 12, 0, 0, 0, loc),
+			want: []string{at(3, 8, 4, 0, "EDT"), at(3, 9, 4, 0, "EDT")},
+		},
+		{
+			name: "fall back runs repeated time once",
+			spec: "30 1 * * *",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, loc),
+			want: []string{at(11, 1, 1, 30, "EDT"), at(11, 2, 1, 30, "EST")},
+		},
+		{
+			name: "fall back skips every repeated run",
+			spec: "*/30 1 * * *",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, loc),
+			want: []string{at(11, 1, 1, 0, "EDT"), at(11, 1, 1, 30, "EDT"), at(11, 2, 1, 0, "EST")},
+		},
+		{
+			name: "hourly schedule runs in both repeated hours",
+			spec: "0 * * * *",
+			from: time.Date(2026, 11, 1, 0, 30, 0, 0, loc),
+			want: []string{"11-01 01:00 EDT", "11-01 01:00 EST", "11-01 02:00 EST"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec, "America/New_York")
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := tt.from
+			for i, want := range tt.want {
+				next = s.Next(next)
+				if got := next.In(loc).Format("01-02 15:04 MST"); got != want {
+					t.Fatalf("run %d of '%s' = %s, want %s", i+1, tt.spec, got, want)
+				}
+			}
+		})
+	}
+}
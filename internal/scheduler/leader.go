@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"log"
+	"time"
+)
+
+const (
+	leaderLeaseName = "scheduler"
+	// Claims are kept long enough to cover any failover, then removed
+	runClaimRetention = 7 * 24 * time.Hour
+)
+
+// SetLeaderElection makes instances which share the database elect one scheduling leader.
+// Only the leader starts scheduled, catch-up and watch runs. Without it the instance is always the leader.
+func (sm *SchedulerManager) SetLeaderElection(leaseRepo *database.LeaseRepo, instanceID string, ttl time.Duration) {
+	sm.LeaseRepo = leaseRepo
+	sm.instanceID = instanceID
+	sm.leaseTTL = ttl
+	log.Printf("Scheduler: Leader election enabled, instance: %s, lease: %s", instanceID, ttl)
+}
+
+// IsLeader tells if this instance schedules jobs. Leadership ends when the lease was not renewed in time.
+func (sm *SchedulerManager) IsLeader() bool {
+	if sm.LeaseRepo == nil {
+		return true
+	}
+	sm.leaderMu.Lock()
+	defer sm.leaderMu.Unlock()
+	return time.Now().Before(sm.leaderUntil)
+}
+
+// LeaderInfo returns ID of this instance and if it is the leader
+func (sm *SchedulerManager) LeaderInfo() (instanceID string, leader bool) {
+	return sm.instanceID, sm.IsLeader()
+}
+
+// renewLease takes or renews the lease and reports if this instance just became the leader
+func (sm *SchedulerManager) renewLease() (acquired bool) {
+	now := time.Now()
+	ok, err := sm.LeaseRepo.AcquireLease(leaderLeaseName, sm.instanceID, sm.leaseTTL, now)
+	if err != nil {
+		log.Printf("Scheduler: Can't renew leader lease: %v", err)
+	}
+
+	sm.leaderMu.Lock()
+	defer sm.leaderMu.Unlock()
+	wasLeader := now.Before(sm.leaderUntil)
+	if ok {
+		sm.leaderUntil = now.Add(sm.leaseTTL)
+	} else if err == nil {
+		sm.leaderUntil = time.Time{}
+	}
+	// On database error leadership lasts until the lease expires
+	isLeader := now.Before(sm.leaderUntil)
+
+	switch {
+	case isLeader && !wasLeader:
+		log.Printf("Scheduler: Instance %s became the leader", sm.instanceID)
+		return true
+	case !isLeader && wasLeader:
+		log.Printf("Scheduler: Instance %s is not the leader anymore, scheduled runs are left to the leader", sm.instanceID)
+	}
+	return false
+}
+
+// leaderLoop renews the lease until the scheduler stops.
+// New leader loads jobs again and checks runs missed while there was no leader.
+func (sm *SchedulerManager) leaderLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(sm.leaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if sm.renewLease() {
+				sm.onLeadership()
+			} else if sm.IsLeader() {
+				// Pause could be changed by another instance
+				sm.loadPause()
+			}
+		}
+	}
+}
+
+func (sm *SchedulerManager) onLeadership() {
+	sm.loadPause()
+	if err := sm.LeaseRepo.PruneRunClaims(time.Now().Add(-runClaimRetention)); err != nil {
+		log.Printf("Scheduler: %v", err)
+	}
+	sm.reloadMu.Lock()
+	sm.caughtUp = false
+	sm.reloadMu.Unlock()
+	sm.LoadAndScheduleJobs()
+}
+
+// claimRun makes sure the scheduled run fired at fireTime is executed by one instance only
+func (sm *SchedulerManager) claimRun(jobID int, trigger string, fireTime time.Time) bool {
+	if !sm.IsLeader() {
+		log.Printf("Scheduler: Instance is not the leader, %s run of job ID %d is left to the leader", trigger, jobID)
+		return false
+	}
+	if sm.LeaseRepo == nil {
+		return true
+	}
+	claimed, err := sm.LeaseRepo.ClaimRun(jobID, trigger, fireTime, sm.instanceID)
+	if err != nil {
+		log.Printf("Scheduler: Run of job ID %d not started: %v", jobID, err)
+		return false
+	}
+	if !claimed {
+		log.Printf("Scheduler: %s run of job ID %d at %s is already claimed by another instance", trigger, jobID, fireTime.Format("2006-01-02 15:04:05"))
+	}
+	return claimed
+}
+
+// releaseLeadership lets another instance take over right away
+func (sm *SchedulerManager) releaseLeadership() {
+	if sm.LeaseRepo == nil || !sm.IsLeader() {
+		return
+	}
+	sm.leaderMu.Lock()
+	sm.leaderUntil = time.Time{}
+	sm.leaderMu.Unlock()
+	if err := sm.LeaseRepo.ReleaseLease(leaderLeaseName, sm.instanceID); err != nil {
+		log.Printf("Scheduler: %v", err)
+	}
+}
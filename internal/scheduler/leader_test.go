@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"testing"
+	"time"
+)
+
+func TestLeaderElection(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+	a, db := newTestScheduler(t)
+	b := NewSchedulerManager(database.NewJobRepo(db), database.NewReplicaRepo(db), database.NewRunRepo(db), database.NewSettingsRepo(db))
+	a.SetLeaderElection(database.NewLeaseRepo(db), "a", ttl)
+	b.SetLeaderElection(database.NewLeaseRepo(db), "b", ttl)
+	fireTime := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+
+	if !a.renewLease() || b.renewLease() {
+		t.Fatal("first instance should become the only leader")
+	}
+	if !a.claimRun(1, database.RunTriggerCron, fireTime) {
+		t.Fatal("leader could not claim the run")
+	}
+	if b.claimRun(1, database.RunTriggerCron, fireTime.Add(time.Hour)) {
+		t.Fatal("instance which is not the leader claimed a run")
+	}
+
+	// Renewed lease outlives its first term
+	time.Sleep(ttl / 2)
+	if a.renewLease() || !a.IsLeader() {
+		t.Fatal("leader lost the lease on renewal")
+	}
+	time.Sleep(ttl / 2)
+	if b.renewLease() || b.IsLeader() {
+		t.Fatal("renewed lease was taken over")
+	}
+
+	// Leader stopped renewing, the other instance takes over after expiration
+	time.Sleep(ttl + 50*time.Millisecond)
+	if a.IsLeader() {
+		t.Fatal("leadership outlived the lease")
+	}
+	if !b.renewLease() || !b.IsLeader() {
+		t.Fatal("expired lease was not taken over")
+	}
+	if a.renewLease() || a.IsLeader() {
+		t.Fatal("old leader took the lease back")
+	}
+
+	// Run claimed by the old leader is not started again after failover
+	if b.claimRun(1, database.RunTriggerCron, fireTime) {
+		t.Fatal("run was claimed twice")
+	}
+	if !b.claimRun(1, database.RunTriggerCron, fireTime.Add(time.Hour)) {
+		t.Fatal("new leader could not claim the next run")
+	}
+
+	b.releaseLeadership()
+	if b.IsLeader() || !a.renewLease() {
+		t.Fatal("released lease was not taken over right away")
+	}
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"backup-app/internal/schedule"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+const (
+	// Number of the latest successful runs used to estimate duration of the job run
+	loadHistoryRuns = 10
+	// Duration of runs of jobs without successful runs in history
+	defaultRunEstimate = 10 * time.Minute
+	// Suggested start times are rounded to this step
+	loadSuggestionStep = 5 * time.Minute
+	// Limit of runs of one schedule in the timeline, e.g. for schedules firing every minute
+	maxPlannedRunsPerSchedule = 500
+)
+
+// ScheduleLoad returns timeline of runs which active jobs start by their schedules between from and to.
+// Duration of every run is estimated from the run history, runs of different jobs which write to
+// the same storage at the same time are reported as conflicts with suggested staggered start times.
+func (sm *SchedulerManager) ScheduleLoad(from, to time.Time) (*schedule.Load, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("end of the period %s is not after its start %s", to.Format("2006-01-02 15:04"), from.Format("2006-01-02 15:04"))
+	}
+	jobs, err := sm.JobRepo.GetAllJobs()
+	if err != nil {
+		return nil, err
+	}
+	history, err := sm.RunRepo.GetRecentSuccessfulRuns(loadHistoryRuns)
+	if err != nil {
+		return nil, err
+	}
+	estimates := newRunEstimates(history)
+
+	var runs []schedule.PlannedRun
+	for _, job := range jobs {
+		if !job.IsActive {
+			continue
+		}
+		loc, err := database.LoadLocation(job.TimeZone)
+		if err != nil {
+			log.Printf("Scheduler: Job '%s' (ID: %d) is not in schedule load: %v", job.Name, job.ID, err)
+			continue
+		}
+		var storages []string
+		for _, d := range job.DestinationPaths() {
+			if key := backup.StorageKey(d); !slices.Contains(storages, key) {
+				storages = append(storages, key)
+			}
+		}
+
+		for _, s := range cronSchedules(&job) {
+			cronSchedule, err := ParseSchedule(s.Schedule, job.TimeZone)
+			if err != nil {
+				log.Printf("Scheduler: Job '%s' (ID: %d) is not in schedule load: %v", job.Name, job.ID, err)
+				continue
+			}
+			duration, estimated := estimates.duration(job.ID, s.Action)
+
+			next := from
+			for i := 0; i < maxPlannedRunsPerSchedule; i++ {
+				next = cronSchedule.Next(next)
+				if next.IsZero() || !next.Before(to) {
+					break
+				}
+				start := next.In(from.Location())
+				runs = append(runs, schedule.PlannedRun{
+					JobID:     job.ID,
+					JobName:   job.Name,
+					Priority:  job.Priority,
+					Action:    s.Action,
+					Schedule:  s.Schedule,
+					Start:     start,
+					End:       start.Add(duration),
+					Estimated: estimated,
+					Storages:  storages,
+					Location:  loc,
+				})
+			}
+		}
+	}
+
+	return schedule.AnalyzeLoad(from, to, runs, loadSuggestionStep), nil
+}
+
+// runEstimates keeps durations of the latest successful runs by job and action
+type runEstimates map[int]map[string][]time.Duration
+
+func newRunEstimates(runs []database.BackupRun) runEstimates {
+	estimates := runEstimates{}
+	for _, r := range runs {
+		if estimates[r.JobID] == nil {
+			estimates[r.JobID] = map[string][]time.Duration{}
+		}
+		estimates[r.JobID][r.Action] = append(estimates[r.JobID][r.Action], r.Duration())
+	}
+	return estimates
+}
+
+// duration returns median duration of the job runs with the action, or of all runs of the job when the action has no history.
+// estimated is false when the job has no successful runs and default duration is used.
+func (e runEstimates) duration(jobID int, action string) (d time.Duration, estimated bool) {
+	durations := e[jobID][action]
+	if len(durations) == 0 {
+		for _, actionDurations := range e[jobID] {
+			durations = append(durations, actionDurations...)
+		}
+	}
+	if len(durations) == 0 {
+		return defaultRunEstimate, false
+	}
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	d = sorted[len(sorted)/2]
+	// Very short runs are shown as one minute, so they are visible and still checked for overlaps
+	return max(d, time.Minute), true
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	ErrJobRunning    = errors.New("job is already running")
+	ErrJobQueued     = errors.New("run of the job is already queued")
+	ErrJobNotRunning = errors.New("job is not running")
+
+	errReplacedByNewerRun = errors.New("replaced by newer run of the job")
+	errCancelledByUser    = errors.New("cancelled by user")
+)
+
+// jobRun is one admitted run of the job. It starts when start channel is closed.
+type jobRun struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	start  chan struct{}
+	done   chan struct{}
+	// Paths changed in the source, the run copies only them when it is set
+	changedPaths []string
+	// Row of the run in history, 0 when it could not be saved
+	runID int
+}
+
+// jobLock keeps the working run of the job and the one which waits for it
+type jobLock struct {
+	current *jobRun
+	next    *jobRun
+}
+
+func newJobRun() *jobRun {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &jobRun{
+		ctx:    ctx,
+		cancel: cancel,
+		start:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// admit decides by overlap policy of the job if new run can start.
+// queued is true when the run has to wait for the current one.
+// Rejected attempt is recorded in run history.
+func (sm *SchedulerManager) admit(job *database.BackupJob, trigger string, attempt runAttempt) (run *jobRun, queued bool, err error) {
+	run, queued, err = sm.tryAdmit(job)
+	if err != nil {
+		// Skip is recorded after locksMu is released, so slow database does not hold up other jobs
+		log.Printf("Scheduler: Run of job '%s' (ID: %d) skipped: %v", job.Name, job.ID, err)
+		sm.recordSkip(job, trigger, attempt.runID, attempt, err)
+		return nil, false, err
+	}
+	return run, queued, nil
+}
+
+// tryAdmit applies overlap policy of the job to the new run
+func (sm *SchedulerManager) tryAdmit(job *database.BackupJob) (run *jobRun, queued bool, err error) {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+
+	if sm.draining {
+		return nil, false, ErrShuttingDown
+	}
+
+	lock := sm.locks[job.ID]
+	if lock == nil {
+		lock = &jobLock{}
+		sm.locks[job.ID] = lock
+	}
+
+	run = newJobRun()
+	if lock.current == nil {
+		lock.current = run
+		close(run.start)
+		sm.running.Add(1)
+		return run, false, nil
+	}
+
+	switch job.OverlapPolicy {
+	case database.OverlapPolicyQueue:
+		if lock.next != nil {
+			return nil, false, ErrJobQueued
+		}
+		lock.next = run
+		sm.running.Add(1)
+		log.Printf("Scheduler: Job '%s' (ID: %d) is running, new run is queued", job.Name, job.ID)
+		return run, true, nil
+	case database.OverlapPolicyCancel:
+		if lock.next != nil {
+			// Waiting run is replaced too, it never becomes current
+			lock.next.cancel(errReplacedByNewerRun)
+			close(lock.next.start)
+		}
+		lock.next = run
+		lock.current.cancel(errReplacedByNewerRun)
+		sm.running.Add(1)
+		log.Printf("Scheduler: Job '%s' (ID: %d) is running, cancelling it for new run", job.Name, job.ID)
+		return run, true, nil
+	}
+	return nil, false, ErrJobRunning
+}
+
+// enqueue admits the run and saves it as queued, so the run is not lost when the server stops before it starts
+func (sm *SchedulerManager) enqueue(job *database.BackupJob, trigger string, attempt runAttempt) (run *jobRun, queued bool, err error) {
+	run, queued, err = sm.admit(job, trigger, attempt)
+	if err != nil {
+		return nil, false, err
+	}
+	run.runID = attempt.runID
+	if run.runID == 0 {
+		dbRun, err := sm.RunRepo.QueueRun(job.ID, trigger, attempt.action, attempt.parentRunID, attempt.number, time.Now())
+		if err != nil {
+			log.Printf("Scheduler: Failed to record queued run for job ID %d: %v", job.ID, err)
+		} else {
+			run.runID = dbRun.ID
+		}
+	}
+	return run, queued, nil
+}
+
+// release finishes the current run of the job and starts the queued one, on shutdown the queued one is dropped
+func (sm *SchedulerManager) release(jobID int, run *jobRun) {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+
+	close(run.done)
+	run.cancel(nil)
+	sm.running.Done()
+
+	lock := sm.locks[jobID]
+	if lock == nil || lock.current != run {
+		return
+	}
+	lock.current = lock.next
+	lock.next = nil
+	if lock.current == nil {
+		delete(sm.locks, jobID)
+		return
+	}
+	if sm.draining {
+		// Waiting run is not started on shutdown, it stays queued for the next start
+		lock.current.cancel(errShutdown)
+	}
+	close(lock.current.start)
+}
+
+// CancelJob stops the working run of the job and drops the run which waits for it.
+// It doesn't wait, the stopped run is recorded with Cancelled status when the copy stops.
+func (sm *SchedulerManager) CancelJob(jobID int) error {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+
+	lock := sm.locks[jobID]
+	if lock == nil {
+		return ErrJobNotRunning
+	}
+	if lock.next != nil {
+		lock.next.cancel(errCancelledByUser)
+		close(lock.next.start)
+		lock.next = nil
+	}
+	lock.current.cancel(errCancelledByUser)
+	log.Printf("Scheduler: Run of job ID %d is cancelled by user", jobID)
+	return nil
+}
+
+// IsRunning tells if the job has a run in progress
+func (sm *SchedulerManager) IsRunning(jobID int) bool {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	return sm.locks[jobID] != nil
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"context"
+	"errors"
+	"testing"
+)
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestAdmitOverlapPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		// Results of the second and the third run while the first one works
+		secondErr    error
+		secondQueued bool
+		thirdErr     error
+		// Cause of cancelling the first run, nil when it keeps working
+		firstCause error
+		// Skipped runs recorded in history
+		skipped int
+	}{
+		{policy: database.OverlapPolicySkip, secondErr: ErrJobRunning, thirdErr: ErrJobRunning, skipped: 2},
+		{policy: database.OverlapPolicyQueue, secondQueued: true, thirdErr: ErrJobQueued, skipped: 1},
+		{policy: database.OverlapPolicyCancel, secondQueued: true, firstCause: errReplacedByNewerRun},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			sm, _ := newTestScheduler(t)
+			job := createTestJob(t, sm, "overlap")
+			job.OverlapPolicy = tt.policy
+
+			first, queued, err := sm.admit(job, "manual", firstAttempt)
+			if err != nil || queued || !isClosed(first.start) {
+				t.Fatalf("first run: queued %v, err %v", queued, err)
+			}
+
+			second, queued, err := sm.admit(job, "schedule", firstAttempt)
+			if !errors.Is(err, tt.secondErr) || queued != tt.secondQueued {
+				t.Fatalf("second run: queued %v, err %v, want queued %v, err %v", queued, err, tt.secondQueued, tt.secondErr)
+			}
+			third, _, err := sm.admit(job, "schedule", firstAttempt)
+			if !errors.Is(err, tt.thirdErr) {
+				t.Fatalf("third run: err %v, want %v", err, tt.thirdErr)
+			}
+
+			if cause := context.Cause(first.ctx); !errors.Is(cause, tt.firstCause) {
+				t.Errorf("first run cancelled with %v, want %v", cause, tt.firstCause)
+			}
+			if tt.policy == database.OverlapPolicyCancel {
+				// Third run replaced the waiting second one, which is released without start
+				if !isClosed(second.start) || !errors.Is(context.Cause(second.ctx), errReplacedByNewerRun) {
+					t.Errorf("replaced waiting run is not cancelled")
+				}
+				second = third
+			}
+
+			sm.release(job.ID, first)
+			if second != nil {
+				if !isClosed(second.start) {
+					t.Fatalf("waiting run did not start after the first one finished")
+				}
+				sm.release(job.ID, second)
+			}
+			if sm.IsRunning(job.ID) {
+				t.Errorf("job is running after all runs finished")
+			}
+
+			runs, _, err := sm.RunRepo.GetJobRuns(job.ID, database.RunFilter{Status: database.RunStatusSkipped})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(runs) != tt.skipped {
+				t.Errorf("%d skipped runs recorded, want %d", len(runs), tt.skipped)
+			}
+		})
+	}
+}
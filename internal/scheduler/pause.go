@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// loadPause reads maintenance mode saved before restart or changed by another instance
+func (sm *SchedulerManager) loadPause() {
+	pause, err := sm.SettingsRepo.GetSchedulerPause()
+	if err != nil {
+		log.Printf("Scheduler: Can't load pause state: %v", err)
+		return
+	}
+	sm.pauseMu.Lock()
+	changed := !samePause(pause, sm.pause)
+	sm.pause = pause
+	sm.pauseMu.Unlock()
+	if !changed {
+		return
+	}
+	if state := sm.PauseState(); state != nil {
+		log.Printf("Scheduler: Scheduler is paused since %s%s", state.Since.Format("2006-01-02 15:04"), pauseEndText(state))
+	} else if pause == nil {
+		log.Println("Scheduler: Scheduler resumed.")
+	}
+}
+
+func samePause(a, b *database.SchedulerPause) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Since.Equal(b.Since) && a.Until.Equal(b.Until) && a.Reason == b.Reason
+}
+
+// Pause stops scheduled runs of all jobs until the given time, zero until pauses until Resume.
+// Only runs started by admin are still allowed.
+func (sm *SchedulerManager) Pause(until time.Time, reason string) (*database.SchedulerPause, error) {
+	now := time.Now()
+	if !until.IsZero() && !until.After(now) {
+		return nil, errors.New("pause end must be in the future")
+	}
+
+	sm.pauseMu.Lock()
+	defer sm.pauseMu.Unlock()
+
+	pause := &database.SchedulerPause{Since: now, Until: until, Reason: reason}
+	if sm.pause.ActiveAt(now) {
+		pause.Since = sm.pause.Since
+	}
+	if err := sm.SettingsRepo.SetSchedulerPause(pause); err != nil {
+		return nil, err
+	}
+	sm.pause = pause
+	log.Printf("Scheduler: Scheduler paused%s, reason: %s", pauseEndText(pause), reason)
+	p := *pause
+	return &p, nil
+}
+
+// Resume ends maintenance mode, scheduled runs start again from their next fire time
+func (sm *SchedulerManager) Resume() error {
+	sm.pauseMu.Lock()
+	defer sm.pauseMu.Unlock()
+
+	if err := sm.SettingsRepo.SetSchedulerPause(nil); err != nil {
+		return err
+	}
+	if sm.pause != nil {
+		log.Println("Scheduler: Scheduler resumed.")
+	}
+	sm.pause = nil
+	return nil
+}
+
+// PauseState returns active maintenance mode, nil when the scheduler works.
+// Pause which reached its end is removed.
+func (sm *SchedulerManager) PauseState() *database.SchedulerPause {
+	sm.pauseMu.Lock()
+	defer sm.pauseMu.Unlock()
+
+	if sm.pause == nil {
+		return nil
+	}
+	if !sm.pause.ActiveAt(time.Now()) {
+		log.Printf("Scheduler: Pause ended at %s, scheduler resumed.", sm.pause.Until.Format("2006-01-02 15:04"))
+		if err := sm.SettingsRepo.SetSchedulerPause(nil); err != nil {
+			log.Printf("Scheduler: Can't remove ended pause: %v", err)
+		}
+		sm.pause = nil
+		return nil
+	}
+	p := *sm.pause
+	return &p
+}
+
+func pauseReason(pause *database.SchedulerPause) error {
+	reason := "scheduler is paused" + pauseEndText(pause)
+	if pause.Reason != "" {
+		reason += ": " + pause.Reason
+	}
+	return errors.New(reason)
+}
+
+func pauseEndText(pause *database.SchedulerPause) string {
+	if pause.Until.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(" until %s", pause.Until.Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"context"
+	"fmt"
+)
+
+// checkPreconditions checks preconditions of the job in their order and returns the first failed one
+func checkPreconditions(ctx context.Context, job *database.BackupJob) error {
+	for _, p := range job.Preconditions {
+		if err := checkPrecondition(ctx, job, p); err != nil {
+			return fmt.Errorf("precondition '%s' failed: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func checkPrecondition(ctx context.Context, job *database.BackupJob, p database.JobPrecondition) error {
+	switch p.Type {
+	case database.PreconditionMount:
+		return backup.CheckMountPoint(p.Value)
+	case database.PreconditionFile:
+		return backup.CheckMarkerFile(p.Value)
+	case database.PreconditionReachable:
+		return backup.CheckReachable(ctx, p.Value)
+	case database.PreconditionCommand:
+		return backup.CheckCommand(ctx, p.Value)
+	case database.PreconditionFreeSpace:
+		minFreeMB, path, err := p.FreeSpace()
+		if err != nil {
+			return err
+		}
+		paths := []string{path}
+		if path == "" {
+			paths = job.DestinationPaths()
+		}
+		for _, path := range paths {
+			if err := backup.CheckFreeSpace(ctx, path, minFreeMB*1024*1024); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown precondition type '%s'", p.Type)
+}
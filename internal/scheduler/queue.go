@@ -0,0 +1,163 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Manual and API runs get this priority on top of the job priority, so they go before scheduled runs
+const manualPriorityBoost = 1000
+
+// runQueue starts runs by priority while global and per-destination limits allow it.
+// Zero limit means no limit.
+type runQueue struct {
+	mu                sync.Mutex
+	maxConcurrent     int
+	maxPerDestination int
+
+	running     int
+	destRunning map[string]int
+	pending     []*queueItem
+	seq         int
+}
+
+type queueItem struct {
+	jobID        int
+	priority     int
+	seq          int
+	destinations []string
+	ready        chan struct{}
+}
+
+func newRunQueue() *runQueue {
+	return &runQueue{destRunning: map[string]int{}}
+}
+
+func (q *runQueue) setLimits(maxConcurrent, maxPerDestination int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxConcurrent = maxConcurrent
+	q.maxPerDestination = maxPerDestination
+	q.dispatch()
+}
+
+// acquire waits until the run may start. Returned function must be called when the run is finished.
+func (q *runQueue) acquire(ctx context.Context, jobID, priority int, destinations []string) (func(), error) {
+	q.mu.Lock()
+	q.seq++
+	item := &queueItem{
+		jobID:        jobID,
+		priority:     priority,
+		seq:          q.seq,
+		destinations: uniqueStrings(destinations),
+		ready:        make(chan struct{}),
+	}
+	q.pending = append(q.pending, item)
+	q.dispatch()
+	waiting := q.isPending(item)
+	q.mu.Unlock()
+
+	if waiting {
+		log.Printf("Scheduler: Run of job ID %d waits in queue (priority %d)", jobID, priority)
+	}
+
+	select {
+	case <-item.ready:
+	case <-ctx.Done():
+		q.mu.Lock()
+		if q.isPending(item) {
+			q.remove(item)
+			q.mu.Unlock()
+			return nil, context.Cause(ctx)
+		}
+		// Slot was given at the same moment, the caller releases it
+		q.mu.Unlock()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.running--
+			for _, d := range item.destinations {
+				q.destRunning[d]--
+				if q.destRunning[d] <= 0 {
+					delete(q.destRunning, d)
+				}
+			}
+			q.dispatch()
+		})
+	}, nil
+}
+
+// dispatch starts pending runs in priority order. Run which can't start because its destination is busy
+// doesn't block runs to other destinations. Must be called with mu locked.
+func (q *runQueue) dispatch() {
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		if q.pending[i].priority != q.pending[j].priority {
+			return q.pending[i].priority > q.pending[j].priority
+		}
+		return q.pending[i].seq < q.pending[j].seq
+	})
+
+	var left []*queueItem
+	for _, item := range q.pending {
+		if !q.canStart(item) {
+			left = append(left, item)
+			continue
+		}
+		q.running++
+		for _, d := range item.destinations {
+			q.destRunning[d]++
+		}
+		close(item.ready)
+	}
+	q.pending = left
+}
+
+func (q *runQueue) canStart(item *queueItem) bool {
+	if q.maxConcurrent > 0 && q.running >= q.maxConcurrent {
+		return false
+	}
+	if q.maxPerDestination > 0 {
+		for _, d := range item.destinations {
+			if q.destRunning[d] >= q.maxPerDestination {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (q *runQueue) isPending(item *queueItem) bool {
+	for _, p := range q.pending {
+		if p == item {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *runQueue) remove(item *queueItem) {
+	for i, p := range q.pending {
+		if p == item {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type queuedRun struct {
+	jobID   int
+	release func()
+}
+
+// enqueue starts acquire in background and waits until the run is pending, so runs keep the order they are queued in
+func enqueue(t *testing.T, q *runQueue, started chan<- queuedRun, jobID, priority int, destinations ...string) {
+	t.Helper()
+	q.mu.Lock()
+	pending := len(q.pending)
+	q.mu.Unlock()
+
+	go func() {
+		release, err := q.acquire(context.Background(), jobID, priority, destinations)
+		if err != nil {
+			t.Errorf("acquire for job %d: %v", jobID, err)
+			return
+		}
+		started <- queuedRun{jobID: jobID, release: release}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		q.mu.Lock()
+		n := len(q.pending)
+		q.mu.Unlock()
+		if n > pending {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run of job %d is not queued", jobID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func nextStarted(t *testing.T, started <-chan queuedRun) queuedRun {
+	t.Helper()
+	select {
+	case run := <-started:
+		return run
+	case <-time.After(time.Second):
+		t.Fatal("no run started")
+		return queuedRun{}
+	}
+}
+
+func TestRunQueuePriority(t *testing.T) {
+	q := newRunQueue()
+	q.setLimits(1, 0)
+
+	first, err := q.acquire(context.Background(), 1, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan queuedRun)
+	enqueue(t, q, started, 2, 1)
+	enqueue(t, q, started, 3, 5)
+	enqueue(t, q, started, 4, 5)
+	enqueue(t, q, started, 5, 5+manualPriorityBoost)
+
+	release := first
+	for _, want := range []int{5, 3, 4, 2} {
+		release()
+		run := nextStarted(t, started)
+		if run.jobID != want {
+			t.Fatalf("started job %d, want %d", run.jobID, want)
+		}
+		release = run.release
+	}
+	release()
+	// Second release of the same run does not free another slot
+	release()
+	if q.running != 0 {
+		t.Errorf("running = %d after all runs finished", q.running)
+	}
+}
+
+func TestRunQueueDestinationLimit(t *testing.T) {
+	q := newRunQueue()
+	q.setLimits(0, 1)
+
+	first, err := q.acquire(context.Background(), 1, 0, []string{"/mnt/a", "/mnt/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan queuedRun)
+	enqueue(t, q, started, 2, 10, "/mnt/a", "/mnt/b")
+
+	// Run to other destination is not blocked by the waiting run with higher priority
+	other, err := q.acquire(context.Background(), 3, 0, []string{"/mnt/c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	first()
+	run := nextStarted(t, started)
+	if run.jobID != 2 {
+		t.Fatalf("started job %d, want 2", run.jobID)
+	}
+	run.release()
+}
+
+func TestRunQueueCancelWaiting(t *testing.T) {
+	q := newRunQueue()
+	q.setLimits(1, 0)
+
+	first, err := q.acquire(context.Background(), 1, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first()
+
+	errStop := errors.New("stopped")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel(errStop)
+	}()
+	if _, err := q.acquire(ctx, 2, 0, nil); !errors.Is(err, errStop) {
+		t.Fatalf("acquire error = %v, want cancel cause", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) != 0 {
+		t.Errorf("cancelled run is left in queue")
+	}
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"fmt"
+	"log"
+	"time"
+)
+
+// recoverRuns finishes runs which this instance left when it stopped.
+// Runs which were working are recorded as Interrupted, queued runs are started again.
+func (sm *SchedulerManager) recoverRuns() {
+	runs, err := sm.RunRepo.GetUnfinishedRuns()
+	if err != nil {
+		log.Printf("Scheduler: Failed to load unfinished runs: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, r := range runs {
+		job, err := sm.JobRepo.GetJobByID(r.JobID)
+		if err != nil {
+			log.Printf("Scheduler: Unfinished run ID %d is dropped, can't load job: %v", r.ID, err)
+			sm.interruptRun(nil, r, now)
+			continue
+		}
+
+		if r.Status == database.RunStatusRunning {
+			log.Printf("Scheduler: Run ID %d of job '%s' (ID: %d) was interrupted by server stop", r.ID, job.Name, job.ID)
+			sm.interruptRun(job, r, now)
+			continue
+		}
+
+		attempt := runAttempt{parentRunID: int(r.ParentRunID.Int64), number: r.Attempt, action: r.Action, runID: r.ID}
+		if r.Trigger == database.RunTriggerResume {
+			// Paused run waits for its backup window
+			if windows, err := sm.jobWindows(job); err == nil && !windows.Allows(now) {
+				if opens := windows.NextOpen(now); !opens.IsZero() {
+					log.Printf("Scheduler: Paused run ID %d of job '%s' (ID: %d) resumes at %s", r.ID, job.Name, job.ID, opens.Format("2006-01-02 15:04"))
+					sm.resumeAt(job.ID, opens, attempt)
+					continue
+				}
+			}
+		}
+
+		log.Printf("Scheduler: Queued %s run ID %d of job '%s' (ID: %d) is queued again", r.Trigger, r.ID, job.Name, job.ID)
+		if _, err := sm.startRun(job, r.Trigger, attempt, nil); err != nil {
+			log.Printf("Scheduler: Queued run ID %d of job ID %d not started: %v", r.ID, job.ID, err)
+		}
+	}
+}
+
+// interruptRun closes the run with Interrupted status, backup runs change status of the job too
+func (sm *SchedulerManager) interruptRun(job *database.BackupJob, r database.BackupRun, now time.Time) {
+	message := "Server stopped before the run started"
+	if r.Status == database.RunStatusRunning {
+		message = fmt.Sprintf("Server stopped while the run was working, it started at %s", r.StartTime.Format("2006-01-02 15:04:05"))
+	}
+	if err := sm.RunRepo.FinishRun(r.ID, database.RunStatusInterrupted, message, 0, 0, now); err != nil {
+		log.Printf("Scheduler: Failed to record interrupted run ID %d: %v", r.ID, err)
+	}
+	if job == nil || !database.IsBackupAction(r.Action) {
+		return
+	}
+	if err := sm.JobRepo.UpdateJobStatusAndLastRun(job.ID, database.RunStatusInterrupted, now); err != nil {
+		log.Printf("Scheduler: Failed to update job status for ID %d: %v", job.ID, err)
+	}
+}
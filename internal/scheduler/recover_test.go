@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecoverRunsOfThisInstance(t *testing.T) {
+	sm, db := newTestScheduler(t)
+	sm.RunRepo.SetInstance("a")
+	job := createTestJob(t, sm, "nightly")
+
+	// Runs saved by this instance, another instance and before instances were recorded
+	seed := func(instance, status, trigger string) int {
+		t.Helper()
+		repo := database.NewRunRepo(db)
+		repo.SetInstance(instance)
+		started := time.Now().Add(-time.Hour)
+		var run *database.BackupRun
+		var err error
+		if status == database.RunStatusQueued {
+			run, err = repo.QueueRun(job.ID, trigger, database.ActionFull, 0, 1, started)
+		} else {
+			run, err = repo.StartRun(job.ID, trigger, started)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return run.ID
+	}
+	ownRunning := seed("a", database.RunStatusRunning, database.RunTriggerCron)
+	ownQueued := seed("a", database.RunStatusQueued, database.RunTriggerManual)
+	otherRunning := seed("b", database.RunStatusRunning, database.RunTriggerCron)
+	otherQueued := seed("b", database.RunStatusQueued, database.RunTriggerManual)
+	legacyRunning := seed("", database.RunStatusRunning, database.RunTriggerCron)
+
+	sm.recoverRuns()
+	// Wait for the queued run started again
+	if err := sm.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, total, err := sm.RunRepo.GetJobRuns(job.ID, database.RunFilter{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 {
+		t.Errorf("%d runs after recovery, want the seeded ones only", total)
+	}
+	status := map[int]string{}
+	for _, r := range runs {
+		status[r.ID] = r.Status
+	}
+	want := map[int]string{
+		ownRunning:    database.RunStatusInterrupted,
+		ownQueued:     "Success",
+		otherRunning:  database.RunStatusRunning,
+		otherQueued:   database.RunStatusQueued,
+		legacyRunning: database.RunStatusInterrupted,
+	}
+	for id, w := range want {
+		if status[id] != w {
+			t.Errorf("run ID %d status = %q, want %q", id, status[id], w)
+		}
+	}
+
+	saved, err := sm.JobRepo.GetJobByID(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Queued run finished after the interrupted ones were recorded
+	if saved.LastRunStatus.String != "Success" {
+		t.Errorf("job status = %q, want status of the last run", saved.LastRunStatus.String)
+	}
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"log"
+	"time"
+)
+
+const maxRetryDelay = 24 * time.Hour
+
+// runAttempt links retry to the original run of the job
+type runAttempt struct {
+	parentRunID int    // 0 for the original run
+	number      int    // 1 for the original run
+	action      string // what the run does, empty is full backup
+	runID       int    // queued run saved before the restart, 0 when the run is saved on admission
+	ignorePause bool   // started by authenticated admin, runs during maintenance
+}
+
+var firstAttempt = runAttempt{number: 1}
+
+// scheduleRetry starts the job again after delay from its retry policy,
+// when the run failed with retryable error class and retries are not exhausted.
+// Returns true when retry is scheduled.
+func (sm *SchedulerManager) scheduleRetry(job *database.BackupJob, attempt runAttempt, runID int, result backup.BackupResult) bool {
+	retry := attempt.number
+	if retry > job.RetryMaxAttempts {
+		return false
+	}
+	class := backup.ClassifyResult(result)
+	if class == "" || !job.RetriesOn(class) {
+		return false
+	}
+
+	next := runAttempt{parentRunID: attempt.parentRunID, number: attempt.number + 1, action: attempt.action, ignorePause: attempt.ignorePause}
+	if next.parentRunID == 0 {
+		next.parentRunID = runID
+	}
+	delay := job.RetryDelay(retry)
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	log.Printf("Scheduler: Job '%s' (ID: %d) failed with %s error, retry %d of %d in %s",
+		job.Name, job.ID, class, retry, job.RetryMaxAttempts, delay)
+
+	sm.afterFunc(delay, func() {
+		// Job is read again, it can be changed or deleted while waiting
+		job, err := sm.JobRepo.GetJobByID(job.ID)
+		if err != nil {
+			log.Printf("Scheduler: Retry cancelled, can't load job: %v", err)
+			return
+		}
+		log.Printf("Scheduler: Retrying job '%s' (ID: %d), attempt %d", job.Name, job.ID, next.number)
+		sm.runAttempt(job, database.RunTriggerRetry, next)
+	})
+	return true
+}
+
+// afterFunc runs f after delay unless the scheduler is stopped before
+func (sm *SchedulerManager) afterFunc(delay time.Duration, f func()) {
+	sm.timersMu.Lock()
+	defer sm.timersMu.Unlock()
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		sm.timersMu.Lock()
+		delete(sm.timers, timer)
+		sm.timersMu.Unlock()
+		f()
+	})
+	sm.timers[timer] = true
+}
+
+// stopTimers drops all waiting retries and resumes
+func (sm *SchedulerManager) stopTimers() {
+	sm.timersMu.Lock()
+	defer sm.timersMu.Unlock()
+	for timer := range sm.timers {
+		timer.Stop()
+	}
+	sm.timers = map[*time.Timer]bool{}
+}
@@ -0,0 +1,330 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// RunJob performs backup (or replication) of the job and saves results of the run.
+// trigger tells what started the run (cron, manual or API) and is kept in the run history.
+// When the job is already running, overlap policy of the job decides if this call waits, cancels the old run or is skipped.
+func (sm *SchedulerManager) RunJob(job *database.BackupJob, trigger string) backup.BackupResult {
+	return sm.runAttempt(job, trigger, firstAttempt)
+}
+
+func (sm *SchedulerManager) runAttempt(job *database.BackupJob, trigger string, attempt runAttempt) backup.BackupResult {
+	run, _, err := sm.enqueue(job, trigger, attempt)
+	if err != nil {
+		return skippedResult(job.ID, err)
+	}
+	return sm.execute(job, trigger, run, attempt)
+}
+
+// StartJob is RunJob in background. The error is returned when the run is rejected,
+// queued is true when the run waits for the previous run of the job.
+// Runs started by admin are not stopped by maintenance mode.
+func (sm *SchedulerManager) StartJob(job *database.BackupJob, trigger string, admin bool) (queued bool, err error) {
+	attempt := firstAttempt
+	attempt.ignorePause = admin
+	return sm.startRun(job, trigger, attempt, nil)
+}
+
+// startRun starts the run in background, with changedPaths the run copies only these paths
+func (sm *SchedulerManager) startRun(job *database.BackupJob, trigger string, attempt runAttempt, changedPaths []string) (queued bool, err error) {
+	run, queued, err := sm.enqueue(job, trigger, attempt)
+	if err != nil {
+		return false, err
+	}
+	run.changedPaths = changedPaths
+	go sm.execute(job, trigger, run, attempt)
+	return queued, nil
+}
+
+func (sm *SchedulerManager) execute(job *database.BackupJob, trigger string, run *jobRun, attempt runAttempt) backup.BackupResult {
+	defer sm.release(job.ID, run)
+	if attempt.action == "" {
+		attempt.action = database.ActionFull
+	}
+
+	<-run.start
+	if run.ctx.Err() != nil {
+		// Run was replaced by newer one while it was waiting
+		return sm.skip(job, trigger, run, attempt, context.Cause(run.ctx))
+	}
+	if pause := sm.PauseState(); pause != nil && !attempt.ignorePause {
+		reason := pauseReason(pause)
+		log.Printf("Scheduler: Run of job '%s' (ID: %d) skipped: %v", job.Name, job.ID, reason)
+		return sm.skip(job, trigger, run, attempt, reason)
+	}
+
+	priority := job.Priority
+	if trigger == database.RunTriggerManual || trigger == database.RunTriggerAPI {
+		priority += manualPriorityBoost
+	}
+	var targets []string
+	for _, d := range job.DestinationPaths() {
+		targets = append(targets, backup.DestinationKey(d))
+	}
+	releaseSlot, err := sm.queue.acquire(run.ctx, job.ID, priority, targets)
+	if err != nil {
+		return sm.skip(job, trigger, run, attempt, err)
+	}
+	defer releaseSlot()
+
+	windows, err := sm.jobWindows(job)
+	if err != nil {
+		return sm.skip(job, trigger, run, attempt, err)
+	}
+	now := time.Now()
+	if !ignoresWindows(trigger) && !windows.Allows(now) {
+		reason := "outside backup window"
+		if opens := windows.NextOpen(now); !opens.IsZero() {
+			reason += ", window opens at " + opens.Format("2006-01-02 15:04")
+		}
+		log.Printf("Scheduler: Run of job '%s' (ID: %d) skipped: %s", job.Name, job.ID, reason)
+		return sm.skip(job, trigger, run, attempt, errors.New(reason))
+	}
+	if err := checkPreconditions(run.ctx, job); err != nil {
+		if run.ctx.Err() != nil {
+			err = context.Cause(run.ctx)
+		}
+		log.Printf("Scheduler: Run of job '%s' (ID: %d) skipped: %v", job.Name, job.ID, err)
+		return sm.skip(job, trigger, run, attempt, err)
+	}
+	ctx, cancel := runContext(run.ctx, job, windows, trigger, now)
+	defer cancel()
+
+	runID := run.runID
+	if runID != 0 {
+		err = sm.RunRepo.MarkRunStarted(runID, now)
+	} else {
+		var dbRun *database.BackupRun
+		if dbRun, err = sm.RunRepo.StartAttempt(job.ID, trigger, attempt.action, attempt.parentRunID, attempt.number, now); err == nil {
+			runID = dbRun.ID
+		}
+	}
+	if err != nil {
+		log.Printf("Scheduler: Failed to record run start for job ID %d: %v", job.ID, err)
+	}
+
+	var result backup.BackupResult
+	switch {
+	case job.IsReplication():
+		result = sm.runReplication(ctx, job)
+	case attempt.action == database.ActionVerify:
+		result = backup.VerifyBackup(ctx, job.ID, job.SourcePath, job.DestinationPaths())
+	case attempt.action == database.ActionPrune:
+		result = backup.PruneBackup(ctx, job.ID, job.SourcePath, job.DestinationPaths(), BackupOptionsForJob(job))
+	default:
+		opts := BackupOptionsForJob(job)
+		opts.ChangedPaths = run.changedPaths
+		opts.Incremental = attempt.action == database.ActionIncremental
+		result = backup.PerformBackup(ctx, job.ID, job.SourcePath, job.DestinationPaths(), opts)
+	}
+	applyStopCause(ctx, job, &result)
+
+	if runID != 0 {
+		err := sm.RunRepo.FinishRun(runID, result.Status, result.Message, result.Files, result.Bytes, time.Now())
+		if err != nil {
+			log.Printf("Scheduler: Failed to record run result for job ID %d: %v", job.ID, err)
+		}
+	}
+	var pending bool
+	switch {
+	case result.Status == database.RunStatusInterrupted:
+		sm.checkpoint(job, attempt, runID, result)
+		pending = true
+	case result.Status == backup.StatusPaused:
+		pending = sm.scheduleResume(job, windows, attempt, runID)
+	default:
+		pending = sm.scheduleRetry(job, attempt, runID, result)
+	}
+
+	// Verify and prune runs don't change the backup, so its status and dependent jobs stay as they are
+	if !database.IsBackupAction(attempt.action) {
+		log.Printf("Scheduler: Job ID %d %s run finished with '%s' (Duration: %s)", result.JobID, attempt.action, result.Status, result.Duration.String())
+		return result
+	}
+
+	for _, d := range result.Destinations {
+		if err := sm.JobRepo.UpdateDestinationResult(result.JobID, d.Destination, d.Status, d.Message, d.Files, d.Bytes, result.Time); err != nil {
+			log.Printf("Scheduler: Failed to update destination result for job ID %d: %v", result.JobID, err)
+		}
+	}
+
+	err = sm.JobRepo.UpdateJobStatusAndLastRun(result.JobID, result.Status, result.Time)
+	if err != nil {
+		log.Printf("Scheduler: Failed to update job status for ID %d: %v", result.JobID, err)
+	} else {
+		log.Printf("Scheduler: Job ID %d status updated to '%s' (Duration: %s)", result.JobID, result.Status, result.Duration.String())
+	}
+
+	// Dependent jobs wait for the final result of the run
+	if !pending {
+		sm.triggerDependents(job, result)
+	}
+
+	return result
+}
+
+// runReplication copies destination of the source job which holds the selected run: the run set in the job or the latest completed one.
+// Every copied file is verified by checksum and each successful destination is recorded as a replica.
+func (sm *SchedulerManager) runReplication(ctx context.Context, job *database.BackupJob) backup.BackupResult {
+	if !job.ReplicaOfJobID.Valid {
+		return errorResult(job.ID, "Replication job has no source job")
+	}
+
+	sourceJob, err := sm.JobRepo.GetJobByID(int(job.ReplicaOfJobID.Int64))
+	if err != nil {
+		return errorResult(job.ID, fmt.Sprintf("Can't load source job: %v", err))
+	}
+
+	var run *database.BackupRun
+	if job.ReplicaRunID > 0 {
+		if run, err = sm.RunRepo.GetRunByID(job.ReplicaRunID); err != nil {
+			return errorResult(job.ID, fmt.Sprintf("Can't load run to replicate: %v", err))
+		}
+		if run.JobID != sourceJob.ID || !run.IsCompletedBackup() {
+			return errorResult(job.ID, fmt.Sprintf("Run ID %d is not completed backup run of job '%s'", run.ID, sourceJob.Name))
+		}
+	}
+
+	source, err := replicationSource(sourceJob, job.SourcePath, run)
+	if err != nil {
+		return errorResult(job.ID, err.Error())
+	}
+	sourcePath := source.DestinationPath
+
+	log.Printf("Scheduler: Replicating backup of job '%s' (ID: %d) made at %s from '%s'",
+		sourceJob.Name, sourceJob.ID, source.LastRunTime.Time.Format("2006-01-02 15:04"), source.DisplayPath())
+
+	opts := BackupOptionsForJob(job)
+	opts.Verify = true
+	opts.SourceType = backup.SourceTypeFiles
+	result := backup.PerformBackup(ctx, job.ID, sourcePath, job.DestinationPaths(), opts)
+
+	for _, d := range result.Destinations {
+		if d.Status != "Success" {
+			continue
+		}
+		replica := &database.BackupReplica{
+			SourceJobID:      sourceJob.ID,
+			ReplicationJobID: job.ID,
+			SourcePath:       sourcePath,
+			DestinationPath:  d.Destination,
+			SourceRunTime:    source.LastRunTime,
+			ReplicatedAt:     result.Time,
+			FilesCount:       d.Files,
+			BytesCount:       d.Bytes,
+		}
+		if err := sm.ReplicaRepo.CreateReplica(replica); err != nil {
+			log.Printf("Scheduler: Failed to record replica of job ID %d in '%s': %v", sourceJob.ID, d.Destination, err)
+		}
+	}
+
+	return result
+}
+
+// replicationSource returns local destination of the source job which holds the run, with nil run it is the latest completed run.
+// Destination holds the run when its last result is successful and was written by the run,
+// destination which failed since then is not replicated. Non empty path limits the choice to this destination.
+func replicationSource(sourceJob *database.BackupJob, path string, run *database.BackupRun) (*database.JobDestination, error) {
+	var reasons []string
+	for _, d := range sourceJob.Destinations {
+		if path != "" && d.DestinationPath != path {
+			continue
+		}
+		dest, err := backup.NewDestination(d.DestinationPath)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("'%s' is wrong: %v", d.DisplayPath(), err))
+			continue
+		}
+		if _, ok := dest.(*backup.LocalDestination); !ok {
+			reasons = append(reasons, fmt.Sprintf("'%s' is not local, only local backups can be replicated", dest))
+			continue
+		}
+		if d.LastRunStatus.String != "Success" || !d.LastRunTime.Valid {
+			reasons = append(reasons, fmt.Sprintf("'%s' has no completed backup, last result: '%s'", d.DisplayPath(), d.LastRunStatus.String))
+			continue
+		}
+		written := d.LastRunTime.Time
+		if run != nil && (written.Before(run.StartTime) || written.After(run.EndTime.Time)) {
+			reasons = append(reasons, fmt.Sprintf("'%s' holds backup made at %s, not run ID %d", d.DisplayPath(), written.Format("2006-01-02 15:04"), run.ID))
+			continue
+		}
+		return &d, nil
+	}
+	if len(reasons) == 0 {
+		return nil, fmt.Errorf("'%s' is not destination of job '%s'", database.RedactDestination(path), sourceJob.Name)
+	}
+	return nil, fmt.Errorf("job '%s' has no backup to replicate: %s", sourceJob.Name, strings.Join(reasons, "; "))
+}
+
+func errorResult(jobID int, message string) backup.BackupResult {
+	log.Printf("Backup error for job ID %d: %s", jobID, message)
+	return backup.BackupResult{
+		JobID:   jobID,
+		Status:  "Error",
+		Message: message,
+		Time:    time.Now(),
+	}
+}
+
+// skip records run which was not started
+func (sm *SchedulerManager) skip(job *database.BackupJob, trigger string, run *jobRun, attempt runAttempt, reason error) backup.BackupResult {
+	sm.recordSkip(job, trigger, run.runID, attempt, reason)
+	return skippedResult(job.ID, reason)
+}
+
+// recordSkip closes queued run as skipped, or saves new skipped run when the run has no row yet.
+// Run cancelled by user while it waited is recorded as cancelled.
+// Queued run stopped by shutdown stays queued and starts after restart.
+func (sm *SchedulerManager) recordSkip(job *database.BackupJob, trigger string, runID int, attempt runAttempt, reason error) {
+	if runID != 0 && (errors.Is(reason, errShutdown) || errors.Is(reason, ErrShuttingDown)) {
+		log.Printf("Scheduler: Queued run ID %d of job ID %d starts after restart", runID, job.ID)
+		return
+	}
+	result := skippedResult(job.ID, reason)
+	var err error
+	if runID != 0 {
+		err = sm.RunRepo.FinishRun(runID, result.Status, result.Message, 0, 0, time.Now())
+	} else {
+		err = sm.RunRepo.RecordNotStartedRun(job.ID, trigger, attempt.action, attempt.parentRunID, attempt.number, result.Status, result.Message, time.Now())
+	}
+	if err != nil {
+		log.Printf("Scheduler: Failed to record skipped run for job ID %d: %v", job.ID, err)
+	}
+}
+
+func skippedResult(jobID int, reason error) backup.BackupResult {
+	if errors.Is(reason, errCancelledByUser) {
+		return backup.BackupResult{
+			JobID:   jobID,
+			Status:  backup.StatusCancelled,
+			Message: "Run cancelled by user before it started",
+			Time:    time.Now(),
+		}
+	}
+	return backup.BackupResult{
+		JobID:   jobID,
+		Status:  database.RunStatusSkipped,
+		Message: fmt.Sprintf("Run skipped: %v", reason),
+		Time:    time.Now(),
+	}
+}
+
+func BackupOptionsForJob(job *database.BackupJob) backup.BackupOptions {
+	return backup.BackupOptions{
+		RequireAllDestinations: job.DestinationPolicy != database.DestinationPolicyAny,
+		SpacePolicy:            job.SpacePolicy,
+		MinFreeBytes:           job.MinFreeMB * 1024 * 1024,
+		Retention:              backup.RetentionPolicy{KeepRuns: job.RetentionRuns, KeepDays: job.RetentionDays},
+		SourceType:             job.SourceType,
+	}
+}
@@ -1,170 +1,410 @@
 package scheduler
 
 import (
-	"backup-app/internal/backup"
 	"backup-app/internal/database"
+	"backup-app/internal/schedule"
+	"backup-app/internal/window"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
+// ParseSchedule parses schedule of the job in its time zone, empty zone is the server local time
+func ParseSchedule(spec, timeZone string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		return nil, fmt.Errorf("time zone is set in the job settings, not in the schedule '%s'", spec)
+	}
+	loc, err := database.LoadLocation(timeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := schedule.Parser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron spec '%s': %w", spec, err)
+	}
+	if spec, ok := parsed.(*cron.SpecSchedule); ok {
+		spec.Location = loc
+		return dstSchedule{spec: spec}, nil
+	}
+	return parsed, nil
+}
+
+// ValidateJobSchedule checks schedule and time zone of the job before it is saved, cron spec of the job is made from its schedule settings
+func ValidateJobSchedule(job *database.BackupJob) error {
+	if _, err := database.LoadLocation(job.TimeZone); err != nil {
+		return err
+	}
+	if job.ScheduleConfig.Kind != "" {
+		spec, err := job.ScheduleConfig.Spec()
+		if err != nil {
+			return err
+		}
+		job.Schedule = spec
+	}
+	if !database.ValidAction(job.ScheduleAction) {
+		return fmt.Errorf("unknown schedule action '%s'", job.ScheduleAction)
+	}
+	if !IsManualSchedule(job.Schedule) {
+		if _, err := ParseSchedule(job.Schedule, job.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	for i := range job.Schedules {
+		s := &job.Schedules[i]
+		if !database.ValidAction(s.Action) {
+			return fmt.Errorf("unknown schedule action '%s'", s.Action)
+		}
+		if s.ScheduleConfig.Kind != "" {
+			spec, err := s.ScheduleConfig.Spec()
+			if err != nil {
+				return err
+			}
+			s.Schedule = spec
+		}
+		if IsManualSchedule(s.Schedule) {
+			return fmt.Errorf("additional schedule for %s runs can't be manual", s.Action)
+		}
+		if _, err := ParseSchedule(s.Schedule, job.TimeZone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type SchedulerManager struct {
-	Cron    *cron.Cron
-	JobRepo *database.JobRepo
+	Cron         *cron.Cron
+	JobRepo      *database.JobRepo
+	ReplicaRepo  *database.ReplicaRepo
+	RunRepo      *database.RunRepo
+	SettingsRepo *database.SettingsRepo
+	LeaseRepo    *database.LeaseRepo
+
+	locksMu sync.Mutex
+	locks   map[int]*jobLock
+	queue   *runQueue
+	// Admitted runs which did not finish yet, new runs are rejected when draining is set
+	running  sync.WaitGroup
+	draining bool
+
+	// Waiting retries and resumes
+	timersMu sync.Mutex
+	timers   map[*time.Timer]bool
+
+	// Backup window for all jobs, job windows are applied together with it
+	globalWindow window.Window
+
+	// Cron entries and their specs for every scheduled job, one entry per schedule of the job, guarded by reloadMu
+	reloadMu sync.Mutex
+	entries  map[int][]cron.EntryID
+	specs    map[int]string
+	// Source watchers of jobs started by changes, guarded by reloadMu
+	watchers   map[int]*sourceWatcher
+	watchSpecs map[int]string
+
+	// Missed runs are checked only on the first load after startup
+	caughtUp bool
+
+	// Maintenance mode, nil when scheduled runs are allowed
+	pauseMu sync.Mutex
+	pause   *database.SchedulerPause
+
+	// Leader election between instances sharing the database, leader holds the lease until leaderUntil
+	instanceID  string
+	leaseTTL    time.Duration
+	leaderMu    sync.Mutex
+	leaderUntil time.Time
+	stopLeader  chan struct{}
 }
 
-func NewSchedulerManager(jobRepo *database.JobRepo) *SchedulerManager {
+func NewSchedulerManager(jobRepo *database.JobRepo, replicaRepo *database.ReplicaRepo, runRepo *database.RunRepo, settingsRepo *database.SettingsRepo) *SchedulerManager {
 	c := cron.New(cron.WithChain(
 		cron.Recover(cron.DefaultLogger),
 	))
 	return &SchedulerManager{
-		Cron:    c,
-		JobRepo: jobRepo,
+		Cron:         c,
+		JobRepo:      jobRepo,
+		ReplicaRepo:  replicaRepo,
+		RunRepo:      runRepo,
+		SettingsRepo: settingsRepo,
+		locks:        map[int]*jobLock{},
+		queue:        newRunQueue(),
+		timers:       map[*time.Timer]bool{},
+		entries:      map[int][]cron.EntryID{},
+		specs:        map[int]string{},
+		watchers:     map[int]*sourceWatcher{},
+		watchSpecs:   map[int]string{},
 	}
 }
 
+// SetRunLimits limits how many runs work at the same time in total and on one destination, 0 is no limit
+func (sm *SchedulerManager) SetRunLimits(maxConcurrent, maxPerDestination int) {
+	sm.queue.setLimits(maxConcurrent, maxPerDestination)
+	log.Printf("Scheduler: Run limits set, max concurrent runs: %d, max runs per destination: %d", maxConcurrent, maxPerDestination)
+}
+
+// SetGlobalWindow sets backup window which applies to every job
+func (sm *SchedulerManager) SetGlobalWindow(w window.Window) {
+	sm.globalWindow = w
+}
+
 func (sm *SchedulerManager) Start() {
+	sm.loadPause()
+	sm.recoverRuns()
+	if sm.LeaseRepo != nil {
+		sm.renewLease()
+		sm.stopLeader = make(chan struct{})
+		go sm.leaderLoop(sm.stopLeader)
+	}
 	sm.Cron.Start()
 	log.Println("Scheduler started.")
 }
 
-func (sm *SchedulerManager) Stop() {
-	sm.Cron.Stop()
-	log.Println("Scheduler stopped.")
-}
-
-/*
+// LoadAndScheduleJobs syncs cron entries with all jobs in DB.
+// Entries of unchanged jobs are kept, so their next run is not lost or doubled.
 func (sm *SchedulerManager) LoadAndScheduleJobs() {
+	sm.reloadMu.Lock()
+	defer sm.reloadMu.Unlock()
+
 	log.Println("Loading and scheduling jobs...")
+	startTime := time.Now()
 	jobs, err := sm.JobRepo.GetAllJobs()
 	if err != nil {
 		log.Printf("Scheduler: Error loading jobs from DB: %v", err)
 		return
 	}
 
-	for _, entry := range sm.Cron.Entries() {
-		sm.Cron.Remove(entry.ID)
-	}
-	log.Println("Existing cron entries cleared.")
-
+	existing := map[int]bool{}
 	for _, job := range jobs {
-		if job.IsActive {
-			spec := ""
-			switch job.Schedule {
-			case "daily":
-				spec = "0 0 * * *" // Опівночі щодня (00:00)
-			case "weekly":
-				spec = "0 0 * * 0" // Опівночі щонеділі (00:00)
-			case "monthly":
-				spec = "0 0 1 * *" // Опівночі першого числа кожного місяця (00:00)
-			case "manual":
-				// Ручні завдання не плануємо в cron
-				log.Printf("Job '%s' (ID: %d) is manual, skipping scheduling.", job.Name, job.ID)
-				continue
-			default:
-				log.Printf("Scheduler: Unknown schedule '%s' for job ID %d. Skipping.", job.Schedule, job.ID)
-				continue
-			}
+		existing[job.ID] = true
+		schedules := sm.scheduleJob(&job)
+		sm.syncWatcher(&job)
 
-			// Плануємо завдання
-			jobID := job.ID
-			jobName := job.Name
-			sourcePath := job.SourcePath
-			destinationPath := job.DestinationPath
-
-			_, err := sm.Cron.AddFunc(spec, func() {
-				log.Printf("Scheduler: Initiating scheduled backup for job '%s' (ID: %d)", jobName, jobID)
-				result := backup.PerformLocalBackup(jobID, sourcePath, destinationPath)
-
-				err := sm.JobRepo.UpdateJobStatusAndLastRun(result.JobID, result.Status, result.Time)
-				if err != nil {
-					log.Printf("Scheduler: Failed to update job status for ID %d: %v", result.JobID, err)
-				} else {
-					log.Printf("Scheduler: Job ID %d status updated to '%s' (Duration: %s)", result.JobID, result.Status, result.Duration.String())
-				}
-			})
-			if err != nil {
-				log.Printf("Scheduler: Error adding cron job for '%s' (ID: %d): %v", job.Name, job.ID, err)
-			} else {
-				log.Printf("Scheduler: Job '%s' (ID: %d) scheduled with spec: '%s'", job.Name, job.ID, spec)
-			}
-		} else {
-			log.Printf("Scheduler: Job '%s' (ID: %d) is inactive, skipping scheduling.", job.Name, job.ID)
+		if !sm.caughtUp && len(schedules) > 0 && sm.IsLeader() {
+			sm.catchUp(&job, schedules, startTime)
 		}
 	}
+
+	// Jobs deleted from DB
+	for jobID := range sm.entries {
+		if !existing[jobID] {
+			sm.unscheduleJob(jobID)
+		}
+	}
+	for jobID := range sm.watchers {
+		if !existing[jobID] {
+			sm.unwatchJob(jobID)
+		}
+	}
+
+	sm.caughtUp = true
 	log.Println("All active jobs loaded and scheduled.")
 }
-*/
 
-func (sm *SchedulerManager) LoadAndScheduleJobs() {
-	log.Println("Loading and scheduling jobs...")
-	jobs, err := sm.JobRepo.GetAllJobs()
+// ReloadJob updates cron entry of one job after it was created, changed or deleted
+func (sm *SchedulerManager) ReloadJob(jobID int) {
+	sm.reloadMu.Lock()
+	defer sm.reloadMu.Unlock()
+
+	job, err := sm.JobRepo.GetJobByID(jobID)
+	if errors.Is(err, database.ErrJobNotFound) {
+		sm.unscheduleJob(jobID)
+		sm.unwatchJob(jobID)
+		return
+	}
 	if err != nil {
-		log.Printf("Scheduler: Error loading jobs from DB: %v", err)
+		// Database can be busy or unavailable for a moment, the job keeps its schedule and watcher until it is read
+		log.Printf("Scheduler: Failed to reload job ID %d, its schedule is not changed: %v", jobID, err)
 		return
 	}
+	sm.scheduleJob(job)
+	sm.syncWatcher(job)
+}
+
+// parsedSchedule is schedule of the job which is run by cron
+type parsedSchedule struct {
+	database.JobSchedule
+	cron cron.Schedule
+}
 
-	// Очищаємо всі існуючі записи cron, щоб уникнути дублювання при перезавантаженні
-	// Цей підхід є простим для розробки. Для продакшену можна відстежувати зміни.
-	for _, entry := range sm.Cron.Entries() {
-		sm.Cron.Remove(entry.ID)
+// claim is trigger under which runs of the schedule are claimed, additional schedules are told apart by their ID
+func (s parsedSchedule) claim(trigger string) string {
+	if s.ID == 0 {
+		return trigger
 	}
-	log.Println("Existing cron entries cleared.")
+	return fmt.Sprintf("%s#%d", trigger, s.ID)
+}
 
-	for _, job := range jobs {
-		if !job.IsActive { // Перевіряємо, чи завдання не активне
-			log.Printf("Scheduler: Job '%s' (ID: %d) is inactive, skipping scheduling.", job.Name, job.ID)
-			continue
-		}
+// scheduleJob adds, replaces or removes cron entries of the job, one for every schedule which is not manual.
+// Returns schedules which are run by cron. Must be called with reloadMu locked.
+func (sm *SchedulerManager) scheduleJob(job *database.BackupJob) []parsedSchedule {
+	if !job.IsActive { // Перевіряємо, чи завдання не активне
+		log.Printf("Scheduler: Job '%s' (ID: %d) is inactive, skipping scheduling.", job.Name, job.ID)
+		sm.unscheduleJob(job.ID)
+		return nil
+	}
 
-		if job.Schedule == "manual" || job.Schedule == "" { // "manual" або порожній розклад
-			log.Printf("Scheduler: Job '%s' (ID: %d) has manual or empty schedule, skipping cron scheduling.", job.Name, job.ID)
-			continue
-		}
+	scheduled := cronSchedules(job)
+	if len(scheduled) == 0 { // "manual" або порожній розклад
+		log.Printf("Scheduler: Job '%s' (ID: %d) has manual or empty schedule, skipping cron scheduling.", job.Name, job.ID)
+		sm.unscheduleJob(job.ID)
+		return nil
+	}
 
-		// Використовуємо значення Schedule безпосередньо як cron-специфікацію
-		spec := job.Schedule
+	spec := jobSpec(job)
 
-		// Валідація cron-специфікації перед додаванням
-		parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-		_, err := parser.Parse(spec)
+	// Валідація cron-специфікації перед додаванням
+	parsed := make([]parsedSchedule, len(scheduled))
+	for i, s := range scheduled {
+		schedule, err := ParseSchedule(s.Schedule, job.TimeZone)
 		if err != nil {
-			log.Printf("Scheduler: Invalid cron spec '%s' for job '%s' (ID: %d): %v. Skipping scheduling.", spec, job.Name, job.ID, err)
+			log.Printf("Scheduler: Invalid schedule '%s' for job '%s' (ID: %d): %v. Skipping scheduling.", s.Schedule, job.Name, job.ID, err)
+			sm.unscheduleJob(job.ID)
 			// Оновлюємо статус завдання, щоб користувач бачив помилку
 			err = sm.JobRepo.UpdateJobStatusAndLastRun(job.ID, "Error chedule", time.Now())
 			if err != nil {
 				log.Printf("Scheduler: Failed to update job status for invalid cron spec (ID %d): %v", job.ID, err)
 			}
-			continue
+			return nil
 		}
+		parsed[i] = parsedSchedule{JobSchedule: s, cron: schedule}
+	}
 
-		jobID := job.ID
-		jobName := job.Name
-		sourcePath := job.SourcePath
-		destinationPath := job.DestinationPath
-
-		_, err = sm.Cron.AddFunc(spec, func() {
-			log.Printf("Scheduler: Initiating scheduled backup for job '%s' (ID: %d)", jobName, jobID)
-			result := backup.PerformLocalBackup(jobID, sourcePath, destinationPath)
+	if _, ok := sm.entries[job.ID]; ok && sm.specs[job.ID] == spec {
+		// Schedule is not changed, the entries keep their next runs
+		return parsed
+	}
+	sm.unscheduleJob(job.ID)
 
-			err := sm.JobRepo.UpdateJobStatusAndLastRun(result.JobID, result.Status, result.Time)
+	jobID := job.ID
+	for _, s := range parsed {
+		claim := s.claim(database.RunTriggerCron)
+		action := s.Action
+		sched := s.cron
+		entryID := sm.Cron.Schedule(sched, cron.FuncJob(func() {
+			// Claim is made for the time the schedule fired at, so instances agree on it even when one of them starts late
+			now := time.Now()
+			fireTime, ok := lastMissedRun(sched, now.Add(-cronFireLookback), now)
+			if !ok {
+				fireTime = now.Truncate(time.Second)
+			}
+			if !sm.claimRun(jobID, claim, fireTime) {
+				return
+			}
+			// Job is read again, so the run uses its latest settings
+			job, err := sm.JobRepo.GetJobByID(jobID)
 			if err != nil {
-				log.Printf("Scheduler: Failed to update job status for ID %d: %v", result.JobID, err)
-			} else {
-				log.Printf("Scheduler: Job ID %d status updated to '%s' (Duration: %s)", result.JobID, result.Status, result.Duration.String())
+				log.Printf("Scheduler: Failed to load job ID %d for scheduled run: %v", jobID, err)
+				return
 			}
-		})
+			log.Printf("Scheduler: Initiating scheduled %s run for job '%s' (ID: %d)", action, job.Name, job.ID)
+			sm.runAttempt(job, database.RunTriggerCron, runAttempt{number: 1, action: action})
+		}))
+		sm.entries[job.ID] = append(sm.entries[job.ID], entryID)
+	}
+
+	sm.specs[job.ID] = spec
+	log.Printf("Scheduler: Job '%s' (ID: %d) scheduled with spec: '%s'", job.Name, job.ID, spec)
+	return parsed
+}
+
+// unscheduleJob removes cron entries of the job if it has them. Must be called with reloadMu locked.
+func (sm *SchedulerManager) unscheduleJob(jobID int) {
+	entryIDs, ok := sm.entries[jobID]
+	if !ok {
+		return
+	}
+	for _, entryID := range entryIDs {
+		sm.Cron.Remove(entryID)
+	}
+	delete(sm.entries, jobID)
+	delete(sm.specs, jobID)
+	log.Printf("Scheduler: Cron entries of job ID %d removed", jobID)
+}
+
+// cronSchedules returns schedules of the job which are run by cron, main schedule first
+func cronSchedules(job *database.BackupJob) []database.JobSchedule {
+	var scheduled []database.JobSchedule
+	for _, s := range job.AllSchedules() {
+		if !IsManualSchedule(s.Schedule) {
+			scheduled = append(scheduled, s)
+		}
+	}
+	return scheduled
+}
+
+// jobSpec identifies schedules of the cron entries, entries are replaced when any schedule, its action or time zone of the job changes
+func jobSpec(job *database.BackupJob) string {
+	var parts []string
+	for _, s := range cronSchedules(job) {
+		parts = append(parts, s.String())
+	}
+	spec := strings.Join(parts, "; ")
+	if job.TimeZone == "" {
+		return spec
+	}
+	return job.TimeZone + " " + spec
+}
+
+// IsManualSchedule tells if job is started only by hand
+func IsManualSchedule(spec string) bool {
+	return spec == schedule.ManualSpec || spec == ""
+}
+
+// NextRuns returns next count fire times of all job schedules, nil for manual jobs.
+// Inactive jobs are calculated too, so user can see when the job would run.
+func (sm *SchedulerManager) NextRuns(job *database.BackupJob, count int) ([]time.Time, error) {
+	scheduled := cronSchedules(job)
+	if len(scheduled) == 0 {
+		return nil, nil
+	}
+	loc, _ := database.LoadLocation(job.TimeZone)
+
+	// Scheduled job uses time of its cron entries, so page shows exactly what cron will do
+	sm.reloadMu.Lock()
+	entryIDs, ok := sm.entries[job.ID]
+	ok = ok && sm.specs[job.ID] == jobSpec(job)
+	sm.reloadMu.Unlock()
+
+	var runs []time.Time
+	for i, s := range scheduled {
+		schedule, err := ParseSchedule(s.Schedule, job.TimeZone)
 		if err != nil {
-			log.Printf("Scheduler: Error adding cron job for '%s' (ID: %d) with spec '%s': %v", job.Name, job.ID, spec, err)
-			// Оновлюємо статус завдання, щоб користувач бачив помилку
-			err = sm.JobRepo.UpdateJobStatusAndLastRun(job.ID, "Error planing", time.Now())
-			if err != nil {
-				log.Printf("Scheduler: Failed to update job status for scheduling error (ID %d): %v", job.ID, err)
+			return nil, err
+		}
+
+		next := time.Now()
+		n := count
+		if ok && i < len(entryIDs) {
+			if entry := sm.Cron.Entry(entryIDs[i]); !entry.Next.IsZero() && n > 0 {
+				runs = append(runs, entry.Next.In(loc))
+				next = entry.Next
+				n--
+			}
+		}
+		for j := 0; j < n; j++ {
+			next = schedule.Next(next)
+			if next.IsZero() {
+				break
 			}
-		} else {
-			log.Printf("Scheduler: Job '%s' (ID: %d) scheduled with spec: '%s'", job.Name, job.ID, spec)
+			runs = append(runs, next.In(loc))
 		}
 	}
-	log.Println("All active jobs loaded and scheduled.")
+
+	// Schedules firing at the same time start one run, overlap policy decides about the other
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Before(runs[j]) })
+	runs = slices.CompactFunc(runs, func(a, b time.Time) bool { return a.Equal(b) })
+	if len(runs) > count {
+		runs = runs[:count]
+	}
+	return runs, nil
 }
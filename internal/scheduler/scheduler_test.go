@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// newTestScheduler returns scheduler which works with a new database in the test directory
+func newTestScheduler(t *testing.T) (*SchedulerManager, *sql.DB) {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "backup.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	sm := NewSchedulerManager(database.NewJobRepo(db), database.NewReplicaRepo(db), database.NewRunRepo(db), database.NewSettingsRepo(db))
+	return sm, db
+}
+
+// createTestJob saves active job which copies the test directory every night
+func createTestJob(t *testing.T, sm *SchedulerManager, name string) *database.BackupJob {
+	t.Helper()
+	job, err := sm.JobRepo.CreateJob(&database.BackupJob{
+		Name:            name,
+		SourcePath:      t.TempDir(),
+		DestinationPath: t.TempDir(),
+		Schedule:        "0 3 * * *",
+		IsActive:        true,
+		SourceType:      database.SourceTypeFiles,
+		WatchChanges:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestReloadJob(t *testing.T) {
+	t.Run("deleted job is unscheduled", func(t *testing.T) {
+		sm, _ := newTestScheduler(t)
+		job := createTestJob(t, sm, "nightly")
+		sm.ReloadJob(job.ID)
+		t.Cleanup(sm.stopWatchers)
+		if len(sm.entries[job.ID]) == 0 || sm.watchers[job.ID] == nil {
+			t.Fatalf("job is not scheduled and watched")
+		}
+
+		if err := sm.JobRepo.DeleteJob(job.ID); err != nil {
+			t.Fatal(err)
+		}
+		sm.ReloadJob(job.ID)
+		if len(sm.entries[job.ID]) != 0 || sm.watchers[job.ID] != nil {
+			t.Errorf("deleted job is still scheduled or watched")
+		}
+	})
+
+	t.Run("database error keeps schedule", func(t *testing.T) {
+		sm, db := newTestScheduler(t)
+		job := createTestJob(t, sm, "nightly")
+		sm.ReloadJob(job.ID)
+		t.Cleanup(sm.stopWatchers)
+
+		db.Close()
+		sm.ReloadJob(job.ID)
+		if len(sm.entries[job.ID]) == 0 || sm.watchers[job.ID] == nil {
+			t.Errorf("job lost its schedule or watcher after failed reload")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrShuttingDown = errors.New("server is shutting down")
+
+	errShutdown = errors.New("server shutdown")
+)
+
+// Limit of waiting for cancelled runs to record their results
+const shutdownCancelWait = 10 * time.Second
+
+// Shutdown stops the scheduler without losing runs. New runs are rejected and working runs get time until ctx is done.
+// Runs which are still working then are cancelled and saved as queued, so the next start continues them.
+func (sm *SchedulerManager) Shutdown(ctx context.Context) error {
+	sm.locksMu.Lock()
+	sm.draining = true
+	jobs := len(sm.locks)
+	sm.locksMu.Unlock()
+
+	sm.Cron.Stop()
+	sm.stopTimers()
+	sm.stopWatchers()
+	if sm.stopLeader != nil {
+		close(sm.stopLeader)
+		sm.releaseLeadership()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		sm.running.Wait()
+		close(done)
+	}()
+
+	if jobs > 0 {
+		log.Printf("Scheduler: Waiting for runs of %d jobs to finish", jobs)
+	}
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Scheduler: Runs did not finish before shutdown timeout, cancelling them")
+		sm.cancelAll(errShutdown)
+		select {
+		case <-done:
+		case <-time.After(shutdownCancelWait):
+			err = fmt.Errorf("runs did not stop in %s after they were cancelled", shutdownCancelWait)
+		}
+	}
+
+	log.Println("Scheduler stopped.")
+	return err
+}
+
+// cancelAll stops working and waiting runs of all jobs
+func (sm *SchedulerManager) cancelAll(cause error) {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+
+	for _, lock := range sm.locks {
+		if lock.next != nil {
+			lock.next.cancel(cause)
+			close(lock.next.start)
+			lock.next = nil
+		}
+		lock.current.cancel(cause)
+	}
+}
+
+// checkpoint queues continuation of the run which was interrupted by shutdown, it starts after restart
+func (sm *SchedulerManager) checkpoint(job *database.BackupJob, attempt runAttempt, runID int, result backup.BackupResult) {
+	next := continuation(job, attempt, runID)
+	if _, err := sm.RunRepo.QueueRun(job.ID, database.RunTriggerResume, next.action, next.parentRunID, next.number, time.Now()); err != nil {
+		log.Printf("Scheduler: Failed to save continuation of job ID %d: %v", job.ID, err)
+		return
+	}
+	log.Printf("Scheduler: Job '%s' (ID: %d) interrupted by shutdown after %d files, %s run continues after restart",
+		job.Name, job.ID, result.Files, next.action)
+}
+
+// continuation is the attempt which continues stopped run.
+// Full files backup continues as incremental one, so files which are already copied are skipped.
+func continuation(job *database.BackupJob, attempt runAttempt, runID int) runAttempt {
+	next := runAttempt{parentRunID: attempt.parentRunID, number: attempt.number + 1, action: attempt.action}
+	if next.action == database.ActionFull && !job.IsReplication() && job.SourceType == database.SourceTypeFiles {
+		next.action = database.ActionIncremental
+	}
+	if next.parentRunID == 0 {
+		next.parentRunID = runID
+	}
+	return next
+}
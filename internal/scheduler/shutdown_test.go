@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// exec replaces the shell, so the killed command does not leave a child holding the output
+const longCommand = "exec sleep 30"
+
+// createCommandJob saves job which dumps output of the long command, so its run works until it is cancelled
+func createCommandJob(t *testing.T, sm *SchedulerManager, overlapPolicy string) *database.BackupJob {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses sh commands")
+	}
+	backup.SetAllowedCommands([]string{longCommand})
+	t.Cleanup(func() { backup.SetAllowedCommands(nil) })
+
+	job, err := sm.JobRepo.CreateJob(&database.BackupJob{
+		Name:            "dump",
+		SourcePath:      longCommand,
+		DestinationPath: t.TempDir(),
+		Schedule:        "manual",
+		IsActive:        true,
+		SourceType:      database.SourceTypeCommand,
+		OverlapPolicy:   overlapPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// jobRuns returns runs of the job from the oldest one
+func jobRuns(t *testing.T, sm *SchedulerManager, jobID int) []database.BackupRun {
+	t.Helper()
+	runs, _, err := sm.RunRepo.GetJobRuns(jobID, database.RunFilter{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs
+}
+
+// waitRunning waits until the first run of the job is saved as running
+func waitRunning(t *testing.T, sm *SchedulerManager, jobID int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if runs := jobRuns(t, sm, jobID); len(runs) > 0 && runs[0].Status == database.RunStatusRunning {
+			// Let the command start
+			time.Sleep(100 * time.Millisecond)
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func shutdownSoon(t *testing.T, sm *SchedulerManager) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := sm.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestShutdownInterruptsLongRun(t *testing.T) {
+	sm, _ := newTestScheduler(t)
+	job := createCommandJob(t, sm, database.OverlapPolicySkip)
+
+	if _, err := sm.StartJob(job, database.RunTriggerManual, false); err != nil {
+		t.Fatal(err)
+	}
+	waitRunning(t, sm, job.ID)
+	shutdownSoon(t, sm)
+
+	runs := jobRuns(t, sm, job.ID)
+	if len(runs) != 2 {
+		t.Fatalf("runs after shutdown: %+v, want interrupted run and its continuation", runs)
+	}
+	if runs[0].Status != database.RunStatusInterrupted {
+		t.Errorf("run status = %q, want %q", runs[0].Status, database.RunStatusInterrupted)
+	}
+	resume := runs[1]
+	if resume.Status != database.RunStatusQueued || resume.Trigger != database.RunTriggerResume ||
+		resume.ParentRunID.Int64 != int64(runs[0].ID) || resume.Attempt != 2 || resume.Action != database.ActionFull {
+		t.Errorf("continuation = %+v, want queued full resume of run ID %d", resume, runs[0].ID)
+	}
+}
+
+func TestShutdownKeepsQueuedRun(t *testing.T) {
+	sm, _ := newTestScheduler(t)
+	job := createCommandJob(t, sm, database.OverlapPolicyQueue)
+
+	if _, err := sm.StartJob(job, database.RunTriggerManual, false); err != nil {
+		t.Fatal(err)
+	}
+	waitRunning(t, sm, job.ID)
+	if queued, err := sm.StartJob(job, database.RunTriggerAPI, false); err != nil || !queued {
+		t.Fatalf("second run: queued %v, err %v", queued, err)
+	}
+	shutdownSoon(t, sm)
+
+	runs := jobRuns(t, sm, job.ID)
+	if len(runs) != 3 {
+		t.Fatalf("runs after shutdown: %+v, want interrupted, queued and continuation", runs)
+	}
+	if runs[0].Status != database.RunStatusInterrupted {
+		t.Errorf("working run status = %q, want %q", runs[0].Status, database.RunStatusInterrupted)
+	}
+	// Queued run was not started, it stays queued for the next start
+	if queued := runs[1]; queued.Status != database.RunStatusQueued || queued.Trigger != database.RunTriggerAPI {
+		t.Errorf("queued run = %+v, want it still queued", queued)
+	}
+	if resume := runs[2]; resume.Status != database.RunStatusQueued || resume.Trigger != database.RunTriggerResume {
+		t.Errorf("continuation = %+v, want queued resume", resume)
+	}
+}
+
+func TestShutdownRejectsNewRuns(t *testing.T) {
+	sm, _ := newTestScheduler(t)
+	job := createTestJob(t, sm, "nightly")
+
+	if err := sm.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.StartJob(job, database.RunTriggerManual, false); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("StartJob after shutdown: %v, want %v", err, ErrShuttingDown)
+	}
+	if result := sm.RunJob(job, database.RunTriggerCron); result.Status != database.RunStatusSkipped {
+		t.Errorf("RunJob after shutdown: %s %s", result.Status, result.Message)
+	}
+	if sm.IsRunning(job.ID) {
+		t.Errorf("rejected run is left as running")
+	}
+	for _, r := range jobRuns(t, sm, job.ID) {
+		if r.Status != database.RunStatusSkipped {
+			t.Errorf("run %+v is recorded, want only skipped runs", r)
+		}
+	}
+}
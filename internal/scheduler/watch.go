@@ -0,0 +1,271 @@
+package scheduler
+
+import (
+	"backup-app/internal/database"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Incremental run with more changed paths copies the whole source
+const maxWatchChangedPaths = 10000
+
+// sourceWatcher starts incremental runs of the job when files in its source directory change
+type sourceWatcher struct {
+	sm          *SchedulerManager
+	jobID       int
+	root        string
+	debounce    time.Duration
+	minInterval time.Duration
+	fsw         *fsnotify.Watcher
+
+	mu      sync.Mutex
+	changed map[string]bool
+	lastRun time.Time
+	timer   *time.Timer
+	stopped bool
+}
+
+// watchSettings identifies watcher of the job, watcher is restarted when they change
+func watchSettings(job *database.BackupJob) string {
+	return fmt.Sprintf("%s|%d|%d", job.SourcePath, job.WatchDebounceSeconds, job.WatchMinIntervalSeconds)
+}
+
+// watchesChanges tells if the job needs source watcher
+func watchesChanges(job *database.BackupJob) bool {
+	return job.IsActive && job.WatchChanges && !job.IsReplication() && job.SourceType == database.SourceTypeFiles
+}
+
+// syncWatcher starts, restarts or stops watcher of the job. Must be called with reloadMu locked.
+func (sm *SchedulerManager) syncWatcher(job *database.BackupJob) {
+	if !watchesChanges(job) {
+		sm.unwatchJob(job.ID)
+		return
+	}
+
+	settings := watchSettings(job)
+	if _, ok := sm.watchers[job.ID]; ok && sm.watchSpecs[job.ID] == settings {
+		return
+	}
+	sm.unwatchJob(job.ID)
+
+	w, err := newSourceWatcher(sm, job)
+	if err != nil {
+		log.Printf("Scheduler: Can't watch source of job '%s' (ID: %d): %v", job.Name, job.ID, err)
+		return
+	}
+	sm.watchers[job.ID] = w
+	sm.watchSpecs[job.ID] = settings
+	log.Printf("Scheduler: Watching '%s' for changes of job '%s' (ID: %d)", job.SourcePath, job.Name, job.ID)
+}
+
+// unwatchJob stops watcher of the job if it has one. Must be called with reloadMu locked.
+func (sm *SchedulerManager) unwatchJob(jobID int) {
+	w, ok := sm.watchers[jobID]
+	if !ok {
+		return
+	}
+	w.stop()
+	delete(sm.watchers, jobID)
+	delete(sm.watchSpecs, jobID)
+	log.Printf("Scheduler: Watcher of job ID %d stopped", jobID)
+}
+
+func (sm *SchedulerManager) stopWatchers() {
+	sm.reloadMu.Lock()
+	defer sm.reloadMu.Unlock()
+	for jobID := range sm.watchers {
+		sm.unwatchJob(jobID)
+	}
+}
+
+func newSourceWatcher(sm *SchedulerManager, job *database.BackupJob) (*sourceWatcher, error) {
+	info, err := os.Stat(job.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source '%s' is not a directory", job.SourcePath)
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("can't create watcher: %w", err)
+	}
+	w := &sourceWatcher{
+		sm:          sm,
+		jobID:       job.ID,
+		root:        filepath.Clean(job.SourcePath),
+		debounce:    time.Duration(job.WatchDebounceSeconds) * time.Second,
+		minInterval: time.Duration(job.WatchMinIntervalSeconds) * time.Second,
+		fsw:         fsw,
+		changed:     map[string]bool{},
+	}
+	if err := w.addTree(w.root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	go w.loop()
+	return w, nil
+}
+
+// addTree watches directory with all its subdirectories, inotify watches are not recursive
+func (w *sourceWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := w.fsw.Add(path); err != nil {
+			return fmt.Errorf("can't watch '%s': %w", path, err)
+		}
+		return nil
+	})
+}
+
+func (w *sourceWatcher) loop() {
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Scheduler: Watcher error for job ID %d: %v", w.jobID, err)
+		}
+	}
+}
+
+func (w *sourceWatcher) handle(event fsnotify.Event) {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			// Files created before the watch was added are copied with the directory
+			if err := w.addTree(event.Name); err != nil {
+				log.Printf("Scheduler: Watcher of job ID %d: %v", w.jobID, err)
+			}
+		}
+	}
+
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil || rel == "." {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	w.changed[filepath.ToSlash(rel)] = true
+	w.schedule(w.debounce)
+}
+
+// schedule (re)starts the timer of the run, must be called with mu locked
+func (w *sourceWatcher) schedule(delay time.Duration) {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(delay, w.fire)
+}
+
+func (w *sourceWatcher) fire() {
+	w.mu.Lock()
+	if w.stopped || len(w.changed) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	if !w.sm.IsLeader() {
+		// Leader instance watches the same source and starts the run
+		w.changed = map[string]bool{}
+		w.mu.Unlock()
+		return
+	}
+	if wait := w.minInterval - time.Since(w.lastRun); wait > 0 {
+		w.schedule(wait)
+		w.mu.Unlock()
+		return
+	}
+	if w.sm.IsRunning(w.jobID) {
+		// Changes are kept for the run after the current one
+		w.schedule(w.debounce)
+		w.mu.Unlock()
+		return
+	}
+
+	var paths []string
+	for p := range w.changed {
+		paths = append(paths, p)
+	}
+	w.changed = map[string]bool{}
+	w.lastRun = time.Now()
+	w.mu.Unlock()
+
+	job, err := w.sm.JobRepo.GetJobByID(w.jobID)
+	if err != nil {
+		log.Printf("Scheduler: Failed to load job ID %d for watch run: %v", w.jobID, err)
+		return
+	}
+	paths = compactPaths(paths)
+	if len(paths) > maxWatchChangedPaths {
+		log.Printf("Scheduler: %d paths changed in source of job '%s' (ID: %d), starting full run", len(paths), job.Name, job.ID)
+		paths = nil
+	} else {
+		log.Printf("Scheduler: %d paths changed in source of job '%s' (ID: %d), starting incremental run", len(paths), job.Name, job.ID)
+	}
+	if _, err := w.sm.startRun(job, database.RunTriggerWatch, runAttempt{number: 1, action: database.ActionIncremental}, paths); err != nil {
+		log.Printf("Scheduler: Watch run of job ID %d not started: %v", job.ID, err)
+	}
+}
+
+func (w *sourceWatcher) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	w.fsw.Close()
+}
+
+// compactPaths drops paths which are inside other changed paths, they are copied with the parent directory
+func compactPaths(paths []string) []string {
+	changed := map[string]bool{}
+	for _, p := range paths {
+		changed[p] = true
+	}
+
+	var result []string
+	for _, p := range paths {
+		inside := false
+		for parent := p; !inside; {
+			i := strings.LastIndex(parent, "/")
+			if i < 0 {
+				break
+			}
+			parent = parent[:i]
+			inside = changed[parent]
+		}
+		if !inside {
+			result = append(result, p)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
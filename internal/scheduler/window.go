@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"backup-app/internal/backup"
+	"backup-app/internal/database"
+	"backup-app/internal/window"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	errMaxRuntime   = errors.New("maximum run time exceeded")
+	errWindowClosed = errors.New("backup window closed")
+)
+
+// jobWindows returns global backup window together with the window of the job in its time zone
+func (sm *SchedulerManager) jobWindows(job *database.BackupJob) (window.Set, error) {
+	w, err := window.Parse(job.WindowStart, job.WindowEnd, job.BlackoutDates)
+	if err != nil {
+		return nil, fmt.Errorf("wrong backup window of job: %w", err)
+	}
+	loc, err := database.LoadLocation(job.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	return window.Set{sm.globalWindow, w.In(loc)}, nil
+}
+
+// ignoresWindows tells if run is started by user, such runs are not limited by backup windows
+func ignoresWindows(trigger string) bool {
+	return trigger == database.RunTriggerManual || trigger == database.RunTriggerAPI
+}
+
+// runContext limits the run by maximum run time of the job and by the end of backup window
+func runContext(ctx context.Context, job *database.BackupJob, windows window.Set, trigger string, now time.Time) (context.Context, context.CancelFunc) {
+	var cancels []context.CancelFunc
+	if job.MaxRuntimeMinutes > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, time.Duration(job.MaxRuntimeMinutes)*time.Minute, errMaxRuntime)
+		cancels = append(cancels, cancel)
+	}
+	if !ignoresWindows(trigger) {
+		if closes := windows.ClosesAt(now); !closes.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadlineCause(ctx, closes, errWindowClosed)
+			cancels = append(cancels, cancel)
+		}
+	}
+	return ctx, func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// applyStopCause sets status of the run which was stopped by maximum run time or backup window
+func applyStopCause(ctx context.Context, job *database.BackupJob, result *backup.BackupResult) {
+	if result.Status != backup.StatusCancelled {
+		return
+	}
+	switch context.Cause(ctx) {
+	case errShutdown:
+		result.Status = database.RunStatusInterrupted
+		result.Message = fmt.Sprintf("Run interrupted by server shutdown after %d files (%d bytes), it continues after restart", result.Files, result.Bytes)
+	case errMaxRuntime:
+		result.Status = backup.StatusTimedOut
+		result.Message = fmt.Sprintf("Run timed out: maximum run time of %d min exceeded", job.MaxRuntimeMinutes)
+	case errWindowClosed:
+		if job.WindowEndAction == database.WindowEndPause {
+			result.Status = backup.StatusPaused
+			result.Message = "Run paused: backup window closed, it continues in the next window"
+		} else {
+			result.Status = backup.StatusTimedOut
+			result.Message = "Run timed out: backup window closed"
+		}
+	}
+}
+
+// scheduleResume queues continuation of the paused run, it starts when backup window opens.
+// Continuation is saved, so it is not lost when the server restarts before the window opens.
+func (sm *SchedulerManager) scheduleResume(job *database.BackupJob, windows window.Set, attempt runAttempt, runID int) bool {
+	opens := windows.NextOpen(time.Now())
+	if opens.IsZero() {
+		log.Printf("Scheduler: Job '%s' (ID: %d) is paused, but backup window never opens again", job.Name, job.ID)
+		return false
+	}
+
+	next := continuation(job, attempt, runID)
+	queued, err := sm.RunRepo.QueueRun(job.ID, database.RunTriggerResume, next.action, next.parentRunID, next.number, opens)
+	if err != nil {
+		log.Printf("Scheduler: Failed to save continuation of job ID %d: %v", job.ID, err)
+		return false
+	}
+	next.runID = queued.ID
+	log.Printf("Scheduler: Job '%s' (ID: %d) paused, %s run resumes at %s", job.Name, job.ID, next.action, opens.Format("2006-01-02 15:04"))
+
+	sm.resumeAt(job.ID, opens, next)
+	return true
+}
+
+// resumeAt starts the saved continuation of the job when backup window opens
+func (sm *SchedulerManager) resumeAt(jobID int, opens time.Time, next runAttempt) {
+	sm.afterFunc(time.Until(opens), func() {
+		job, err := sm.JobRepo.GetJobByID(jobID)
+		if err != nil {
+			log.Printf("Scheduler: Resume cancelled, can't load job: %v", err)
+			return
+		}
+		log.Printf("Scheduler: Resuming job '%s' (ID: %d)", job.Name, job.ID)
+		sm.runAttempt(job, database.RunTriggerResume, next)
+	})
+}
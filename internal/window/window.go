@@ -0,0 +1,217 @@
+// Package window describes time windows when backups are allowed to run.
+package window
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// Limit of days to look for the window opening or closing
+	maxSearchDays = 400
+)
+
+// Window is daily time of day range with blackout dates when runs are not allowed.
+// Zero Window allows runs at any time.
+type Window struct {
+	hours    bool
+	start    int // minutes since midnight
+	end      int // minutes since midnight, less than start for windows over midnight
+	blackout []dateRange
+	loc      *time.Location
+}
+
+type dateRange struct {
+	from, to string // inclusive, 2006-01-02
+}
+
+// Parse reads window from "15:04" start and end times and blackout dates.
+// Blackout dates are separated by commas or new lines, range of days is written as 2006-01-01..2006-01-07.
+// Empty start and end mean the whole day. Window is in the local time zone.
+func Parse(start, end, blackout string) (Window, error) {
+	w := Window{loc: time.Local}
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if start != "" || end != "" {
+		if start == "" || end == "" {
+			return w, fmt.Errorf("both start and end of backup window are required")
+		}
+		var err error
+		if w.start, err = parseClock(start); err != nil {
+			return w, err
+		}
+		if w.end, err = parseClock(end); err != nil {
+			return w, err
+		}
+		if w.start == w.end {
+			return w, fmt.Errorf("start and end of backup window are the same")
+		}
+		w.hours = true
+	}
+
+	for _, item := range strings.FieldsFunc(blackout, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(item, "..")
+		if !isRange {
+			to = from
+		}
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		for _, d := range []string{from, to} {
+			if _, err := time.Parse(dateLayout, d); err != nil {
+				return w, fmt.Errorf("wrong blackout date '%s', expected YYYY-MM-DD", d)
+			}
+		}
+		if to < from {
+			return w, fmt.Errorf("wrong blackout range '%s', end is before start", item)
+		}
+		w.blackout = append(w.blackout, dateRange{from: from, to: to})
+	}
+	return w, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("wrong time '%s', expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// In returns the window which hours and dates are in time zone loc
+func (w Window) In(loc *time.Location) Window {
+	w.loc = loc
+	return w
+}
+
+func (w Window) local(t time.Time) time.Time {
+	if w.loc == nil {
+		return t
+	}
+	return t.In(w.loc)
+}
+
+// IsZero tells if the window allows runs at any time
+func (w Window) IsZero() bool {
+	return !w.hours && len(w.blackout) == 0
+}
+
+// Allows tells if run can work at t
+func (w Window) Allows(t time.Time) bool {
+	t = w.local(t)
+	return w.inHours(t) && !w.blacked(t)
+}
+
+func (w Window) inHours(t time.Time) bool {
+	if !w.hours {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+func (w Window) blacked(t time.Time) bool {
+	day := t.Format(dateLayout)
+	for _, r := range w.blackout {
+		if day >= r.from && day <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// ClosesAt returns when the window which is open at t closes, zero time when it never closes
+func (w Window) ClosesAt(t time.Time) time.Time {
+	t = w.local(t)
+	var closes time.Time
+	if w.hours {
+		closes = clockAt(t, w.end)
+		if !closes.After(t) {
+			closes = clockAt(t.AddDate(0, 0, 1), w.end)
+		}
+	}
+	if len(w.blackout) > 0 {
+		day := midnight(t)
+		for i := 0; i < maxSearchDays; i++ {
+			day = day.AddDate(0, 0, 1)
+			if !closes.IsZero() && !day.Before(closes) {
+				break
+			}
+			if w.blacked(day) {
+				closes = day
+				break
+			}
+		}
+	}
+	return closes
+}
+
+// NextOpen returns the first time from t when the window allows runs, zero time when it is not found
+func (w Window) NextOpen(t time.Time) time.Time {
+	return Set{w}.NextOpen(t)
+}
+
+// Set is a group of windows, run is allowed only when all of them allow it
+type Set []Window
+
+func (s Set) Allows(t time.Time) bool {
+	for _, w := range s {
+		if !w.Allows(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClosesAt returns when the first of windows closes after t, zero time when they never close
+func (s Set) ClosesAt(t time.Time) time.Time {
+	var closes time.Time
+	for _, w := range s {
+		c := w.ClosesAt(t)
+		if !c.IsZero() && (closes.IsZero() || c.Before(closes)) {
+			closes = c
+		}
+	}
+	return closes
+}
+
+// NextOpen returns the first time from t when all windows allow runs, zero time when it is not found
+func (s Set) NextOpen(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	for i := 0; i < maxSearchDays*2; i++ {
+		moved := false
+		for _, w := range s {
+			t = w.local(t)
+			if w.blacked(t) {
+				t = midnight(t).AddDate(0, 0, 1)
+				moved = true
+			}
+			if !w.inHours(t) {
+				next := clockAt(t, w.start)
+				if next.Before(t) {
+					next = clockAt(t.AddDate(0, 0, 1), w.start)
+				}
+				t = next
+				moved = true
+			}
+		}
+		if !moved {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func clockAt(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location())
+}
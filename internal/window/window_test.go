@@ -0,0 +1,78 @@
+package window
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, start, end, blackout string) Window {
+	t.Helper()
+	w, err := Parse(start, end, blackout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.In(time.UTC)
+}
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 5, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestOvernightWindow(t *testing.T) {
+	overnight := mustParse(t, "22:00", "06:00", "")
+	withBlackout := mustParse(t, "22:00", "06:00", "2026-05-02")
+	morning := mustParse(t, "04:00", "08:00", "")
+
+	tests := []struct {
+		name     string
+		set      Set
+		t        time.Time
+		allows   bool
+		closesAt time.Time
+		nextOpen time.Time
+	}{
+		{name: "evening", set: Set{overnight}, t: at(1, 23, 0), allows: true, closesAt: at(2, 6, 0), nextOpen: at(1, 23, 0)},
+		{name: "start", set: Set{overnight}, t: at(1, 22, 0), allows: true, closesAt: at(2, 6, 0), nextOpen: at(1, 22, 0)},
+		{name: "after midnight", set: Set{overnight}, t: at(2, 2, 0), allows: true, closesAt: at(2, 6, 0), nextOpen: at(2, 2, 0)},
+		{name: "end", set: Set{overnight}, t: at(2, 6, 0), nextOpen: at(2, 22, 0)},
+		{name: "day", set: Set{overnight}, t: at(2, 12, 0), nextOpen: at(2, 22, 0)},
+		{name: "blackout day after midnight", set: Set{withBlackout}, t: at(1, 23, 0), allows: true, closesAt: at(2, 0, 0), nextOpen: at(1, 23, 0)},
+		{name: "blackout day", set: Set{withBlackout}, t: at(2, 3, 0), nextOpen: at(3, 0, 0)},
+		{name: "overlap of windows", set: Set{overnight, morning}, t: at(1, 23, 0), nextOpen: at(2, 4, 0)},
+		{name: "inside overlap", set: Set{overnight, morning}, t: at(2, 5, 0), allows: true, closesAt: at(2, 6, 0), nextOpen: at(2, 5, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.set.Allows(tt.t); got != tt.allows {
+				t.Errorf("Allows(%s) = %v, want %v", tt.t, got, tt.allows)
+			}
+			if tt.allows {
+				if got := tt.set.ClosesAt(tt.t); !got.Equal(tt.closesAt) {
+					t.Errorf("ClosesAt(%s) = %s, want %s", tt.t, got, tt.closesAt)
+				}
+			}
+			if got := tt.set.NextOpen(tt.t); !got.Equal(tt.nextOpen) {
+				t.Errorf("NextOpen(%s) = %s, want %s", tt.t, got, tt.nextOpen)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, start, end, blackout string
+	}{
+		{"only start", "22:00", "", ""},
+		{"same start and end", "22:00", "22:00", ""},
+		{"wrong time", "22:00", "30:00", ""},
+		{"wrong date", "", "", "2026-13-01"},
+		{"reversed range", "", "", "2026-05-07..2026-05-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.start, tt.end, tt.blackout); err == nil {
+				t.Errorf("Parse(%q, %q, %q) is accepted", tt.start, tt.end, tt.blackout)
+			}
+		})
+	}
+}